@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"flag"
 	"fmt"
 	"log"
@@ -8,18 +10,37 @@ import (
 
 	"github.com/atletaid/go-template/config"
 	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/common/mail"
+	"github.com/atletaid/go-template/src/common/migration"
+	"github.com/atletaid/go-template/src/common/purge"
+	"github.com/atletaid/go-template/src/common/ratelimit"
+	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/account"
 	"github.com/atletaid/go-template/src/module/account/delivery"
 	"github.com/atletaid/go-template/src/module/account/repository"
+	"github.com/atletaid/go-template/src/module/deck"
+	_deck_rest "github.com/atletaid/go-template/src/module/deck/delivery"
+	_deck_repo "github.com/atletaid/go-template/src/module/deck/repository"
+	"github.com/atletaid/go-template/src/module/group"
+	_group_rest "github.com/atletaid/go-template/src/module/group/delivery"
+	_group_repo "github.com/atletaid/go-template/src/module/group/repository"
 	"github.com/atletaid/go-template/src/module/recreation"
 	_recreation_rest "github.com/atletaid/go-template/src/module/recreation/delivery"
 	_recreation_repo "github.com/atletaid/go-template/src/module/recreation/repository"
 	"github.com/atletaid/go-template/src/module/restaurant"
 	_restaurant_rest "github.com/atletaid/go-template/src/module/restaurant/delivery"
 	_restaurant_repo "github.com/atletaid/go-template/src/module/restaurant/repository"
+	"github.com/atletaid/go-template/src/module/search"
+	_search_rest "github.com/atletaid/go-template/src/module/search/delivery"
+	_search_repo "github.com/atletaid/go-template/src/module/search/repository"
+	"github.com/atletaid/go-template/src/module/swipe"
+	_swipe_rest "github.com/atletaid/go-template/src/module/swipe/delivery"
+	_swipe_repo "github.com/atletaid/go-template/src/module/swipe/repository"
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
-	"github.com/tokopedia/sqlt"
 )
 
 func main() {
@@ -32,39 +53,95 @@ func main() {
 		return
 	}
 
+	requireMigrations := flag.Bool("require-migrations", false, "refuse to start while database migrations are pending")
 	flag.Parse()
 
 	// Init PostgreSQL Database
-	dbMaster, err := sqlt.Open("postgres", cfg.Account.MasterDB)
+	db, err := database.Open(cfg.Account.MasterDB, cfg.Account.SlaveDB, cfg.Server.ReplicaMaxLag*time.Second, cfg.Server.ReplicaCheckInterval*time.Second)
 	if err != nil {
 		log.Println("Error opening database : ", err)
 		return
 	}
 
+	if *requireMigrations {
+		migrations, err := migration.Load()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		pending, err := migration.NewMigrator(db.Master(), migrations).Pending(context.Background())
+		if err != nil {
+			log.Println("Error checking migrations : ", err)
+			return
+		}
+
+		if len(pending) > 0 {
+			log.Printf("%d pending migrations, run `make migrate-up` first", len(pending))
+			return
+		}
+	}
+
 	// Init Inmemory & Redis Cache
-	accountCache := repository.NewAccountCache(cfg.InMemory.DefaultExpiration, cfg.InMemory.IntervalPurges)
-	recreationCache := _recreation_repo.NewRecreationCache(cfg.InMemory.DefaultExpiration, cfg.InMemory.IntervalPurges)
-	restaurantCache := _restaurant_repo.NewRestaurantCache(cfg.InMemory.DefaultExpiration, cfg.InMemory.IntervalPurges)
-	redisPool, err := repository.NewPool(cfg.Redis.Host, cfg.Redis.DialTimeout*time.Second, cfg.Redis.IdleTimeout*time.Second, cfg.Redis.PoolSize)
+	redisPool := repository.NewPool(cfg.Redis.Host, cfg.Redis.DialTimeout*time.Second, cfg.Redis.IdleTimeout*time.Second, cfg.Redis.PoolSize)
+	cacheStore := cache.NewStore(redisPool, cfg.InMemory.IntervalPurges*time.Minute, cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown*time.Second)
+	go cacheStore.Listen()
+
+	tokenizer, err := auth.NewTokenizer(cfg.Auth)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	sessionStore := auth.NewSessionStore(cacheStore, cfg.Auth.RefreshTokenExpiration*time.Minute)
+	authMiddleware := auth.NewMiddleware(tokenizer, sessionStore)
+	actionTokenStore := auth.NewActionTokenStore(redisPool)
+	oidcLogin := auth.NewOIDC(redisPool, cfg.OIDC)
+	rateLimiter, err := ratelimit.NewLimiter(cacheStore, cfg.RateLimit, cfg.Server.TrustedProxies)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	authMiddleware := auth.NewMiddleware()
+	mailer, err := mail.NewMailer(cfg.Mail)
+	if err != nil {
+		log.Println(err)
+		return
+	}
 
-	accountRepo := repository.NewAccountRepository(dbMaster, dbMaster, cfg.Server.DBTimeout*time.Second)
-	accountRepo = repository.NewMiddlewareAccountRepository(accountCache, redisPool, accountRepo)
-	accountUsecase := account.NewAccountUsecase(accountRepo)
+	accountRepo := repository.NewAccountRepository(db, cfg.Server.DBTimeout*time.Second)
+	accountRepo = repository.NewMiddlewareAccountRepository(cacheStore, cfg.InMemory.AccountExpiration*time.Minute, cfg.Redis.AccountExpiration*time.Second, cfg.InMemory.StaleWhileRevalidate*time.Minute, accountRepo)
+	accountUsecase := account.NewAccountUsecase(accountRepo, sessionStore, actionTokenStore, mailer, cfg.Mail.LinkBaseURL, cfg.Auth.VerifyEmailExpiration*time.Minute, cfg.Auth.ResetPasswordExpiration*time.Minute)
 
-	recreationRepo := _recreation_repo.NewRecreationRepository(dbMaster, dbMaster, cfg.Server.DBTimeout*time.Second)
-	recreationRepo = _recreation_repo.NewMiddlewareRecreationRepository(recreationCache, redisPool, recreationRepo)
+	recreationDBRepo := _recreation_repo.NewRecreationRepository(db, cfg.Server.DBTimeout*time.Second)
+	recreationRepo := _recreation_repo.NewMiddlewareRecreationRepository(cacheStore, cfg.InMemory.RecreationExpiration*time.Minute, cfg.Redis.RecreationExpiration*time.Second, cfg.InMemory.StaleWhileRevalidate*time.Minute, recreationDBRepo)
+	recreationRepo = _recreation_repo.NewGeoRecreationRepository(geo.NewIndex(cacheStore, _recreation_repo.KeyRecreationsGeo), recreationDBRepo, recreationRepo)
 	recreationUsecase := recreation.NewRecreationUsecase(recreationRepo)
 
-	restaurantRepo := _restaurant_repo.NewRestaurantRepository(dbMaster, dbMaster, cfg.Server.DBTimeout*time.Second)
-	restaurantRepo = _restaurant_repo.NewMiddlewareRestaurantRepository(restaurantCache, redisPool, restaurantRepo)
+	restaurantDBRepo := _restaurant_repo.NewRestaurantRepository(db, cfg.Server.DBTimeout*time.Second)
+	restaurantRepo := _restaurant_repo.NewMiddlewareRestaurantRepository(cacheStore, cfg.InMemory.RestaurantExpiration*time.Minute, cfg.Redis.RestaurantExpiration*time.Second, cfg.InMemory.StaleWhileRevalidate*time.Minute, restaurantDBRepo)
+	restaurantRepo = _restaurant_repo.NewGeoRestaurantRepository(geo.NewIndex(cacheStore, _restaurant_repo.KeyRestaurantsGeo), restaurantDBRepo, restaurantRepo)
 	restaurantUsecase := restaurant.NewRestaurantUsecase(restaurantRepo)
 
+	searchRepo := _search_repo.NewSearchRepository(db, cfg.Server.DBTimeout*time.Second)
+	searchUsecase := search.NewSearchUsecase(searchRepo)
+
+	swipeRepo := _swipe_repo.NewSwipeRepository(db, cfg.Server.DBTimeout*time.Second)
+	swipeUsecase := swipe.NewSwipeUsecase(swipeRepo, restaurantRepo, recreationRepo)
+
+	deckRepo := _deck_repo.NewDeckRepository(db, cfg.Server.DBTimeout*time.Second)
+	deckStore := _deck_repo.NewDeckStore(redisPool, cfg.Deck.Expiration*time.Minute)
+	deckUsecase := deck.NewDeckUsecase(deckRepo, deckStore, cfg.Deck)
+
+	groupStore := _group_repo.NewGroupStore(redisPool, cfg.Group.InactivityTimeout*time.Minute)
+	groupEventBus := _group_repo.NewEventBus(redisPool, cfg.Group.InactivityTimeout*time.Minute, cfg.Group.EventLogSize)
+	groupUsecase := group.NewGroupUsecase(groupStore, groupEventBus, deckUsecase, cfg.Group.MaxMembers)
+
+	go purge.Run(cfg.Server.PurgeInterval*time.Minute, cfg.Server.PurgeRetention*time.Hour, map[string]purge.Func{
+		"accounts":    accountUsecase.PurgeDeactivatedAccounts,
+		"restaurants": restaurantUsecase.PurgeDeletedRestaurants,
+		"recreations": recreationUsecase.PurgeDeletedRecreations,
+	})
+
 	var ginRouter *gin.Engine
 	if cfg.Server.Enviroment == "development" {
 		ginRouter = gin.Default()
@@ -72,8 +149,27 @@ func main() {
 		ginRouter = gin.New()
 	}
 
-	router := delivery.NewAccountHandler(ginRouter, authMiddleware, accountUsecase)
-	router = _recreation_rest.NewRecreationHandler(router, recreationUsecase)
-	router = _restaurant_rest.NewRestaurantHandler(router, restaurantUsecase)
+	router := newRouter(ginRouter, cfg, authMiddleware, rateLimiter, tokenizer, sessionStore, oidcLogin,
+		accountUsecase, recreationUsecase, restaurantUsecase, searchUsecase, swipeUsecase, deckUsecase, groupUsecase)
 	router.Run(cfg.Account.Port)
 }
+
+// newRouter registers the routes of every module on ginRouter.
+func newRouter(ginRouter *gin.Engine, cfg *config.Config, authMiddleware *auth.Middleware, rateLimiter *ratelimit.Limiter,
+	tokenizer *auth.Tokenizer, sessionStore *auth.SessionStore, oidcLogin *auth.OIDC,
+	accountUsecase account.Usecase, recreationUsecase recreation.Usecase, restaurantUsecase restaurant.Usecase,
+	searchUsecase search.Usecase, swipeUsecase swipe.Usecase, deckUsecase deck.Usecase, groupUsecase group.Usecase) *gin.Engine {
+	ginRouter.Use(rateLimiter.Limit("default"))
+	ginRouter.GET("/debug/vars", authMiddleware.AuthUserToken(), authMiddleware.RequireRole(model.RoleAdmin), gin.WrapH(expvar.Handler()))
+
+	router := delivery.NewAccountHandler(ginRouter, authMiddleware, rateLimiter, accountUsecase)
+	router = delivery.NewAuthHandler(router, authMiddleware, rateLimiter, tokenizer, sessionStore, oidcLogin, accountUsecase)
+	router = _recreation_rest.NewRecreationHandler(router, authMiddleware, recreationUsecase)
+	router = _restaurant_rest.NewRestaurantHandler(router, authMiddleware, restaurantUsecase)
+	router = _search_rest.NewSearchHandler(router, searchUsecase)
+	router = _swipe_rest.NewSwipeHandler(router, authMiddleware, swipeUsecase)
+	router = _deck_rest.NewDeckHandler(router, authMiddleware, deckUsecase, cfg.Deck.Size)
+	router = _group_rest.NewGroupHandler(router, authMiddleware, groupUsecase, cfg.Deck.Size, cfg.Group.HeartbeatInterval*time.Second)
+
+	return router
+}
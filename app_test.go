@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/ratelimit"
+	"github.com/atletaid/go-template/src/module/account/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// gin panics on conflicting routes, so registering every module once
+// catches paths that clash across handlers before they reach a deploy.
+func TestNewRouter(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{Algorithm: "HS256", HMACSecret: "test secret"},
+		RateLimit: map[string]*config.RateLimitConfig{
+			"default": {Requests: 120, Period: 60, KeyBy: "ip"},
+			"signup":  {Requests: 5, Period: 3600, KeyBy: "ip"},
+			"auth":    {Requests: 10, Period: 60, KeyBy: "ip"},
+		},
+		Deck:  config.DeckConfig{Size: 20},
+		Group: config.GroupConfig{HeartbeatInterval: 15},
+	}
+
+	// Redis is never reached; the cache falls back to memory.
+	redisPool := repository.NewPool("127.0.0.1:0", time.Second, time.Second, 1)
+	cacheStore := cache.NewStore(redisPool, time.Minute, 1, time.Minute)
+
+	tokenizer, err := auth.NewTokenizer(cfg.Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStore := auth.NewSessionStore(cacheStore, time.Hour)
+	rateLimiter, err := ratelimit.NewLimiter(cacheStore, cfg.RateLimit, cfg.Server.TrustedProxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := newRouter(gin.New(), cfg, auth.NewMiddleware(tokenizer, sessionStore), rateLimiter, tokenizer, sessionStore,
+		auth.NewOIDC(redisPool, nil), nil, nil, nil, nil, nil, nil, nil)
+
+	routes := map[string]bool{}
+	for _, route := range router.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+
+	for _, route := range []string{
+		"GET /debug/vars",
+		"POST /api/restaurant/city",
+		"POST /api/restaurants/:restaurant_id/restore",
+		"PUT /api/v1/account/:account_id/role",
+	} {
+		if !routes[route] {
+			t.Errorf("route %s not registered", route)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/migration"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage: migrate [flags] <command>
+
+commands:
+  up             apply every pending migration
+  down [n]       revert the last n applied migrations (default 1)
+  status         list migrations and when they were applied
+  create <name>  add an empty up/down pair to -dir
+
+flags:
+`
+
+func main() {
+	log.SetFlags(log.Llongfile | log.Ldate)
+
+	dir := flag.String("dir", migration.Dir, "directory `create` writes new migrations to")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if flag.Arg(0) == "create" {
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		paths, err := migration.Create(*dir, flag.Arg(1))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return
+	}
+
+	//Init Config
+	cfg, ok := config.InitConfig([]string{"files/etc/config"}...)
+	if !ok {
+		log.Fatalln("Error opening config files")
+	}
+
+	db, err := database.Open(cfg.Account.MasterDB, "", cfg.Server.ReplicaMaxLag*time.Second, cfg.Server.ReplicaCheckInterval*time.Second)
+	if err != nil {
+		log.Fatalln("Error opening database : ", err)
+	}
+
+	migrations, err := migration.Load()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	migrator := migration.NewMigrator(db.Master(), migrations)
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps < 1 {
+				log.Fatalln("down expects a positive number of steps")
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
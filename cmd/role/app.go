@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/account/repository"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage: role <email> <user|curator|admin>
+
+Grants an account a role, e.g. to make the first admin, who can then manage
+roles through PUT /api/v1/account/:account_id/role. The account picks the
+role up at its next login or token refresh, once cached copies of it expire.
+`
+
+func main() {
+	log.SetFlags(log.Llongfile | log.Ldate)
+
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 || !model.IsRole(flag.Arg(1)) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	//Init Config
+	cfg, ok := config.InitConfig([]string{"files/etc/config"}...)
+	if !ok {
+		log.Fatalln("Error opening config files")
+	}
+
+	db, err := database.Open(cfg.Account.MasterDB, "", cfg.Server.ReplicaMaxLag*time.Second, cfg.Server.ReplicaCheckInterval*time.Second)
+	if err != nil {
+		log.Fatalln("Error opening database : ", err)
+	}
+
+	accountRepo := repository.NewAccountRepository(db, cfg.Server.DBTimeout*time.Second)
+
+	account, err := accountRepo.FindByEmail(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := accountRepo.UpdateRole(account.AccountID, flag.Arg(1)); err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("account %d (%s) is now %s\n", account.AccountID, account.Email, flag.Arg(1))
+}
@@ -10,15 +10,30 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Account  AccountConfig
-	Redis    RedisConfig
-	InMemory InMemoryConfig
+	Server    ServerConfig
+	Account   AccountConfig
+	Redis     RedisConfig
+	InMemory  InMemoryConfig
+	Auth      AuthConfig
+	Mail      MailConfig
+	OIDC      map[string]*OIDCConfig
+	RateLimit map[string]*RateLimitConfig
+	Deck      DeckConfig
+	Group     GroupConfig
 }
 
+// ServerConfig durations are in seconds, except PurgeInterval in minutes
+// and PurgeRetention, how long soft-deleted rows are kept, in hours.
+// TrustedProxies is a comma-separated list of IPs or CIDRs whose
+// X-Forwarded-For header is believed; it is ignored from anyone else.
 type ServerConfig struct {
-	Enviroment string
-	DBTimeout  time.Duration
+	Enviroment           string
+	DBTimeout            time.Duration
+	ReplicaMaxLag        time.Duration
+	ReplicaCheckInterval time.Duration
+	PurgeInterval        time.Duration
+	PurgeRetention       time.Duration
+	TrustedProxies       string
 }
 
 type AccountConfig struct {
@@ -27,16 +42,244 @@ type AccountConfig struct {
 	SlaveDB  string
 }
 
-type RedisConfig struct {
+// AuthConfig configures access tokens. Algorithm is HS256, signed with
+// HMACSecret, or RS256, signed with the PEM key in RSAPrivateKeyFile and
+// verified with RSAPublicKeyFile. Expirations are in minutes; the verify
+// email and reset password ones bound the tokens mailed to users.
+type AuthConfig struct {
+	Algorithm               string
+	HMACSecret              string
+	RSAPrivateKeyFile       string
+	RSAPublicKeyFile        string
+	Issuer                  string
+	Audience                string
+	AccessTokenExpiration   time.Duration
+	RefreshTokenExpiration  time.Duration
+	VerifyEmailExpiration   time.Duration
+	ResetPasswordExpiration time.Duration
+}
+
+// OIDCConfig configures one OpenID Connect login provider, declared as a
+// [OIDC "name"] section. Endpoints are discovered from Issuer unless
+// AuthURL, TokenURL and JWKSURL are all set, e.g. to point at a local mock
+// provider. Scopes is space separated; "openid email profile" by default.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       string
+	AuthURL      string
+	TokenURL     string
+	JWKSURL      string
+}
+
+// RateLimitConfig limits one route group, declared as a [RateLimit "name"]
+// section, to Requests per Period seconds with bursts of up to Burst
+// requests (Requests by default). KeyBy is "ip", "account" or "api_key";
+// only the comma-separated APIKeys get a bucket of their own.
+type RateLimitConfig struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+	KeyBy    string
+	APIKeys  string
+}
+
+// MailConfig configures outgoing email. Driver is "smtp", or "log" to print
+// messages instead of sending them. LinkBaseURL prefixes the links put in
+// verification and password reset emails.
+type MailConfig struct {
+	Driver      string
 	Host        string
-	PoolSize    int
-	DialTimeout time.Duration
-	IdleTimeout time.Duration
+	Port        string
+	Username    string
+	Password    string
+	From        string
+	LinkBaseURL string
+}
+
+// DeckConfig tunes swipe decks. Each deck holds up to Capacity venues
+// within RadiusKM, RestaurantRatio restaurants for every RecreationRatio
+// recreations. It is refilled in the background once fewer than
+// LowWatermark cards are left, and rebuilt when the account moves more than
+// RebuildDistanceKM away. Candidates are ranked by the weighted sum of
+// nearness, price fit and similarity to past likes. Expiration is in
+// minutes.
+type DeckConfig struct {
+	Size              int
+	Capacity          int
+	LowWatermark      int
+	RadiusKM          float64
+	RebuildDistanceKM float64
+	RestaurantRatio   int
+	RecreationRatio   int
+	DistanceWeight    float64
+	PriceWeight       float64
+	SimilarityWeight  float64
+	Expiration        time.Duration
+}
+
+// GroupConfig configures group swiping sessions. A session expires once
+// no member has used it for InactivityTimeout minutes. The last
+// EventLogSize events of a session are kept for clients resuming their
+// event stream, which gets a heartbeat every HeartbeatInterval seconds.
+type GroupConfig struct {
+	MaxMembers        int
+	InactivityTimeout time.Duration
+	EventLogSize      int
+	HeartbeatInterval time.Duration
+}
+
+type RedisConfig struct {
+	Host                 string
+	PoolSize             int
+	DialTimeout          time.Duration
+	IdleTimeout          time.Duration
+	BreakerThreshold     int
+	BreakerCooldown      time.Duration
+	DefaultExpiration    time.Duration
+	AccountExpiration    time.Duration
+	RestaurantExpiration time.Duration
+	RecreationExpiration time.Duration
 }
 
 type InMemoryConfig struct {
-	DefaultExpiration time.Duration
-	IntervalPurges    time.Duration
+	DefaultExpiration    time.Duration
+	IntervalPurges       time.Duration
+	StaleWhileRevalidate time.Duration
+	AccountExpiration    time.Duration
+	RestaurantExpiration time.Duration
+	RecreationExpiration time.Duration
+}
+
+// setDefaults fills the auth, replica, purge, deck, group and cache
+// settings that were left out of the config file. Per-namespace expirations
+// fall back to the section's DefaultExpiration.
+func (cfg *Config) setDefaults() {
+	if cfg.Auth.AccessTokenExpiration == 0 {
+		cfg.Auth.AccessTokenExpiration = 15
+	}
+
+	if cfg.Auth.RefreshTokenExpiration == 0 {
+		cfg.Auth.RefreshTokenExpiration = 30 * 24 * 60
+	}
+
+	if cfg.Auth.VerifyEmailExpiration == 0 {
+		cfg.Auth.VerifyEmailExpiration = 24 * 60
+	}
+
+	if cfg.Auth.ResetPasswordExpiration == 0 {
+		cfg.Auth.ResetPasswordExpiration = 60
+	}
+
+	if cfg.Server.ReplicaMaxLag == 0 {
+		cfg.Server.ReplicaMaxLag = 5
+	}
+
+	if cfg.Server.ReplicaCheckInterval == 0 {
+		cfg.Server.ReplicaCheckInterval = 5
+	}
+
+	if cfg.Server.PurgeInterval == 0 {
+		cfg.Server.PurgeInterval = 60
+	}
+
+	if cfg.Server.PurgeRetention == 0 {
+		cfg.Server.PurgeRetention = 30 * 24
+	}
+
+	cfg.Deck.setDefaults()
+
+	if cfg.Group.MaxMembers == 0 {
+		cfg.Group.MaxMembers = 10
+	}
+
+	if cfg.Group.InactivityTimeout == 0 {
+		cfg.Group.InactivityTimeout = 120
+	}
+
+	if cfg.Group.EventLogSize == 0 {
+		cfg.Group.EventLogSize = 100
+	}
+
+	if cfg.Group.HeartbeatInterval == 0 {
+		cfg.Group.HeartbeatInterval = 15
+	}
+
+	if cfg.Redis.DefaultExpiration == 0 {
+		cfg.Redis.DefaultExpiration = 3600
+	}
+
+	if cfg.Redis.BreakerThreshold == 0 {
+		cfg.Redis.BreakerThreshold = 5
+	}
+
+	if cfg.Redis.BreakerCooldown == 0 {
+		cfg.Redis.BreakerCooldown = 30
+	}
+
+	for _, expiration := range []*time.Duration{
+		&cfg.Redis.AccountExpiration,
+		&cfg.Redis.RestaurantExpiration,
+		&cfg.Redis.RecreationExpiration,
+	} {
+		if *expiration == 0 {
+			*expiration = cfg.Redis.DefaultExpiration
+		}
+	}
+
+	if cfg.InMemory.DefaultExpiration == 0 {
+		cfg.InMemory.DefaultExpiration = 15
+	}
+
+	if cfg.InMemory.IntervalPurges == 0 {
+		cfg.InMemory.IntervalPurges = 60
+	}
+
+	for _, expiration := range []*time.Duration{
+		&cfg.InMemory.AccountExpiration,
+		&cfg.InMemory.RestaurantExpiration,
+		&cfg.InMemory.RecreationExpiration,
+	} {
+		if *expiration == 0 {
+			*expiration = cfg.InMemory.DefaultExpiration
+		}
+	}
+}
+
+func (cfg *DeckConfig) setDefaults() {
+	if cfg.Size == 0 {
+		cfg.Size = 20
+	}
+
+	if cfg.Capacity == 0 {
+		cfg.Capacity = 100
+	}
+
+	if cfg.LowWatermark == 0 {
+		cfg.LowWatermark = cfg.Size
+	}
+
+	if cfg.RadiusKM == 0 {
+		cfg.RadiusKM = 25
+	}
+
+	if cfg.RebuildDistanceKM == 0 {
+		cfg.RebuildDistanceKM = 5
+	}
+
+	if cfg.RestaurantRatio == 0 && cfg.RecreationRatio == 0 {
+		cfg.RestaurantRatio, cfg.RecreationRatio = 1, 1
+	}
+
+	if cfg.DistanceWeight == 0 && cfg.PriceWeight == 0 && cfg.SimilarityWeight == 0 {
+		cfg.DistanceWeight, cfg.PriceWeight, cfg.SimilarityWeight = 0.5, 0.25, 0.25
+	}
+
+	if cfg.Expiration == 0 {
+		cfg.Expiration = 30
+	}
 }
 
 func InitConfig(configPaths ...string) (*Config, bool) {
@@ -60,5 +303,6 @@ func InitConfig(configPaths ...string) (*Config, bool) {
 		}
 	}
 
+	cfg.setDefaults()
 	return &cfg, ok
 }
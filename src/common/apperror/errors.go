@@ -1,39 +1,103 @@
 package apperror
 
-import "errors"
-
-var (
-	StatusBadRequest    = errors.New("Status Bad Request")
-	InternalServerError = errors.New("Internal Server Error")
-	DecodeError         = errors.New("Wrong request params format, see example in data")
-	AccountNotExists    = errors.New("Account not exists")
-	RecreationNotExists = errors.New("Recreation not exists")
-	RestaurantNotExists = errors.New("Restaurant not exists")
+import (
+	"errors"
+	"net/http"
 )
 
-type ErrorCodes struct {
-	HTTPcode   int
-	StatusCode int
+// Error is an application error. Code is the stable status_code clients see,
+// HTTPStatus the response status, and Message the text safe to show them.
+// Details carries per-field problems and Err the underlying cause, which is
+// only ever logged.
+type Error struct {
+	Code       int
+	HTTPStatus int
+	Message    string
+	Details    []FieldError
+	Err        error
 }
 
-var (
-	DefaultErrorCode  = ErrorCodes{400, 100000}
-	NotFoundErrorCode = ErrorCodes{0, 0}
-)
+// FieldError describes what is wrong with one request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(httpStatus, code int, message string) *Error {
+	return &Error{
+		Code:       code,
+		HTTPStatus: httpStatus,
+		Message:    message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any *Error with the same code, so errors.Is(err, AccountNotExists)
+// holds for wrapped or detailed copies of the sentinel too.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
 
-var errorCodes = map[error]ErrorCodes{
-	StatusBadRequest:    ErrorCodes{400, 100101},
-	InternalServerError: ErrorCodes{500, 100102},
-	DecodeError:         ErrorCodes{400, 100201},
-	AccountNotExists:    ErrorCodes{400, 200010},
-	RecreationNotExists: ErrorCodes{400, 300010},
-	RestaurantNotExists: ErrorCodes{400, 400010},
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
 }
 
-func GetErrorCodes(err error) ErrorCodes {
-	error := errorCodes[err]
-	if error == NotFoundErrorCode {
-		return DefaultErrorCode
+// WithDetails returns a copy of e carrying the given field errors.
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	detailed := *e
+	detailed.Details = append([]FieldError(nil), details...)
+	return &detailed
+}
+
+// From returns the *Error in err's chain. Anything else is unexpected and
+// becomes an InternalServerError wrapping it.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
 	}
-	return error
+	return InternalServerError.Wrap(err)
 }
+
+var (
+	StatusBadRequest      = New(http.StatusBadRequest, 100101, "Status Bad Request")
+	InternalServerError   = New(http.StatusInternalServerError, 100102, "Internal Server Error")
+	Unauthorized          = New(http.StatusUnauthorized, 100103, "Invalid Auth Token")
+	StatusConflict        = New(http.StatusConflict, 100104, "Status Conflict")
+	InvalidRefreshToken   = New(http.StatusUnauthorized, 100105, "Invalid or expired refresh token")
+	Forbidden             = New(http.StatusForbidden, 100106, "Not allowed to access this resource")
+	TooManyRequests       = New(http.StatusTooManyRequests, 100107, "Too many requests, slow down")
+	ServiceUnavailable    = New(http.StatusServiceUnavailable, 100108, "Service temporarily unavailable, try again later")
+	DecodeError           = New(http.StatusBadRequest, 100201, "Wrong request params format, see example in data")
+	ValidationError       = New(http.StatusUnprocessableEntity, 100202, "Request params are invalid, see errors")
+	AccountNotExists      = New(http.StatusNotFound, 200010, "Account not exists")
+	AccountExists         = New(http.StatusConflict, 200011, "Account with this email already exists")
+	InvalidCredentials    = New(http.StatusUnauthorized, 200012, "Email or password is wrong")
+	InvalidActionToken    = New(http.StatusBadRequest, 200013, "Token is invalid, expired or already used")
+	AccountNotVerified    = New(http.StatusForbidden, 200014, "Verify your email address first")
+	UnknownLoginProvider  = New(http.StatusNotFound, 200015, "Login provider is not configured")
+	LoginProviderFailed   = New(http.StatusUnauthorized, 200016, "Login with provider failed, try again")
+	AccountDeactivated    = New(http.StatusForbidden, 200017, "Account is deactivated, reactivate it to continue")
+	InvalidRole           = New(http.StatusBadRequest, 200018, "Role must be user, curator or admin")
+	RecreationNotExists   = New(http.StatusNotFound, 300010, "Recreation not exists")
+	RestaurantNotExists   = New(http.StatusNotFound, 400010, "Restaurant not exists")
+	SwipeNotExists        = New(http.StatusNotFound, 500010, "No swipe to undo")
+	GroupSessionNotExists = New(http.StatusNotFound, 600010, "Session not exists or has expired")
+	NotGroupMember        = New(http.StatusForbidden, 600011, "Join the session first")
+	GroupSessionFull      = New(http.StatusConflict, 600012, "Session is full")
+	VenueNotInDeck        = New(http.StatusNotFound, 600013, "Venue is not in this session's deck")
+)
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Purposes of action tokens. A token only redeems for the purpose it was
+// issued for.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+const keyActionToken = "auth:action_token:%s:%s"
+
+// ActionTokenStore issues time-limited, single-use tokens, such as the ones
+// mailed for email verification and password reset. Only a hash of each
+// token is kept in Redis.
+type ActionTokenStore struct {
+	pool *redigo.Pool
+}
+
+func NewActionTokenStore(pool *redigo.Pool) *ActionTokenStore {
+	return &ActionTokenStore{
+		pool: pool,
+	}
+}
+
+// Issue returns a new token for purpose that redeems to accountID and the
+// email it was sent to until expiration has passed.
+func (s *ActionTokenStore) Issue(purpose string, accountID int64, email string, expiration time.Duration) (string, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf(keyActionToken, purpose, hashSecret(token))
+
+	conn.Send("MULTI")
+	conn.Send("HMSET", key, "account_id", accountID, "email", email)
+	conn.Send("EXPIRE", key, int64(expiration/time.Second))
+	if _, err := conn.Do("EXEC"); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Redeem consumes token and returns the account and email it was issued
+// for. Callers should check the email is still the account's, since the
+// token only proves access to that address.
+func (s *ActionTokenStore) Redeem(purpose, token string) (accountID int64, email string, err error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf(keyActionToken, purpose, hashSecret(token))
+
+	conn.Send("MULTI")
+	conn.Send("HMGET", key, "account_id", "email")
+	conn.Send("DEL", key)
+	replies, err := redigo.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, "", err
+	}
+
+	values, err := redigo.Values(replies[0], nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if values[0] == nil {
+		return 0, "", apperror.InvalidActionToken
+	}
+
+	if accountID, err = redigo.Int64(values[0], nil); err != nil {
+		return 0, "", err
+	}
+
+	if email, err = redigo.String(values[1], nil); err != nil {
+		return 0, "", err
+	}
+
+	return accountID, email, nil
+}
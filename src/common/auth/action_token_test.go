@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/redistest"
+)
+
+func TestActionTokenRedeemsOnce(t *testing.T) {
+	pool, _ := redistest.NewPool(t)
+	tokens := NewActionTokenStore(pool)
+
+	token, err := tokens.Issue(PurposeVerifyEmail, 7, "jane@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountID, email, err := tokens.Redeem(PurposeVerifyEmail, token)
+	if err != nil {
+		t.Fatalf("Redeem() error = %v", err)
+	}
+	if accountID != 7 || email != "jane@example.com" {
+		t.Errorf("Redeem() = %d, %q, want 7, jane@example.com", accountID, email)
+	}
+
+	if _, _, err := tokens.Redeem(PurposeVerifyEmail, token); !errors.Is(err, apperror.InvalidActionToken) {
+		t.Errorf("second Redeem() error = %v, want InvalidActionToken", err)
+	}
+}
+
+func TestActionTokenRejects(t *testing.T) {
+	pool, mr := redistest.NewPool(t)
+	tokens := NewActionTokenStore(pool)
+
+	issue := func() string {
+		token, err := tokens.Issue(PurposeResetPassword, 7, "jane@example.com", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	otherPurpose := issue()
+	expired := issue()
+	mr.FastForward(time.Hour)
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+	}{
+		{"other purpose", PurposeVerifyEmail, otherPurpose},
+		{"expired", PurposeResetPassword, expired},
+		{"unknown", PurposeResetPassword, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tokens.Redeem(tt.purpose, tt.token); !errors.Is(err, apperror.InvalidActionToken) {
+				t.Errorf("Redeem() error = %v, want InvalidActionToken", err)
+			}
+		})
+	}
+}
+
+func TestActionTokenStoresOnlyHashes(t *testing.T) {
+	pool, mr := redistest.NewPool(t)
+	tokens := NewActionTokenStore(pool)
+
+	token, err := tokens.Issue(PurposeVerifyEmail, 7, "jane@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range mr.Keys() {
+		if key == "auth:action_token:"+PurposeVerifyEmail+":"+token {
+			t.Errorf("token stored in the clear as %s", key)
+		}
+	}
+}
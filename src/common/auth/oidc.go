@@ -0,0 +1,314 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/apperror"
+	oidc "github.com/coreos/go-oidc"
+	redigo "github.com/gomodule/redigo/redis"
+	"golang.org/x/oauth2"
+)
+
+const (
+	keyOIDCState = "auth:oidc_state:%s"
+
+	// OIDCBindingCookie ties a login to the browser that started it, so a
+	// callback carrying someone else's code and state is refused.
+	OIDCBindingCookie = "oidc_binding"
+
+	// oidcStateExpiration bounds how long a user may take on the provider's
+	// login page.
+	oidcStateExpiration = 10 * time.Minute
+)
+
+// Identity is what a provider asserts about the user after login.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type oidcProvider struct {
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcState is kept in Redis between the redirect to the provider and its
+// callback.
+type oidcState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	Binding      string `json:"binding"`
+}
+
+// OIDC runs the authorization code flow with PKCE against the providers
+// from the [OIDC "name"] config sections.
+type OIDC struct {
+	pool      *redigo.Pool
+	providers map[string]*oidcProvider
+}
+
+// NewOIDC sets up every configured provider. A provider whose discovery
+// fails is logged and left out rather than stopping startup.
+func NewOIDC(pool *redigo.Pool, cfgs map[string]*config.OIDCConfig) *OIDC {
+	o := &OIDC{
+		pool:      pool,
+		providers: make(map[string]*oidcProvider),
+	}
+
+	for name, cfg := range cfgs {
+		if cfg.ClientID == "" {
+			continue
+		}
+
+		provider, err := newOIDCProvider(cfg)
+		if err != nil {
+			log.Printf("oidc provider %s disabled: %v", name, err)
+			continue
+		}
+
+		o.providers[name] = provider
+	}
+
+	return o
+}
+
+func newOIDCProvider(cfg *config.OIDCConfig) (*oidcProvider, error) {
+	ctx := context.Background()
+	verifierConfig := &oidc.Config{ClientID: cfg.ClientID}
+
+	scopes := strings.Fields(cfg.Scopes)
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	var (
+		endpoint oauth2.Endpoint
+		verifier *oidc.IDTokenVerifier
+	)
+	if cfg.AuthURL != "" && cfg.TokenURL != "" && cfg.JWKSURL != "" {
+		endpoint = oauth2.Endpoint{AuthURL: cfg.AuthURL, TokenURL: cfg.TokenURL}
+		verifier = oidc.NewVerifier(cfg.Issuer, oidc.NewRemoteKeySet(ctx, cfg.JWKSURL), verifierConfig)
+	} else {
+		provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		endpoint = provider.Endpoint()
+		verifier = provider.Verifier(verifierConfig)
+	}
+
+	return &oidcProvider{
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     endpoint,
+			Scopes:       scopes,
+		},
+		verifier: verifier,
+	}, nil
+}
+
+// Start begins a login with provider and returns the URL to send the user
+// to, along with the binding cookie to set on the browser. The state,
+// nonce, PKCE verifier and a hash of the binding are kept until the
+// callback.
+func (o *OIDC) Start(providerName string) (string, *http.Cookie, error) {
+	provider, found := o.providers[providerName]
+	if !found {
+		return "", nil, apperror.UnknownLoginProvider
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return "", nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	codeVerifier, err := randomString(32)
+	if err != nil {
+		return "", nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	binding, err := randomString(32)
+	if err != nil {
+		return "", nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	data, err := json.Marshal(oidcState{
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		Binding:      hashBinding(binding),
+	})
+	if err != nil {
+		return "", nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	conn := o.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", fmt.Sprintf(keyOIDCState, state), data, "EX", int64(oidcStateExpiration/time.Second)); err != nil {
+		return "", nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	authorizationURL := provider.oauth.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	return authorizationURL, provider.bindingCookie(binding, int(oidcStateExpiration/time.Second)), nil
+}
+
+// Finish completes a login from the provider's callback: it consumes the
+// state, checks binding is the cookie set by Start, exchanges code for
+// tokens and verifies the ID token.
+func (o *OIDC) Finish(ctx context.Context, providerName, state, code, binding string) (*Identity, error) {
+	provider, found := o.providers[providerName]
+	if !found {
+		return nil, apperror.UnknownLoginProvider
+	}
+
+	saved, err := o.takeState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if saved.Provider != providerName {
+		return nil, apperror.LoginProviderFailed.Wrap(errors.New("state issued for another provider"))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashBinding(binding)), []byte(saved.Binding)) != 1 {
+		return nil, apperror.LoginProviderFailed.Wrap(errors.New("state started in another browser"))
+	}
+
+	token, err := provider.oauth.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", saved.CodeVerifier))
+	if err != nil {
+		return nil, apperror.LoginProviderFailed.Wrap(err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, apperror.LoginProviderFailed.Wrap(errors.New("token response has no id_token"))
+	}
+
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, apperror.LoginProviderFailed.Wrap(err)
+	}
+
+	if idToken.Nonce != saved.Nonce {
+		return nil, apperror.LoginProviderFailed.Wrap(errors.New("id_token nonce mismatch"))
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, apperror.LoginProviderFailed.Wrap(err)
+	}
+
+	return &Identity{
+		Provider:      providerName,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// ClearBinding returns the cookie that removes the binding of a login with
+// provider once its callback is handled.
+func (o *OIDC) ClearBinding(providerName string) *http.Cookie {
+	provider, found := o.providers[providerName]
+	if !found {
+		return nil
+	}
+
+	return provider.bindingCookie("", -1)
+}
+
+// bindingCookie is only sent to the callback path, and SameSite=Lax still
+// lets it through on the provider's top-level redirect back.
+func (provider *oidcProvider) bindingCookie(value string, maxAge int) *http.Cookie {
+	path := "/"
+	secure := false
+	if redirectURL, err := url.Parse(provider.oauth.RedirectURL); err == nil {
+		if redirectURL.Path != "" {
+			path = redirectURL.Path
+		}
+		secure = redirectURL.Scheme == "https"
+	}
+
+	return &http.Cookie{
+		Name:     OIDCBindingCookie,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func hashBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
+
+// takeState reads and deletes state, so each one completes a single login.
+func (o *OIDC) takeState(state string) (*oidcState, error) {
+	conn := o.pool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf(keyOIDCState, state)
+
+	conn.Send("MULTI")
+	conn.Send("GET", key)
+	conn.Send("DEL", key)
+	replies, err := redigo.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	data, err := redigo.Bytes(replies[0], nil)
+	if err == redigo.ErrNil {
+		return nil, apperror.LoginProviderFailed.Wrap(errors.New("unknown or expired state"))
+	}
+
+	if err != nil {
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	saved := &oidcState{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return saved, nil
+}
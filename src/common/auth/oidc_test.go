@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/redistest"
+	"golang.org/x/oauth2"
+)
+
+// newTestOIDC has one provider, "test", whose token endpoint counts the
+// exchanges it is asked for and refuses them all.
+func newTestOIDC(t *testing.T) (*OIDC, *int32) {
+	pool, _ := redistest.NewPool(t)
+
+	exchanges := new(int32)
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(exchanges, 1)
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	return &OIDC{
+		pool: pool,
+		providers: map[string]*oidcProvider{
+			"test": {oauth: &oauth2.Config{
+				ClientID:    "client",
+				RedirectURL: "https://app.example.com/api/v1/auth/oidc/test/callback",
+				Endpoint:    oauth2.Endpoint{AuthURL: "https://idp.example.com/auth", TokenURL: tokenServer.URL},
+			}},
+		},
+	}, exchanges
+}
+
+func startTestLogin(t *testing.T, o *OIDC) (string, *http.Cookie) {
+	authorizationURL, binding, err := o.Start("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Query().Get("state"), binding
+}
+
+func TestOIDCBindingCookie(t *testing.T) {
+	o, _ := newTestOIDC(t)
+	_, binding := startTestLogin(t, o)
+
+	if binding.Name != OIDCBindingCookie || binding.Value == "" {
+		t.Fatalf("Start() cookie = %+v, want a %s value", binding, OIDCBindingCookie)
+	}
+	if !binding.HttpOnly || !binding.Secure || binding.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Start() cookie = %+v, want HttpOnly, Secure and SameSite=Lax", binding)
+	}
+	if binding.Path != "/api/v1/auth/oidc/test/callback" {
+		t.Errorf("Start() cookie path = %q, want the callback path", binding.Path)
+	}
+
+	if clear := o.ClearBinding("test"); clear.MaxAge >= 0 || clear.Path != binding.Path {
+		t.Errorf("ClearBinding() = %+v, want an expired cookie on %s", clear, binding.Path)
+	}
+}
+
+// A callback opened in another browser, with an attacker's code and state,
+// must not reach the token endpoint.
+func TestOIDCFinishRequiresBinding(t *testing.T) {
+	o, exchanges := newTestOIDC(t)
+
+	for _, binding := range []string{"", "someone else's"} {
+		state, _ := startTestLogin(t, o)
+		if _, err := o.Finish(context.Background(), "test", state, "code", binding); !errors.Is(err, apperror.LoginProviderFailed) {
+			t.Errorf("Finish() with binding %q error = %v, want LoginProviderFailed", binding, err)
+		}
+	}
+
+	if atomic.LoadInt32(exchanges) != 0 {
+		t.Error("token endpoint called for unbound callbacks")
+	}
+
+	state, binding := startTestLogin(t, o)
+	o.Finish(context.Background(), "test", state, "code", binding.Value)
+	bound := atomic.LoadInt32(exchanges)
+	if bound == 0 {
+		t.Error("token endpoint not called for the bound callback")
+	}
+
+	// The state is spent even though the exchange failed.
+	o.Finish(context.Background(), "test", state, "code", binding.Value)
+	if atomic.LoadInt32(exchanges) != bound {
+		t.Error("token endpoint called again after reusing the state")
+	}
+}
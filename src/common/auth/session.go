@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/cache"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// Both keys are hash-tagged with the account ID, so a session and its
+	// account's session set share a cluster slot and can be used together in
+	// one script or transaction.
+	keySession         = "auth:session:{%d}:%s"
+	keyAccountSessions = "auth:account_sessions:{%d}"
+)
+
+// rotateScript swaps the session's refresh token hash for a new one if the
+// presented hash is the current one. A stale hash means the token was
+// already rotated, so someone is replaying it and session ARGV[4] is
+// deleted and dropped from the account's session set in KEYS[2]. On
+// success the set is kept alive as long as the session so RevokeAll still
+// finds it. Returns the account ID on success, 0 for an unknown session, -1
+// on reuse.
+var rotateScript = redigo.NewScript(2, `
+local current = redis.call('HGET', KEYS[1], 'token')
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	redis.call('SREM', KEYS[2], ARGV[4])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'token', ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+return tonumber(redis.call('HGET', KEYS[1], 'account_id'))
+`)
+
+// createScript stores session KEYS[1] of account ARGV[1] with refresh token
+// hash ARGV[2], created at ARGV[3], and adds it as ARGV[5] to the account's
+// session set in KEYS[2]. Both expire after ARGV[4] seconds.
+var createScript = redigo.NewScript(2, `
+redis.call('HMSET', KEYS[1], 'account_id', ARGV[1], 'token', ARGV[2], 'created_at', ARGV[3])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('SADD', KEYS[2], ARGV[5])
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+return 1
+`)
+
+// revokeScript deletes session KEYS[1] and drops it as ARGV[1] from the
+// account's session set in KEYS[2].
+var revokeScript = redigo.NewScript(2, `
+redis.call('DEL', KEYS[1])
+redis.call('SREM', KEYS[2], ARGV[1])
+return 1
+`)
+
+// SessionStore keeps login sessions in Redis. A session lives as long as its
+// refresh token, which is rotated on every use. Access tokens carry the
+// session ID, so deleting the session revokes them too.
+//
+// Session IDs have the form "<account ID>:<random>" so the account's keys
+// can be found from the session alone. Refresh tokens have the form
+// "<session ID>.<secret>"; only a hash of the secret is stored.
+//
+// Commands go through the cache store's breaker, so while Redis is down
+// every method fails at once with a ServiceUnavailable error wrapping
+// cache.ErrUnavailable.
+type SessionStore struct {
+	store      *cache.Store
+	expiration time.Duration
+}
+
+func NewSessionStore(store *cache.Store, expiration time.Duration) *SessionStore {
+	return &SessionStore{
+		store:      store,
+		expiration: expiration,
+	}
+}
+
+// Create starts a session for accountID and returns its ID and first
+// refresh token.
+func (s *SessionStore) Create(accountID int64) (sessionID, refreshToken string, err error) {
+	random, err := randomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	sessionID = fmt.Sprintf("%d:%s", accountID, random)
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionKey, accountKey := sessionKeys(accountID, random)
+	if _, err := s.store.Eval(createScript, sessionKey, accountKey, accountID, hashSecret(secret), time.Now().Unix(), int64(s.expiration/time.Second), sessionID); err != nil {
+		return "", "", storeError(err)
+	}
+
+	return sessionID, sessionID + "." + secret, nil
+}
+
+// Rotate redeems refreshToken and returns the session's account and ID with
+// a new refresh token. The old token stops working; presenting it again
+// revokes the whole session.
+func (s *SessionStore) Rotate(refreshToken string) (accountID int64, sessionID, newRefreshToken string, err error) {
+	sessionID, secret, ok := splitRefreshToken(refreshToken)
+	if !ok {
+		return 0, "", "", apperror.InvalidRefreshToken
+	}
+
+	sessionKey, accountKey, ok := parseSessionID(sessionID)
+	if !ok {
+		return 0, "", "", apperror.InvalidRefreshToken
+	}
+
+	newSecret, err := randomString(32)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	result, err := redigo.Int64(s.store.Eval(rotateScript, sessionKey, accountKey, hashSecret(secret), hashSecret(newSecret), int64(s.expiration/time.Second), sessionID))
+	if err != nil {
+		return 0, "", "", storeError(err)
+	}
+
+	switch {
+	case result == 0:
+		return 0, "", "", apperror.InvalidRefreshToken
+	case result < 0:
+		return 0, "", "", apperror.InvalidRefreshToken.Wrap(fmt.Errorf("refresh token reused, session %s revoked", sessionID))
+	}
+
+	return result, sessionID, sessionID + "." + newSecret, nil
+}
+
+// Active reports whether sessionID has not been revoked or expired.
+func (s *SessionStore) Active(sessionID string) (bool, error) {
+	sessionKey, _, ok := parseSessionID(sessionID)
+	if !ok {
+		return false, nil
+	}
+
+	active, err := redigo.Bool(s.store.Do("EXISTS", sessionKey))
+	if err != nil {
+		return false, storeError(err)
+	}
+
+	return active, nil
+}
+
+// Revoke ends one session of accountID. A session of another account is
+// left alone.
+func (s *SessionStore) Revoke(accountID int64, sessionID string) error {
+	sessionKey, accountKey, ok := parseSessionID(sessionID)
+	if !ok || accountKey != fmt.Sprintf(keyAccountSessions, accountID) {
+		return nil
+	}
+
+	_, err := s.store.Eval(revokeScript, sessionKey, accountKey, sessionID)
+	return storeError(err)
+}
+
+// RevokeAll ends every session of accountID, logging it out on all devices.
+func (s *SessionStore) RevokeAll(accountID int64) error {
+	accountKey := fmt.Sprintf(keyAccountSessions, accountID)
+	sessionIDs, err := redigo.Strings(s.store.Do("SMEMBERS", accountKey))
+	if err != nil {
+		return storeError(err)
+	}
+
+	keys := make([]interface{}, 0, len(sessionIDs)+1)
+	keys = append(keys, accountKey)
+	for _, sessionID := range sessionIDs {
+		if sessionKey, _, ok := parseSessionID(sessionID); ok {
+			keys = append(keys, sessionKey)
+		}
+	}
+
+	_, err = s.store.Do("DEL", keys...)
+	return storeError(err)
+}
+
+// storeError tells an outage apart from other failures, so handlers answer
+// 503 rather than 500 while Redis is unreachable.
+func storeError(err error) error {
+	if err == cache.ErrUnavailable {
+		return apperror.ServiceUnavailable.Wrap(err)
+	}
+	return err
+}
+
+func splitRefreshToken(refreshToken string) (sessionID, secret string, ok bool) {
+	parts := strings.SplitN(refreshToken, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// parseSessionID returns the keys of sessionID and of its account's session
+// set, or false if it isn't of the form "<account ID>:<random>".
+func parseSessionID(sessionID string) (sessionKey, accountKey string, ok bool) {
+	parts := strings.SplitN(sessionID, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", false
+	}
+
+	accountID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", "", false
+	}
+
+	sessionKey, accountKey = sessionKeys(accountID, parts[1])
+	return sessionKey, accountKey, true
+}
+
+func sessionKeys(accountID int64, random string) (sessionKey, accountKey string) {
+	return fmt.Sprintf(keySession, accountID, random), fmt.Sprintf(keyAccountSessions, accountID)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
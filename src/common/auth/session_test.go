@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/redistest"
+)
+
+func newTestSessionStore(t *testing.T) (*SessionStore, *miniredis.Miniredis) {
+	pool, mr := redistest.NewPool(t)
+	return NewSessionStore(cache.NewStore(pool, time.Minute, 1, time.Minute), time.Hour), mr
+}
+
+func TestSessionRotate(t *testing.T) {
+	sessions, _ := newTestSessionStore(t)
+
+	sessionID, refreshToken, err := sessions.Create(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountID, rotatedID, newRefreshToken, err := sessions.Rotate(refreshToken)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if accountID != 7 || rotatedID != sessionID || newRefreshToken == refreshToken {
+		t.Errorf("Rotate() = %d, %q, %q, want account 7, session %q and a new token", accountID, rotatedID, newRefreshToken, sessionID)
+	}
+
+	if _, _, _, err := sessions.Rotate(newRefreshToken); err != nil {
+		t.Errorf("Rotate() of the new token error = %v", err)
+	}
+}
+
+func TestSessionRotateReuseRevokes(t *testing.T) {
+	sessions, mr := newTestSessionStore(t)
+
+	sessionID, refreshToken, err := sessions.Create(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, newRefreshToken, err := sessions.Rotate(refreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := sessions.Rotate(refreshToken); !errors.Is(err, apperror.InvalidRefreshToken) {
+		t.Fatalf("Rotate() of a used token error = %v, want InvalidRefreshToken", err)
+	}
+
+	if active, _ := sessions.Active(sessionID); active {
+		t.Error("session still active after its refresh token was reused")
+	}
+
+	if members, _ := mr.Members(fmt.Sprintf(keyAccountSessions, 7)); len(members) != 0 {
+		t.Errorf("account sessions = %v after reuse, want the revoked session dropped", members)
+	}
+
+	if _, _, _, err := sessions.Rotate(newRefreshToken); !errors.Is(err, apperror.InvalidRefreshToken) {
+		t.Errorf("Rotate() in a revoked session error = %v, want InvalidRefreshToken", err)
+	}
+}
+
+func TestSessionRotateRejectsMalformedTokens(t *testing.T) {
+	sessions, _ := newTestSessionStore(t)
+
+	for _, token := range []string{"", "nodot", ".secret", "session.", "unknown.secret", "7:unknown.secret", "x:unknown.secret"} {
+		if _, _, _, err := sessions.Rotate(token); !errors.Is(err, apperror.InvalidRefreshToken) {
+			t.Errorf("Rotate(%q) error = %v, want InvalidRefreshToken", token, err)
+		}
+	}
+}
+
+// Rotating must keep the account's session set alive as long as the
+// session, or RevokeAll would miss sessions that outlived their first
+// expiration.
+func TestSessionRotateRefreshesAccountSet(t *testing.T) {
+	sessions, mr := newTestSessionStore(t)
+
+	sessionID, refreshToken, err := sessions.Create(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr.FastForward(50 * time.Minute)
+	if _, _, _, err := sessions.Rotate(refreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	accountKey := fmt.Sprintf(keyAccountSessions, 7)
+	if got := mr.TTL(accountKey); got != time.Hour {
+		t.Errorf("account set TTL = %v, want %v", got, time.Hour)
+	}
+
+	mr.FastForward(50 * time.Minute)
+	if err := sessions.RevokeAll(7); err != nil {
+		t.Fatal(err)
+	}
+
+	if active, _ := sessions.Active(sessionID); active {
+		t.Error("RevokeAll left a rotated session active")
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	sessions, _ := newTestSessionStore(t)
+
+	first, _, err := sessions.Create(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := sessions.Create(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := sessions.Create(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessions.Revoke(7, first); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		sessionID string
+		want      bool
+	}{
+		{first, false},
+		{second, true},
+		{other, true},
+	} {
+		if active, _ := sessions.Active(tt.sessionID); active != tt.want {
+			t.Errorf("Active(%s) = %t after Revoke, want %t", tt.sessionID, active, tt.want)
+		}
+	}
+
+	if err := sessions.RevokeAll(7); err != nil {
+		t.Fatal(err)
+	}
+
+	if active, _ := sessions.Active(second); active {
+		t.Error("session still active after RevokeAll")
+	}
+	if active, _ := sessions.Active(other); !active {
+		t.Error("RevokeAll ended another account's session")
+	}
+}
+
+func TestSessionStoreFailsFastWithoutRedis(t *testing.T) {
+	sessions, mr := newTestSessionStore(t)
+	mr.Close()
+
+	// The first failure opens the breaker; later calls don't dial at all.
+	sessions.Active("7:session")
+
+	if _, err := sessions.Active("7:session"); !errors.Is(err, cache.ErrUnavailable) || !errors.Is(err, apperror.ServiceUnavailable) {
+		t.Errorf("Active() error = %v, want ServiceUnavailable wrapping ErrUnavailable", err)
+	}
+	if _, _, err := sessions.Create(7); !errors.Is(err, apperror.ServiceUnavailable) {
+		t.Errorf("Create() error = %v, want ServiceUnavailable", err)
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/model"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Claims are the claims carried by an access token. The account ID travels
+// in the standard "sub" claim and is parsed into AccountID on verification.
+// Role and EmailVerified reflect the account when the token was issued, and
+// SessionID names the login session the token belongs to.
+type Claims struct {
+	AccountID     int64  `json:"-"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	SessionID     string `json:"sid"`
+	jwt.StandardClaims
+}
+
+// Tokenizer signs and verifies access tokens with the algorithm and keys
+// from the [Auth] config section.
+type Tokenizer struct {
+	method     jwt.SigningMethod
+	signKey    interface{}
+	verifyKey  interface{}
+	issuer     string
+	audience   string
+	expiration time.Duration
+}
+
+func NewTokenizer(cfg config.AuthConfig) (*Tokenizer, error) {
+	t := &Tokenizer{
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		expiration: cfg.AccessTokenExpiration * time.Minute,
+	}
+
+	switch cfg.Algorithm {
+	case "HS256":
+		if cfg.HMACSecret == "" {
+			return nil, errors.New("auth: HS256 needs HMACSecret")
+		}
+		t.method = jwt.SigningMethodHS256
+		t.signKey = []byte(cfg.HMACSecret)
+		t.verifyKey = t.signKey
+
+	case "RS256":
+		t.method = jwt.SigningMethodRS256
+
+		publicPEM, err := ioutil.ReadFile(cfg.RSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading RSAPublicKeyFile: %v", err)
+		}
+		if t.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+			return nil, fmt.Errorf("auth: parsing RSAPublicKeyFile: %v", err)
+		}
+
+		// Instances that only verify tokens don't need the private key.
+		if cfg.RSAPrivateKeyFile != "" {
+			privatePEM, err := ioutil.ReadFile(cfg.RSAPrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("auth: reading RSAPrivateKeyFile: %v", err)
+			}
+			if t.signKey, err = jwt.ParseRSAPrivateKeyFromPEM(privatePEM); err != nil {
+				return nil, fmt.Errorf("auth: parsing RSAPrivateKeyFile: %v", err)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("auth: unsupported Algorithm %q, use HS256 or RS256", cfg.Algorithm)
+	}
+
+	return t, nil
+}
+
+// Issue signs an access token for account within session sessionID.
+func (t *Tokenizer) Issue(account *model.Account, sessionID string) (string, *Claims, error) {
+	if t.signKey == nil {
+		return "", nil, errors.New("auth: no signing key configured")
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		AccountID:     account.AccountID,
+		Role:          account.Role,
+		EmailVerified: account.IsVerified(),
+		SessionID:     sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.FormatInt(account.AccountID, 10),
+			Issuer:    t.issuer,
+			Audience:  t.audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(t.expiration).Unix(),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(t.method, claims).SignedString(t.signKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, claims, nil
+}
+
+// Verify checks the token's signature, algorithm, expiry, issuer and
+// audience, and returns its claims.
+func (t *Tokenizer) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != t.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing algorithm %s", token.Method.Alg())
+		}
+		return t.verifyKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(t.issuer, true) {
+		return nil, errors.New("unexpected token issuer")
+	}
+
+	if !claims.VerifyAudience(t.audience, true) {
+		return nil, errors.New("unexpected token audience")
+	}
+
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("token has no expiry")
+	}
+
+	if claims.SessionID == "" {
+		return nil, errors.New("token has no session")
+	}
+
+	if claims.AccountID, err = strconv.ParseInt(claims.Subject, 10, 64); err != nil {
+		return nil, errors.New("token subject is not an account ID")
+	}
+
+	return claims, nil
+}
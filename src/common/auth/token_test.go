@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/model"
+)
+
+func newTestTokenizer(t *testing.T, cfg config.AuthConfig) *Tokenizer {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = "HS256"
+	}
+	if cfg.HMACSecret == "" {
+		cfg.HMACSecret = "test secret"
+	}
+	if cfg.AccessTokenExpiration == 0 {
+		cfg.AccessTokenExpiration = 15
+	}
+
+	tokenizer, err := NewTokenizer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenizer
+}
+
+func TestTokenizerRoundTrip(t *testing.T) {
+	tokenizer := newTestTokenizer(t, config.AuthConfig{Issuer: "accounts", Audience: "app"})
+
+	verifiedAt := time.Now()
+	account := &model.Account{AccountID: 42, Role: model.RoleCurator, VerifiedAt: &verifiedAt}
+
+	token, _, err := tokenizer.Issue(account, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := tokenizer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	if claims.AccountID != 42 || claims.Role != model.RoleCurator || !claims.EmailVerified || claims.SessionID != "session" {
+		t.Errorf("Verify() = %+v, want the issued claims", claims)
+	}
+}
+
+func TestTokenizerRejects(t *testing.T) {
+	tokenizer := newTestTokenizer(t, config.AuthConfig{Issuer: "accounts", Audience: "app"})
+	account := &model.Account{AccountID: 42, Role: model.RoleUser}
+
+	issue := func(tokenizer *Tokenizer) string {
+		token, _, err := tokenizer.Issue(account, "session")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	valid := issue(tokenizer)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"other secret", issue(newTestTokenizer(t, config.AuthConfig{Issuer: "accounts", Audience: "app", HMACSecret: "other"}))},
+		{"other issuer", issue(newTestTokenizer(t, config.AuthConfig{Issuer: "elsewhere", Audience: "app"}))},
+		{"other audience", issue(newTestTokenizer(t, config.AuthConfig{Issuer: "accounts", Audience: "other"}))},
+		{"expired", issue(newTestTokenizer(t, config.AuthConfig{Issuer: "accounts", Audience: "app", AccessTokenExpiration: -1}))},
+		{"unsigned", parts[0] + "." + parts[1] + "."},
+		{"garbage", "not a token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := tokenizer.Verify(tt.token); err == nil {
+				t.Errorf("Verify() = %+v, want an error", claims)
+			}
+		})
+	}
+}
+
+func TestNewTokenizerChecksConfig(t *testing.T) {
+	for _, cfg := range []config.AuthConfig{
+		{Algorithm: "HS256"},
+		{Algorithm: "none"},
+		{Algorithm: "RS256", RSAPublicKeyFile: "/nonexistent"},
+	} {
+		if _, err := NewTokenizer(cfg); err == nil {
+			t.Errorf("NewTokenizer(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package cache
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	gocache "github.com/patrickmn/go-cache"
+	"golang.org/x/sync/singleflight"
+)
+
+// Expirations a namespace falls back to when given none. Zero can't mean
+// "never": stale-while-revalidate would see every entry as stale, and Redis
+// would drop the hash as soon as it is written.
+const (
+	defaultLocalExpiration  = 15 * time.Minute
+	defaultRemoteExpiration = time.Hour
+)
+
+// Store owns the Redis pool shared by every cache namespace of the process.
+// Redis is treated as optional: failed commands are logged and counted, and
+// after breakerThreshold consecutive failures commands are skipped for
+// breakerCooldown, leaving the in-memory tier in front of the database.
+type Store struct {
+	pool          *redigo.Pool
+	purgeInterval time.Duration
+	instanceID    string
+	breaker       *breaker
+
+	mu         sync.RWMutex
+	namespaces map[string]*Namespace
+}
+
+func NewStore(pool *redigo.Pool, purgeInterval time.Duration, breakerThreshold int, breakerCooldown time.Duration) *Store {
+	return &Store{
+		pool:          pool,
+		purgeInterval: purgeInterval,
+		instanceID:    newInstanceID(),
+		breaker:       newBreaker(breakerThreshold, breakerCooldown),
+		namespaces:    make(map[string]*Namespace),
+	}
+}
+
+// Do sends command behind the breaker; see Eval.
+func (s *Store) Do(command string, args ...interface{}) (reply interface{}, err error) {
+	return s.run(func(conn redigo.Conn) (interface{}, error) {
+		return conn.Do(command, args...)
+	})
+}
+
+// Eval runs script behind the same breaker as the cache's own commands, so
+// other users of the Redis pool fail fast with ErrUnavailable while it is
+// down instead of waiting on the dial timeout.
+func (s *Store) Eval(script *redigo.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	return s.run(func(conn redigo.Conn) (interface{}, error) {
+		return script.Do(conn, keysAndArgs...)
+	})
+}
+
+func (s *Store) run(call func(conn redigo.Conn) (interface{}, error)) (reply interface{}, err error) {
+	if !s.breaker.allow() {
+		metrics.Add(metricCircuitOpen, 1)
+		return nil, ErrUnavailable
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	reply, err = call(conn)
+	if _, isReplyError := err.(redigo.Error); err != nil && !isReplyError {
+		metrics.Add(metricRedisError, 1)
+		s.breaker.failure()
+		return nil, err
+	}
+
+	if s.breaker.success() {
+		s.recover()
+	}
+	return reply, err
+}
+
+// recover runs when Redis answers again after the breaker was open. Writes
+// that happened meanwhile could not invalidate Redis, so every namespace is
+// dropped rather than trusting what it still holds.
+func (s *Store) recover() {
+	log.Println("redis reachable again, dropping cached namespaces")
+
+	s.mu.RLock()
+	keys := make([]interface{}, 0, len(s.namespaces))
+	for name := range s.namespaces {
+		keys = append(keys, name)
+	}
+	s.mu.RUnlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	if _, err := s.Do("DEL", keys...); err != nil {
+		log.Println(err)
+	}
+}
+
+// Namespace returns a two-tier cache: an in-memory L1 in front of a Redis
+// hash named after the namespace. Expirations that aren't positive are
+// replaced by the defaults.
+func (s *Store) Namespace(name string, localExpiration, remoteExpiration time.Duration, serializer Serializer) *Namespace {
+	if localExpiration <= 0 {
+		localExpiration = defaultLocalExpiration
+	}
+
+	if remoteExpiration <= 0 {
+		remoteExpiration = defaultRemoteExpiration
+	}
+
+	namespace := &Namespace{
+		name:             name,
+		store:            s,
+		local:            gocache.New(localExpiration, s.purgeInterval),
+		localExpiration:  localExpiration,
+		remoteExpiration: remoteExpiration,
+		serializer:       serializer,
+		pending:          make(map[string]*pendingLoad),
+	}
+
+	s.mu.Lock()
+	s.namespaces[name] = namespace
+	s.mu.Unlock()
+
+	return namespace
+}
+
+// Namespace caches values under a single Redis hash, one field per entry.
+//
+// The in-memory tier hands every caller the same value, so values returned
+// by GetOrLoad are shared and must be treated as read-only; copy one before
+// changing it.
+type Namespace struct {
+	name             string
+	store            *Store
+	local            *gocache.Cache
+	localExpiration  time.Duration
+	remoteExpiration time.Duration
+	staleExpiration  time.Duration
+	serializer       Serializer
+
+	loads      singleflight.Group
+	refreshing sync.Map
+
+	mu      sync.Mutex
+	pending map[string]*pendingLoad
+}
+
+// pendingLoad is a lookup of one field in flight. Invalidating the field
+// supersedes it: its callers still get the result, but it isn't cached, or
+// a value read before the invalidation would be written back after it.
+type pendingLoad struct {
+	superseded bool
+}
+
+// entry is what the in-memory tier holds. It is kept staleExpiration past
+// freshUntil so it can still be served while a refresh is in flight.
+type entry struct {
+	value      interface{}
+	freshUntil time.Time
+}
+
+// WithStaleWhileRevalidate keeps in-memory entries for staleExpiration after
+// they expire. Within that window GetOrLoad returns the expired value at once
+// and refreshes it in the background, so readers never wait on the loader.
+// Zero disables it.
+func (n *Namespace) WithStaleWhileRevalidate(staleExpiration time.Duration) *Namespace {
+	n.staleExpiration = staleExpiration
+	return n
+}
+
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+// GetOrLoad returns the value cached for field, looking in memory first and
+// Redis second. On a miss in both, load is called and its result is written
+// back to both tiers. Concurrent misses on the same field share one lookup,
+// so load runs at most once per field at a time within the process. The
+// value is shared with other callers and must not be modified.
+func (n *Namespace) GetOrLoad(field string, load func() (interface{}, error)) (interface{}, error) {
+	if item, found := n.local.Get(field); found {
+		metrics.Add(metricLocalHit, 1)
+		cached := item.(*entry)
+		if n.staleExpiration > 0 && time.Now().After(cached.freshUntil) {
+			n.refresh(field, load)
+		}
+		return cached.value, nil
+	}
+
+	value, err, _ := n.loads.Do(field, func() (interface{}, error) {
+		pending := n.begin(field)
+		defer n.end(field, pending)
+
+		return n.fetch(field, pending, load)
+	})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// fetch reads field from Redis, falling back to load when it is missing,
+// unreadable or Redis can't be reached.
+func (n *Namespace) fetch(field string, pending *pendingLoad, load func() (interface{}, error)) (interface{}, error) {
+	data, err := redigo.Bytes(n.store.Do("HGET", n.name, field))
+	if err == nil {
+		value, err := n.serializer.Unmarshal(data)
+		if err == nil {
+			metrics.Add(metricRemoteHit, 1)
+			if n.current(pending) {
+				n.setLocal(field, value)
+			}
+			return value, nil
+		}
+		log.Println(err)
+	} else if err != redigo.ErrNil {
+		log.Println(err)
+	}
+
+	metrics.Add(metricMiss, 1)
+	value, err := load()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if n.current(pending) {
+		n.set(field, value)
+	}
+	return value, nil
+}
+
+// refresh reloads a stale field in the background unless a refresh of that
+// field is already running.
+func (n *Namespace) refresh(field string, load func() (interface{}, error)) {
+	if _, running := n.refreshing.LoadOrStore(field, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer n.refreshing.Delete(field)
+
+		if _, err, _ := n.loads.Do(field, func() (interface{}, error) {
+			pending := n.begin(field)
+			defer n.end(field, pending)
+
+			value, err := load()
+			if err != nil {
+				return nil, err
+			}
+
+			if n.current(pending) {
+				n.set(field, value)
+			}
+			return value, nil
+		}); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+func (n *Namespace) begin(field string) *pendingLoad {
+	pending := &pendingLoad{}
+
+	n.mu.Lock()
+	n.pending[field] = pending
+	n.mu.Unlock()
+
+	return pending
+}
+
+func (n *Namespace) end(field string, pending *pendingLoad) {
+	n.mu.Lock()
+	if n.pending[field] == pending {
+		delete(n.pending, field)
+	}
+	n.mu.Unlock()
+}
+
+// current reports whether no invalidation has superseded pending yet.
+func (n *Namespace) current(pending *pendingLoad) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return !pending.superseded
+}
+
+// dropLocal evicts fields from memory, or every field when none are given,
+// and supersedes the loads of them in flight. Callers arriving afterwards
+// start a fresh load rather than joining the superseded one.
+func (n *Namespace) dropLocal(fields []string) {
+	n.mu.Lock()
+	if len(fields) == 0 {
+		for field, pending := range n.pending {
+			pending.superseded = true
+			delete(n.pending, field)
+			n.loads.Forget(field)
+		}
+	}
+	for _, field := range fields {
+		if pending, found := n.pending[field]; found {
+			pending.superseded = true
+			delete(n.pending, field)
+		}
+		n.loads.Forget(field)
+	}
+	n.mu.Unlock()
+
+	if len(fields) == 0 {
+		n.local.Flush()
+		return
+	}
+
+	for _, field := range fields {
+		n.local.Delete(field)
+	}
+}
+
+// set stores value in memory and, when possible, in Redis. A Redis failure
+// is only logged: the value is still good, it just isn't shared.
+func (n *Namespace) set(field string, value interface{}) {
+	n.setLocal(field, value)
+
+	data, err := n.serializer.Marshal(value)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if _, err := n.store.Do("HSET", n.name, field, data); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if _, err := n.store.Do("EXPIRE", n.name, int64(n.remoteExpiration/time.Second)); err != nil {
+		log.Println(err)
+	}
+}
+
+func (n *Namespace) setLocal(field string, value interface{}) {
+	n.local.Set(field, &entry{
+		value:      value,
+		freshUntil: time.Now().Add(n.localExpiration),
+	}, n.localExpiration+n.staleExpiration)
+}
+
+// Invalidate drops the given fields from both tiers, here and in every other
+// process listening on InvalidationChannel. The eviction is published even
+// if Redis refused the HDEL, so the other processes don't keep serving the
+// fields from memory.
+func (n *Namespace) Invalidate(fields ...string) {
+	if len(fields) == 0 {
+		return
+	}
+
+	n.dropLocal(fields)
+
+	args := make([]interface{}, 0, len(fields)+1)
+	args = append(args, n.name)
+	for _, field := range fields {
+		args = append(args, field)
+	}
+
+	if _, err := n.store.Do("HDEL", args...); err != nil {
+		log.Println(err)
+	}
+
+	n.store.publish(n.name, fields)
+}
+
+// InvalidateAll drops every field of the namespace from both tiers, here and in
+// every other process listening on InvalidationChannel.
+func (n *Namespace) InvalidateAll() {
+	n.dropLocal(nil)
+
+	if _, err := n.store.Do("DEL", n.name); err != nil {
+		log.Println(err)
+	}
+
+	n.store.publish(n.name, nil)
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/atletaid/go-template/src/common/redistest"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	pool, mr := redistest.NewPool(t)
+
+	return NewStore(pool, time.Minute, 1, time.Minute), mr
+}
+
+func stringNamespace(store *Store, name string, localExpiration, remoteExpiration time.Duration) *Namespace {
+	return store.Namespace(name, localExpiration, remoteExpiration, NewJSONSerializer(func() interface{} {
+		return new(string)
+	}))
+}
+
+// counter returns a loader answering value and the number of times it ran.
+func counter(value string) (func() (interface{}, error), *int32) {
+	calls := new(int32)
+	return func() (interface{}, error) {
+		atomic.AddInt32(calls, 1)
+		return value, nil
+	}, calls
+}
+
+func TestGetOrLoadCachesInBothTiers(t *testing.T) {
+	store, mr := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+
+	load, calls := counter("a")
+	for i := 0; i < 3; i++ {
+		value, err := namespace.GetOrLoad("1", load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "a" {
+			t.Fatalf("GetOrLoad() = %v, want a", value)
+		}
+	}
+
+	if *calls != 1 {
+		t.Errorf("loader ran %d times, want 1", *calls)
+	}
+
+	if got := mr.HGet("things", "1"); got != `"a"` {
+		t.Errorf("redis field = %q, want %q", got, `"a"`)
+	}
+
+	if got := mr.TTL("things"); got != time.Hour {
+		t.Errorf("redis TTL = %v, want %v", got, time.Hour)
+	}
+}
+
+// Concurrent misses on the same field must wait on the one load in flight
+// instead of each hitting the database.
+func TestGetOrLoadSharesConcurrentLoads(t *testing.T) {
+	store, _ := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+
+	const callers = 10
+	calls := new(int32)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func() (interface{}, error) {
+		if atomic.AddInt32(calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return "a", nil
+	}
+
+	var wg sync.WaitGroup
+	values := make([]interface{}, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = namespace.GetOrLoad("1", load)
+		}(i)
+	}
+
+	// Give every caller time to reach the in-flight load before it returns.
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("loader ran %d times, want 1", got)
+	}
+	for i, value := range values {
+		if value != "a" {
+			t.Errorf("caller %d got %v, want a", i, value)
+		}
+	}
+}
+
+func TestGetOrLoadReadsOtherProcessesFromRedis(t *testing.T) {
+	store, _ := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+
+	// A second store on the same Redis stands in for another instance.
+	other := NewStore(store.pool, time.Minute, 1, time.Minute)
+	otherNamespace := stringNamespace(other, "things", time.Minute, time.Hour)
+
+	load, _ := counter("a")
+	if _, err := namespace.GetOrLoad("1", load); err != nil {
+		t.Fatal(err)
+	}
+
+	otherLoad, otherCalls := counter("b")
+	value, err := otherNamespace.GetOrLoad("1", otherLoad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "a" || *otherCalls != 0 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want a from redis", value, *otherCalls)
+	}
+}
+
+func TestGetOrLoadDoesNotCacheErrors(t *testing.T) {
+	store, _ := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+
+	failure := errors.New("database down")
+	if _, err := namespace.GetOrLoad("1", func() (interface{}, error) {
+		return nil, failure
+	}); err != failure {
+		t.Fatalf("GetOrLoad() error = %v, want %v", err, failure)
+	}
+
+	load, calls := counter("a")
+	if value, err := namespace.GetOrLoad("1", load); err != nil || value != "a" {
+		t.Errorf("GetOrLoad() = %v, %v, want a", value, err)
+	}
+	if *calls != 1 {
+		t.Errorf("loader ran %d times, want 1", *calls)
+	}
+}
+
+func TestInvalidateDropsBothTiers(t *testing.T) {
+	store, mr := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+
+	for _, field := range []string{"1", "2"} {
+		load, _ := counter("old")
+		if _, err := namespace.GetOrLoad(field, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	namespace.Invalidate("1")
+
+	if mr.HGet("things", "1") != "" {
+		t.Error("invalidated field still in redis")
+	}
+	if mr.HGet("things", "2") == "" {
+		t.Error("other field dropped from redis")
+	}
+
+	load, calls := counter("new")
+	if value, _ := namespace.GetOrLoad("1", load); value != "new" || *calls != 1 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want a reload", value, *calls)
+	}
+
+	namespace.InvalidateAll()
+
+	if mr.Exists("things") {
+		t.Error("namespace hash still in redis after InvalidateAll")
+	}
+
+	load, calls = counter("newer")
+	if value, _ := namespace.GetOrLoad("2", load); value != "newer" || *calls != 1 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want a reload", value, *calls)
+	}
+}
+
+// A load that read the database before an Invalidate must not write its
+// result back after it, or the invalidated value would be cached again.
+func TestInvalidateSupersedesLoadInFlight(t *testing.T) {
+	store, mr := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan interface{})
+	go func() {
+		value, _ := namespace.GetOrLoad("1", func() (interface{}, error) {
+			close(started)
+			<-release
+			return "old", nil
+		})
+		done <- value
+	}()
+
+	<-started
+	namespace.Invalidate("1")
+
+	// Callers arriving after the Invalidate don't join the superseded load.
+	load, calls := counter("new")
+	if value, _ := namespace.GetOrLoad("1", load); value != "new" || *calls != 1 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want a fresh load", value, *calls)
+	}
+
+	close(release)
+	if value := <-done; value != "old" {
+		t.Errorf("superseded GetOrLoad() = %v, want its own result", value)
+	}
+
+	if got := mr.HGet("things", "1"); got != `"new"` {
+		t.Errorf("redis field = %q, want %q", got, `"new"`)
+	}
+	load, calls = counter("unused")
+	if value, _ := namespace.GetOrLoad("1", load); value != "new" || *calls != 0 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want the value loaded after the Invalidate", value, *calls)
+	}
+}
+
+func TestZeroExpirationFallsBackToDefaults(t *testing.T) {
+	store, mr := newTestStore(t)
+	namespace := stringNamespace(store, "things", 0, 0)
+
+	if namespace.localExpiration != defaultLocalExpiration {
+		t.Errorf("local expiration = %v, want %v", namespace.localExpiration, defaultLocalExpiration)
+	}
+
+	load, _ := counter("a")
+	if _, err := namespace.GetOrLoad("1", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mr.TTL("things"); got != defaultRemoteExpiration {
+		t.Errorf("redis TTL = %v, want %v", got, defaultRemoteExpiration)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	store, mr := newTestStore(t)
+	namespace := stringNamespace(store, "things", 20*time.Millisecond, time.Hour).
+		WithStaleWhileRevalidate(time.Minute)
+
+	load, _ := counter("old")
+	if _, err := namespace.GetOrLoad("1", load); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	value, err := namespace.GetOrLoad("1", func() (interface{}, error) {
+		defer close(refreshed)
+		return "new", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "old" {
+		t.Errorf("GetOrLoad() = %v, want the stale value at once", value)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale value was not refreshed")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for mr.HGet("things", "1") != `"new"` {
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed value never reached redis")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	load, calls := counter("unused")
+	if value, _ := namespace.GetOrLoad("1", load); value != "new" || *calls != 0 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want the refreshed value", value, *calls)
+	}
+}
+
+func TestGetOrLoadWithoutRedis(t *testing.T) {
+	store, mr := newTestStore(t)
+	namespace := stringNamespace(store, "things", time.Minute, time.Hour)
+	mr.Close()
+
+	load, calls := counter("a")
+	value, err := namespace.GetOrLoad("1", load)
+	if err != nil || value != "a" {
+		t.Fatalf("GetOrLoad() = %v, %v, want a from the loader", value, err)
+	}
+
+	// The breaker opened on the first failure, so Redis isn't dialed again.
+	if _, err := store.Do("PING"); err != ErrUnavailable {
+		t.Errorf("do() error = %v, want ErrUnavailable", err)
+	}
+
+	if value, _ := namespace.GetOrLoad("1", load); value != "a" || *calls != 1 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want the in-memory value", value, *calls)
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	// InvalidationChannel carries evictions between every process sharing the
+	// Redis instance, so their in-memory tiers don't outlive a write made
+	// elsewhere.
+	InvalidationChannel = "cache:invalidate"
+
+	pingInterval      = 30 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+type invalidation struct {
+	Origin    string   `json:"origin"`
+	Namespace string   `json:"namespace"`
+	Fields    []string `json:"fields,omitempty"`
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Println(err)
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(b)
+}
+
+// publish tells the other processes to evict fields of namespace from memory,
+// or the whole namespace when fields is empty. It is best effort: a lost
+// message only means the other processes wait for their local expiration.
+func (s *Store) publish(namespace string, fields []string) {
+	message, err := json.Marshal(invalidation{
+		Origin:    s.instanceID,
+		Namespace: namespace,
+		Fields:    fields,
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if _, err := s.Do("PUBLISH", InvalidationChannel, message); err != nil {
+		log.Println(err)
+	}
+}
+
+// Listen subscribes to InvalidationChannel and applies the evictions published
+// by other processes. It never returns: when the subscription drops it
+// reconnects with exponential backoff, flushing the in-memory tier first since
+// any eviction sent in the meantime was missed.
+func (s *Store) Listen() {
+	delay := minReconnectDelay
+	for {
+		subscribed, err := s.listen()
+		if subscribed {
+			delay = minReconnectDelay
+		}
+		log.Println("cache invalidation subscriber disconnected:", err)
+
+		time.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (s *Store) listen() (bool, error) {
+	psc := redigo.PubSubConn{Conn: s.pool.Get()}
+	defer psc.Close()
+
+	if err := psc.Subscribe(InvalidationChannel); err != nil {
+		return false, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					log.Println(err)
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	subscribed := false
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * pingInterval).(type) {
+		case redigo.Subscription:
+			if v.Kind == "subscribe" && !subscribed {
+				subscribed = true
+				s.flushLocal()
+			}
+		case redigo.Message:
+			var message invalidation
+			if err := json.Unmarshal(v.Data, &message); err != nil {
+				log.Println(err)
+				continue
+			}
+			s.evict(message)
+		case error:
+			return subscribed, v
+		}
+	}
+}
+
+func (s *Store) evict(message invalidation) {
+	if message.Origin == s.instanceID {
+		return
+	}
+
+	s.mu.RLock()
+	namespace, found := s.namespaces[message.Namespace]
+	s.mu.RUnlock()
+	if !found {
+		return
+	}
+
+	namespace.dropLocal(message.Fields)
+}
+
+func (s *Store) flushLocal() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, namespace := range s.namespaces {
+		namespace.dropLocal(nil)
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/redistest"
+)
+
+// newListeningStores returns two stores sharing a Redis, as two processes
+// would, with the second one listening for the evictions of the first.
+func newListeningStores(t *testing.T) (*Namespace, *Namespace, *redistest.PubSub) {
+	pool, _, pubSub := redistest.NewPubSubPool(t)
+
+	writer := NewStore(pool, time.Minute, 1, time.Minute)
+	listener := NewStore(pool, time.Minute, 1, time.Minute)
+	go listener.Listen()
+
+	eventually(t, "listener subscribed", func() bool { return pubSub.Subscribers() == 1 })
+
+	return stringNamespace(writer, "things", time.Minute, time.Hour),
+		stringNamespace(listener, "things", time.Minute, time.Hour), pubSub
+}
+
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// cachedLocally loads field into namespace's memory, retrying in case the
+// subscription flushed it in between.
+func cachedLocally(t *testing.T, namespace *Namespace, field string) {
+	t.Helper()
+
+	load, _ := counter("old")
+	eventually(t, field+" cached in memory", func() bool {
+		if _, err := namespace.GetOrLoad(field, load); err != nil {
+			t.Fatal(err)
+		}
+		_, found := namespace.local.Get(field)
+		return found
+	})
+}
+
+func TestListenEvictsOtherProcesses(t *testing.T) {
+	writer, listener, _ := newListeningStores(t)
+
+	cachedLocally(t, listener, "1")
+	cachedLocally(t, listener, "2")
+
+	writer.Invalidate("1")
+
+	eventually(t, "1 evicted from the listener", func() bool {
+		_, found := listener.local.Get("1")
+		return !found
+	})
+	if _, found := listener.local.Get("2"); !found {
+		t.Error("field 2 evicted along with 1")
+	}
+
+	load, calls := counter("new")
+	if value, _ := listener.GetOrLoad("1", load); value != "new" || *calls != 1 {
+		t.Errorf("GetOrLoad() = %v after %d loads, want a reload", value, *calls)
+	}
+
+	writer.InvalidateAll()
+
+	eventually(t, "namespace flushed on the listener", func() bool {
+		return listener.local.ItemCount() == 0
+	})
+}
+
+// Evictions sent while the subscription is down are lost, so the listener
+// must flush its memory when it resubscribes, and keep applying evictions.
+func TestListenReconnects(t *testing.T) {
+	writer, listener, pubSub := newListeningStores(t)
+
+	cachedLocally(t, listener, "1")
+
+	pubSub.Drop()
+
+	eventually(t, "listener resubscribed", func() bool { return pubSub.Subscribers() == 1 })
+	eventually(t, "memory flushed on resubscribe", func() bool {
+		_, found := listener.local.Get("1")
+		return !found
+	})
+
+	cachedLocally(t, listener, "2")
+	writer.Invalidate("2")
+
+	eventually(t, "2 evicted after reconnecting", func() bool {
+		_, found := listener.local.Get("2")
+		return !found
+	})
+}
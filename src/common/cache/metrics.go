@@ -0,0 +1,14 @@
+package cache
+
+import "expvar"
+
+// metrics is published under "cache" on the expvar handler.
+var metrics = expvar.NewMap("cache")
+
+const (
+	metricLocalHit    = "local_hits"
+	metricRemoteHit   = "remote_hits"
+	metricMiss        = "misses"
+	metricRedisError  = "redis_errors"
+	metricCircuitOpen = "redis_rejected"
+)
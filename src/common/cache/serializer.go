@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Serializer converts namespace values to and from the bytes stored in Redis.
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+type jsonSerializer struct {
+	newValue func() interface{}
+}
+
+// NewJSONSerializer returns a JSON Serializer. newValue must return a pointer
+// to the cached type, e.g. new(*model.Account) or new(model.Accounts); the
+// pointed-to value is what Unmarshal hands back.
+func NewJSONSerializer(newValue func() interface{}) Serializer {
+	return &jsonSerializer{
+		newValue: newValue,
+	}
+}
+
+func (s *jsonSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (s *jsonSerializer) Unmarshal(data []byte) (interface{}, error) {
+	value := s.newValue()
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(value).Elem().Interface(), nil
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tokopedia/sqlt"
+)
+
+// Querier is the part of *sql.DB the repositories use.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DB is a master and an optional replica opened as one sqlt group. Reads go
+// to the replica while it is reachable and no further than maxLag behind the
+// master; otherwise they go to the master.
+//
+// Writes name the scopes they change, and reads in a scope written less than
+// maxLag ago go to the master, so whoever wrote sees its own write even if
+// the replica hasn't replayed it yet. Reads elsewhere keep using the replica.
+// Scopes are tracked per process, as requests from one client mostly land on
+// the same instance.
+type DB struct {
+	group      *sqlt.DB
+	hasReplica bool
+	maxLag     time.Duration
+
+	replicaOK int32
+
+	mu      sync.Mutex
+	written map[string]time.Time
+}
+
+// Read-your-writes scopes of the tables changed through whole-table caches
+// and listings.
+const (
+	ScopeAccounts    = "accounts"
+	ScopeRestaurants = "restaurants"
+	ScopeRecreations = "recreations"
+)
+
+// ScopeSwipes is the scope of one account's swipes. Swipes are written too
+// often to pin the whole table, and only their owner reads them.
+func ScopeSwipes(accountID int64) string {
+	return fmt.Sprintf("swipes:%d", accountID)
+}
+
+const replicationLagQuery = `
+	SELECT
+		CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END
+`
+
+// Open connects to masterDSN and, when slaveDSN is set and differs from it,
+// to the replica, whose health and lag are then checked every checkInterval.
+func Open(masterDSN, slaveDSN string, maxLag, checkInterval time.Duration) (*DB, error) {
+	sources := masterDSN
+	hasReplica := slaveDSN != "" && slaveDSN != masterDSN
+	if hasReplica {
+		sources += ";" + slaveDSN
+	}
+
+	group, err := sqlt.Open("postgres", sources)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	db := &DB{
+		group:      group,
+		hasReplica: hasReplica,
+		maxLag:     maxLag,
+		written:    make(map[string]time.Time),
+	}
+
+	if hasReplica {
+		db.checkReplica(checkInterval)
+		go db.monitorReplica(checkInterval)
+	}
+
+	return db, nil
+}
+
+// Writer returns the master and starts the window during which reads in
+// scopes go to the master too.
+func (db *DB) Writer(scopes ...string) Querier {
+	db.pin(scopes)
+	return db.group.Master()
+}
+
+// Master returns the master connection pool itself, for callers that need
+// transactions or a dedicated connection. Writes through it pin scopes like
+// Writer does.
+func (db *DB) Master(scopes ...string) *sql.DB {
+	db.pin(scopes)
+	return db.group.Master().DB
+}
+
+// Reader returns the replica when it can be trusted and none of scopes was
+// written recently, the master otherwise.
+func (db *DB) Reader(scopes ...string) Querier {
+	if !db.hasReplica || atomic.LoadInt32(&db.replicaOK) == 0 {
+		return db.group.Master()
+	}
+
+	if db.pinned(scopes) {
+		return db.group.Master()
+	}
+
+	return db.group.Slave()
+}
+
+func (db *DB) pin(scopes []string) {
+	if !db.hasReplica || len(scopes) == 0 {
+		return
+	}
+
+	now := time.Now()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, scope := range scopes {
+		db.written[scope] = now
+	}
+}
+
+func (db *DB) pinned(scopes []string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, scope := range scopes {
+		if writtenAt, ok := db.written[scope]; ok && time.Since(writtenAt) < db.maxLag {
+			return true
+		}
+	}
+	return false
+}
+
+// unpinExpired forgets scopes whose window has passed.
+func (db *DB) unpinExpired() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for scope, writtenAt := range db.written {
+		if time.Since(writtenAt) >= db.maxLag {
+			delete(db.written, scope)
+		}
+	}
+}
+
+func (db *DB) monitorReplica(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		db.checkReplica(interval)
+		db.unpinExpired()
+	}
+}
+
+func (db *DB) checkReplica(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var lagSeconds float64
+	err := db.group.Slave().QueryRowContext(ctx, replicationLagQuery).Scan(&lagSeconds)
+
+	var state int32
+	if err == nil && time.Duration(lagSeconds*float64(time.Second)) <= db.maxLag {
+		state = 1
+	}
+
+	if previous := atomic.SwapInt32(&db.replicaOK, state); previous == state {
+		return
+	}
+
+	switch {
+	case state == 1:
+		log.Println("replica healthy, reading from replica")
+	case err != nil:
+		log.Println("replica unreachable, reading from master:", err)
+	default:
+		log.Printf("replica %.1fs behind, reading from master", lagSeconds)
+	}
+}
+
+// IsUniqueViolation reports whether err is Postgres rejecting a duplicate
+// value for a unique index.
+func IsUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
@@ -0,0 +1,169 @@
+package geo
+
+import (
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/cache"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// kmPerDegree is the length of one degree of latitude.
+const kmPerDegree = 111.045
+
+// Hit is one member found by Index.Search, with its distance from the
+// search center.
+type Hit struct {
+	ID         int64
+	DistanceKM float64
+}
+
+// Index keeps member positions in a Redis geo set under key. A new or
+// flushed index has to be rebuilt from the source of truth; until
+// MarkReady is called Ready reports false and callers should search there
+// instead. A failed Add or Remove leaves the index behind the source of
+// truth, so it is no longer ready until rebuilt. Commands go through the
+// cache.Store breaker, so while Redis is down every call fails fast with
+// cache.ErrUnavailable and callers search the source of truth.
+type Index struct {
+	store *cache.Store
+	key   string
+
+	// stale is set while a failed write still has to clear the ready flag,
+	// which can't be done while Redis is unreachable.
+	stale int32
+}
+
+func NewIndex(store *cache.Store, key string) *Index {
+	return &Index{
+		store: store,
+		key:   key,
+	}
+}
+
+// Add sets the position of member id.
+func (i *Index) Add(id int64, lat, long float64) error {
+	_, err := i.store.Do("GEOADD", i.key, long, lat, id)
+	if err != nil {
+		i.invalidate()
+	}
+	return err
+}
+
+// Remove drops member id from the index.
+func (i *Index) Remove(id int64) error {
+	_, err := i.store.Do("ZREM", i.key, id)
+	if err != nil {
+		i.invalidate()
+	}
+	return err
+}
+
+// Search returns up to count members within radiusKM of the given point,
+// nearest first.
+func (i *Index) Search(lat, long, radiusKM float64, count int) ([]Hit, error) {
+	replies, err := redigo.Values(i.store.Do("GEOSEARCH", i.key,
+		"FROMLONLAT", long, lat,
+		"BYRADIUS", radiusKM, "km",
+		"ASC", "COUNT", count, "WITHDIST",
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(replies))
+	for _, reply := range replies {
+		fields, err := redigo.Values(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var hit Hit
+		if _, err := redigo.Scan(fields, &hit.ID, &hit.DistanceKM); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
+
+// Ready reports whether the index has been fully built and no write to it
+// has failed since.
+func (i *Index) Ready() (bool, error) {
+	if atomic.LoadInt32(&i.stale) == 1 {
+		if err := i.clearReady(); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	return redigo.Bool(i.store.Do("EXISTS", i.key+":ready"))
+}
+
+// invalidate marks the index as no longer ready, on this instance at once
+// and on the others as soon as Redis takes the change.
+func (i *Index) invalidate() {
+	atomic.StoreInt32(&i.stale, 1)
+	if err := i.clearReady(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (i *Index) clearReady() error {
+	if _, err := i.store.Do("DEL", i.key+":ready"); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&i.stale, 0)
+	return nil
+}
+
+// MarkReady records that the index holds every member.
+func (i *Index) MarkReady() error {
+	_, err := i.store.Do("SET", i.key+":ready", 1)
+	return err
+}
+
+// LockRebuild claims the rebuild for ttl, so that only one instance
+// rebuilds at a time. It reports false if another instance holds it.
+func (i *Index) LockRebuild(ttl time.Duration) (bool, error) {
+	reply, err := redigo.String(i.store.Do("SET", i.key+":rebuild", 1, "NX", "EX", int64(ttl/time.Second)))
+	if err == redigo.ErrNil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return reply == "OK", nil
+}
+
+// BoundingBox returns how many degrees of latitude and longitude around lat
+// cover radiusKM, for narrowing a search before measuring exact distances.
+func BoundingBox(lat, radiusKM float64) (latDelta, longDelta float64) {
+	latDelta = radiusKM / kmPerDegree
+	longDelta = 180
+	if cos := math.Cos(lat * math.Pi / 180); cos > radiusKM/(kmPerDegree*180) {
+		longDelta = math.Min(radiusKM/(kmPerDegree*cos), 180)
+	}
+	return latDelta, longDelta
+}
+
+// DistanceKM returns the great-circle distance between two points.
+func DistanceKM(lat1, long1, lat2, long2 float64) float64 {
+	const earthRadiusKM = 6371
+
+	toRadians := func(degrees float64) float64 {
+		return degrees * math.Pi / 180
+	}
+
+	dLat := toRadians(lat2 - lat1)
+	dLong := toRadians(long2 - long1)
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Pow(math.Sin(dLong/2), 2)
+	return earthRadiusKM * 2 * math.Asin(math.Sqrt(a))
+}
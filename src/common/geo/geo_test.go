@@ -0,0 +1,106 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/redistest"
+)
+
+const breakerCooldown = 20 * time.Millisecond
+
+func newTestIndex(t *testing.T) (*Index, *miniredis.Miniredis) {
+	pool, mr := redistest.NewPool(t)
+
+	return NewIndex(cache.NewStore(pool, time.Minute, 1, breakerCooldown), "places:geo"), mr
+}
+
+func TestIndexSearch(t *testing.T) {
+	index, _ := newTestIndex(t)
+
+	// Three points east of the center, about 1, 2 and 50 km away.
+	for id, long := range map[int64]float64{1: 107.609, 2: 107.618, 3: 108.05} {
+		if err := index.Add(id, -6.9, long); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hits, err := index.Search(-6.9, 107.6, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].ID != 1 || hits[1].ID != 2 {
+		t.Fatalf("Search() = %+v, want 1 then 2 within 10 km", hits)
+	}
+	if hits[0].DistanceKM < 0.9 || hits[0].DistanceKM > 1.1 {
+		t.Errorf("Search() distance to 1 = %v, want about 1 km", hits[0].DistanceKM)
+	}
+
+	if hits, _ := index.Search(-6.9, 107.6, 10, 1); len(hits) != 1 || hits[0].ID != 1 {
+		t.Errorf("Search() with count 1 = %+v, want only 1", hits)
+	}
+
+	if err := index.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := index.Search(-6.9, 107.6, 10, 5); len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("Search() after Remove(1) = %+v, want only 2", hits)
+	}
+}
+
+func TestIndexReadiness(t *testing.T) {
+	index, mr := newTestIndex(t)
+
+	if ready, err := index.Ready(); err != nil || ready {
+		t.Fatalf("Ready() on a new index = %v, %v, want false", ready, err)
+	}
+
+	if err := index.MarkReady(); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err := index.Ready(); err != nil || !ready {
+		t.Fatalf("Ready() after MarkReady = %v, %v, want true", ready, err)
+	}
+
+	// An Add lost while Redis is down leaves the index behind the database.
+	mr.Close()
+	if err := index.Add(1, -6.9, 107.6); err == nil {
+		t.Fatal("Add() without redis succeeded")
+	}
+	if _, err := index.Ready(); !errors.Is(err, cache.ErrUnavailable) {
+		t.Errorf("Ready() with the breaker open error = %v, want cache.ErrUnavailable", err)
+	}
+
+	// Redis comes back with the ready flag still set, but the index must
+	// not be trusted until it is rebuilt.
+	if err := mr.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * breakerCooldown)
+
+	if ready, err := index.Ready(); err != nil || ready {
+		t.Errorf("Ready() after a lost Add = %v, %v, want false", ready, err)
+	}
+	if mr.Exists("places:geo:ready") {
+		t.Error("ready flag left in redis after a lost Add")
+	}
+}
+
+func TestIndexLockRebuild(t *testing.T) {
+	index, mr := newTestIndex(t)
+
+	if locked, err := index.LockRebuild(time.Minute); err != nil || !locked {
+		t.Fatalf("first LockRebuild() = %v, %v, want true", locked, err)
+	}
+	if locked, err := index.LockRebuild(time.Minute); err != nil || locked {
+		t.Errorf("second LockRebuild() = %v, %v, want false", locked, err)
+	}
+
+	mr.FastForward(time.Minute)
+	if locked, _ := index.LockRebuild(time.Minute); !locked {
+		t.Error("LockRebuild() = false after the lock expired")
+	}
+}
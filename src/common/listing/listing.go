@@ -0,0 +1,181 @@
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Filters a list endpoint may accept, see Request.Query.
+const (
+	FilterCity  = "city"
+	FilterPrice = "price"
+	FilterTime  = "time"
+)
+
+// Request holds the query parameters shared by every list endpoint. Sort
+// names one field, prefixed with "-" for descending order. Cursor is the
+// next_cursor of the previous page.
+type Request struct {
+	Limit    int    `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor   string `json:"cursor" form:"cursor"`
+	Sort     string `json:"sort" form:"sort"`
+	City     string `json:"city" form:"city" binding:"max=255"`
+	MinPrice *int   `json:"min_price" form:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice *int   `json:"max_price" form:"max_price" binding:"omitempty,gte=0"`
+	MinTime  *int   `json:"min_time" form:"min_time" binding:"omitempty,gte=0"`
+	MaxTime  *int   `json:"max_time" form:"max_time" binding:"omitempty,gte=0"`
+}
+
+// Query is a validated page request. Rows come ordered by Sort, then by ID
+// in the same direction, starting after the After cursor.
+type Query struct {
+	Limit    int
+	Sort     string
+	Desc     bool
+	After    *Cursor
+	City     string
+	MinPrice *int
+	MaxPrice *int
+	MinTime  *int
+	MaxTime  *int
+}
+
+// Cursor is the position of the last row of a page: its sort value, as
+// text, and its ID.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// Query checks r against the sort fields and filters an endpoint supports.
+// The first of sorts is the default.
+func (r *Request) Query(sorts []string, filters ...string) (*Query, error) {
+	q := &Query{
+		Limit:    r.Limit,
+		Sort:     sorts[0],
+		City:     r.City,
+		MinPrice: r.MinPrice,
+		MaxPrice: r.MaxPrice,
+		MinTime:  r.MinTime,
+		MaxTime:  r.MaxTime,
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+
+	var details []apperror.FieldError
+	if r.Sort != "" {
+		q.Desc = strings.HasPrefix(r.Sort, "-")
+		q.Sort = strings.TrimPrefix(r.Sort, "-")
+		if !contains(sorts, q.Sort) {
+			details = append(details, apperror.FieldError{
+				Field:   "sort",
+				Message: fmt.Sprintf("must be one of %s, optionally prefixed with -", strings.Join(sorts, ", ")),
+			})
+		}
+	}
+
+	for _, filter := range []struct {
+		name  string
+		field string
+		set   bool
+	}{
+		{FilterCity, "city", r.City != ""},
+		{FilterPrice, "min_price", r.MinPrice != nil},
+		{FilterPrice, "max_price", r.MaxPrice != nil},
+		{FilterTime, "min_time", r.MinTime != nil},
+		{FilterTime, "max_time", r.MaxTime != nil},
+	} {
+		if filter.set && !contains(filters, filter.name) {
+			details = append(details, apperror.FieldError{Field: filter.field, Message: "is not supported here"})
+		}
+	}
+
+	if r.Cursor != "" {
+		cursor, err := decodeCursor(r.Cursor)
+		if err != nil || cursor.Sort != q.Sort || cursor.Desc != q.Desc {
+			details = append(details, apperror.FieldError{Field: "cursor", Message: "is invalid for this sort"})
+		}
+		q.After = cursor
+	}
+
+	if len(details) > 0 {
+		return nil, apperror.ValidationError.WithDetails(details...)
+	}
+
+	return q, nil
+}
+
+// Key identifies the page, for use in cache keys.
+func (q *Query) Key() string {
+	key := fmt.Sprintf("limit=%d&sort=%s&desc=%t&city=%q&price=%s-%s&time=%s-%s",
+		q.Limit, q.Sort, q.Desc, q.City,
+		formatBound(q.MinPrice), formatBound(q.MaxPrice),
+		formatBound(q.MinTime), formatBound(q.MaxTime),
+	)
+
+	if q.After != nil {
+		key += fmt.Sprintf("&after=%s:%d", q.After.Value, q.After.ID)
+	}
+	return key
+}
+
+// NextCursor encodes the position after a row with the given sort value
+// and ID.
+func (q *Query) NextCursor(value string, id int64) string {
+	data, _ := json.Marshal(Cursor{
+		Sort:  q.Sort,
+		Desc:  q.Desc,
+		Value: value,
+		ID:    id,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// TimeValue formats a timestamp sort value for NextCursor.
+func TimeValue(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05.999999")
+}
+
+func decodeCursor(encoded string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := &Cursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+
+	return cursor, nil
+}
+
+func formatBound(bound *int) string {
+	if bound == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", *bound)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package listing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+)
+
+var testColumns = Columns{
+	ID:         "id",
+	City:       "city",
+	Price:      "price",
+	TimeMinute: "time_minute",
+	Sorts: map[string]string{
+		"id":   "id",
+		"name": "name",
+	},
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestRequestQuery(t *testing.T) {
+	sorts := []string{"id", "name"}
+	cursor := (&Query{Sort: "name", Desc: true}).NextCursor("Bakso", 7)
+
+	tests := []struct {
+		name    string
+		req     Request
+		filters []string
+		want    *Query
+		invalid []string
+	}{
+		{
+			name: "defaults",
+			req:  Request{},
+			want: &Query{Limit: DefaultLimit, Sort: "id"},
+		},
+		{
+			name: "descending sort",
+			req:  Request{Limit: 5, Sort: "-name"},
+			want: &Query{Limit: 5, Sort: "name", Desc: true},
+		},
+		{
+			name:    "supported filters",
+			req:     Request{City: "Bandung", MinPrice: intPtr(10)},
+			filters: []string{FilterCity, FilterPrice},
+			want:    &Query{Limit: DefaultLimit, Sort: "id", City: "Bandung", MinPrice: intPtr(10)},
+		},
+		{
+			name: "cursor of the same sort",
+			req:  Request{Sort: "-name", Cursor: cursor},
+			want: &Query{
+				Limit: DefaultLimit,
+				Sort:  "name",
+				Desc:  true,
+				After: &Cursor{Sort: "name", Desc: true, Value: "Bakso", ID: 7},
+			},
+		},
+		{
+			name:    "unknown sort",
+			req:     Request{Sort: "price"},
+			invalid: []string{"sort"},
+		},
+		{
+			name:    "unsupported filters",
+			req:     Request{City: "Bandung", MaxTime: intPtr(30)},
+			filters: []string{FilterPrice},
+			invalid: []string{"city", "max_time"},
+		},
+		{
+			name:    "cursor of another sort",
+			req:     Request{Sort: "name", Cursor: cursor},
+			invalid: []string{"cursor"},
+		},
+		{
+			name:    "malformed cursor",
+			req:     Request{Cursor: "not a cursor"},
+			invalid: []string{"cursor"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.Query(sorts, tt.filters...)
+
+			if tt.invalid != nil {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) || !errors.Is(err, apperror.ValidationError) {
+					t.Fatalf("Query() error = %v, want ValidationError", err)
+				}
+
+				var fields []string
+				for _, detail := range appErr.Details {
+					fields = append(fields, detail.Field)
+				}
+				if !reflect.DeepEqual(fields, tt.invalid) {
+					t.Errorf("invalid fields = %v, want %v", fields, tt.invalid)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       Query
+		wantClauses string
+		wantArgs    []interface{}
+	}{
+		{
+			name:        "first page by id",
+			query:       Query{Limit: 10, Sort: "id"},
+			wantClauses: "WHERE deleted_at IS NULL\nORDER BY id ASC\nLIMIT $1",
+			wantArgs:    []interface{}{11},
+		},
+		{
+			name:        "after an id, descending",
+			query:       Query{Limit: 10, Sort: "id", Desc: true, After: &Cursor{ID: 42}},
+			wantClauses: "WHERE deleted_at IS NULL AND id < $1\nORDER BY id DESC\nLIMIT $2",
+			wantArgs:    []interface{}{int64(42), 11},
+		},
+		{
+			name:        "after a name, with filters",
+			query:       Query{Limit: 5, Sort: "name", City: "Bandung", MaxPrice: intPtr(50), After: &Cursor{Value: "Bakso", ID: 7}},
+			wantClauses: "WHERE deleted_at IS NULL AND city = $1 AND price <= $2 AND (name, id) > ($3, $4)\nORDER BY name ASC, id ASC\nLIMIT $5",
+			wantArgs:    []interface{}{"Bandung", 50, "Bakso", int64(7), 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clauses, args := tt.query.SQL(testColumns, []string{"deleted_at IS NULL"}, nil)
+			if clauses != tt.wantClauses {
+				t.Errorf("clauses = %q, want %q", clauses, tt.wantClauses)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestQuerySQLSkipsUnsupportedFilters(t *testing.T) {
+	query := Query{Limit: 1, Sort: "id", City: "Bandung", MinTime: intPtr(15)}
+	clauses, args := query.SQL(Columns{ID: "id", Sorts: map[string]string{"id": "id"}}, nil, nil)
+
+	if want := "ORDER BY id ASC\nLIMIT $1"; clauses != want {
+		t.Errorf("clauses = %q, want %q", clauses, want)
+	}
+	if want := []interface{}{2}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+}
+
+func TestQueryKeyDistinguishesPages(t *testing.T) {
+	first := Query{Limit: 20, Sort: "id"}
+	next := Query{Limit: 20, Sort: "id", After: &Cursor{Value: "20", ID: 20}}
+	filtered := Query{Limit: 20, Sort: "id", MinPrice: intPtr(0)}
+
+	keys := map[string]bool{}
+	for _, q := range []Query{first, next, filtered} {
+		keys[q.Key()] = true
+	}
+
+	if len(keys) != 3 {
+		t.Errorf("got %d distinct keys for 3 different pages", len(keys))
+	}
+}
@@ -0,0 +1,72 @@
+package listing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Columns maps a table's columns to what a Query can sort and filter on.
+// Filters whose column is empty aren't supported by the table.
+type Columns struct {
+	ID         string
+	City       string
+	Price      string
+	TimeMinute string
+	Sorts      map[string]string
+}
+
+// SQL appends q's filters and cursor to conditions and returns the WHERE,
+// ORDER BY and LIMIT clauses with their arguments appended to args. One row
+// more than the limit is asked for, to tell whether there is a next page.
+func (q *Query) SQL(cols Columns, conditions []string, args []interface{}) (string, []interface{}) {
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.City != "" && cols.City != "" {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", cols.City, param(q.City)))
+	}
+
+	for _, bound := range []struct {
+		column string
+		op     string
+		value  *int
+	}{
+		{cols.Price, ">=", q.MinPrice},
+		{cols.Price, "<=", q.MaxPrice},
+		{cols.TimeMinute, ">=", q.MinTime},
+		{cols.TimeMinute, "<=", q.MaxTime},
+	} {
+		if bound.value != nil && bound.column != "" {
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", bound.column, bound.op, param(*bound.value)))
+		}
+	}
+
+	sortColumn := cols.Sorts[q.Sort]
+	op, direction := ">", "ASC"
+	if q.Desc {
+		op, direction = "<", "DESC"
+	}
+
+	if q.After != nil {
+		if sortColumn == cols.ID {
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", cols.ID, op, param(q.After.ID)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s, %s) %s (%s, %s)", sortColumn, cols.ID, op, param(q.After.Value), param(q.After.ID)))
+		}
+	}
+
+	var clauses string
+	if len(conditions) > 0 {
+		clauses = "WHERE " + strings.Join(conditions, " AND ") + "\n"
+	}
+
+	order := fmt.Sprintf("%s %s", cols.ID, direction)
+	if sortColumn != cols.ID {
+		order = fmt.Sprintf("%s %s, %s", sortColumn, direction, order)
+	}
+	clauses += fmt.Sprintf("ORDER BY %s\nLIMIT %s", order, param(q.Limit+1))
+
+	return clauses, args
+}
@@ -0,0 +1,18 @@
+package mail
+
+import (
+	"log"
+)
+
+type logMailer struct{}
+
+// NewLogMailer prints messages to the log instead of sending them, so links
+// and tokens can be picked up by hand during local development.
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(msg Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
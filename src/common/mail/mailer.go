@@ -0,0 +1,31 @@
+package mail
+
+import (
+	"fmt"
+
+	"github.com/atletaid/go-template/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers a plain-text email.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewMailer returns the Mailer selected by the [Mail] config section's
+// Driver: "smtp" for real delivery, "log" to print messages instead.
+func NewMailer(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+	case "log", "":
+		return NewLogMailer(), nil
+	default:
+		return nil, fmt.Errorf("mail: unsupported Driver %q, use smtp or log", cfg.Driver)
+	}
+}
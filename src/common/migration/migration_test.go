@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Load() found no migrations")
+	}
+
+	byVersion := make(map[int64]Migration)
+	for i, migration := range migrations {
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			t.Errorf("migration %d_%s comes after %d_%s, want strictly increasing versions",
+				migration.Version, migration.Name, migrations[i-1].Version, migrations[i-1].Name)
+		}
+		if migration.Up == "" {
+			t.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		byVersion[migration.Version] = migration
+	}
+
+	for version, name := range map[int64]string{
+		13: "create_swipes",
+		14: "add_swipes_previous_direction",
+	} {
+		if migration, found := byVersion[version]; !found || migration.Name != name || migration.Down == "" {
+			t.Errorf("migration %d = %+v, want %s with up and down scripts", version, migration, name)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		migration string
+		direction string
+	}{
+		{"0001_create_accounts.up.sql", "0001", "create_accounts", "up"},
+		{"0014_add_swipes_previous_direction.down.sql", "0014", "add_swipes_previous_direction", "down"},
+		{"12345_wide_version.up.sql", "12345", "wide_version", "up"},
+		{"create_accounts.up.sql", "", "", ""},
+		{"0001_create_accounts.sql", "", "", ""},
+		{"0001_create-accounts.up.sql", "", "", ""},
+		{"0001_create_accounts.up.sql.bak", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		match := fileName.FindStringSubmatch(tt.name)
+		if tt.version == "" {
+			if match != nil {
+				t.Errorf("%s matched %q, want no match", tt.name, match)
+			}
+			continue
+		}
+		if match == nil || match[1] != tt.version || match[2] != tt.migration || match[3] != tt.direction {
+			t.Errorf("%s matched %q, want version %s, name %s, direction %s", tt.name, match, tt.version, tt.migration, tt.direction)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0002_old.up.sql", "0009_latest.up.sql", "0009_latest.down.sql", "README"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := Create(dir, "Add Swipe  Index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "0010_add_swipe_index.up.sql"),
+		filepath.Join(dir, "0010_add_swipe_index.down.sql"),
+	}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("Create() = %v, want %v", paths, want)
+	}
+
+	if _, err := Create(dir, "drop; table"); err == nil {
+		t.Error("Create() accepted a name that isn't a word")
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS accounts (
+	account_id    BIGSERIAL PRIMARY KEY,
+	user_email    VARCHAR(255) NOT NULL DEFAULT '',
+	user_fullname VARCHAR(255) NOT NULL DEFAULT '',
+	created_at    TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at    TIMESTAMP NOT NULL DEFAULT now()
+);
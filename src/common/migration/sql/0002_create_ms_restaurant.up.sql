@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS ms_restaurant (
+	restaurant_id          BIGSERIAL PRIMARY KEY,
+	restaurant_name        VARCHAR(255) NOT NULL DEFAULT '',
+	restaurant_time_minute INTEGER NOT NULL DEFAULT 0,
+	restaurant_price       INTEGER NOT NULL DEFAULT 0,
+	position_lat           DOUBLE PRECISION NOT NULL DEFAULT 0,
+	position_long          DOUBLE PRECISION NOT NULL DEFAULT 0,
+	restaurant_city        VARCHAR(255) NOT NULL DEFAULT '',
+	restaurant_image       TEXT NOT NULL DEFAULT '',
+	restaurant_description TEXT NOT NULL DEFAULT '',
+	created_at             TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ms_restaurant_city_idx ON ms_restaurant (restaurant_city);
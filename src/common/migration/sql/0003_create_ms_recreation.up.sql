@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS ms_recreation (
+	recreation_id          BIGSERIAL PRIMARY KEY,
+	recreation_name        VARCHAR(255) NOT NULL DEFAULT '',
+	recreation_time_minute INTEGER NOT NULL DEFAULT 0,
+	recreation_price       INTEGER NOT NULL DEFAULT 0,
+	position_lat           DOUBLE PRECISION NOT NULL DEFAULT 0,
+	position_long          DOUBLE PRECISION NOT NULL DEFAULT 0,
+	recreation_city        VARCHAR(255) NOT NULL DEFAULT '',
+	recreation_image       TEXT NOT NULL DEFAULT '',
+	recreation_description TEXT NOT NULL DEFAULT '',
+	created_at             TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ms_recreation_city_idx ON ms_recreation (recreation_city);
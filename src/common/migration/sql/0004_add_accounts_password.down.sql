@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS accounts_user_email_key;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS password_hash;
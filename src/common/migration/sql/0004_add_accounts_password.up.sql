@@ -0,0 +1,4 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Fails if accounts already share an email; merge those rows first.
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_user_email_key ON accounts (lower(user_email));
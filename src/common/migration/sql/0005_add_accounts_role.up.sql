@@ -0,0 +1,2 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS user_role VARCHAR(16) NOT NULL DEFAULT 'user'
+	CONSTRAINT accounts_user_role_check CHECK (user_role IN ('user', 'curator', 'admin'));
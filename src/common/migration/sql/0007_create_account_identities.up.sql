@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS account_identities (
+	provider   VARCHAR(32) NOT NULL,
+	subject    VARCHAR(255) NOT NULL,
+	account_id BIGINT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS account_identities_account_id_idx ON account_identities (account_id);
@@ -0,0 +1,2 @@
+ALTER TABLE ms_recreation DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE ms_restaurant DROP COLUMN IF EXISTS updated_at;
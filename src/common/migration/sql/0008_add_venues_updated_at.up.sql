@@ -0,0 +1,2 @@
+ALTER TABLE ms_restaurant ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE ms_recreation ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
@@ -0,0 +1,7 @@
+DELETE FROM ms_recreation WHERE deleted_at IS NOT NULL;
+DELETE FROM ms_restaurant WHERE deleted_at IS NOT NULL;
+DELETE FROM accounts WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE ms_recreation DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE ms_restaurant DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS deleted_at;
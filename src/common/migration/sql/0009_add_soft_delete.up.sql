@@ -0,0 +1,8 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE ms_restaurant ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE ms_recreation ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- The purge job only looks at deleted rows.
+CREATE INDEX IF NOT EXISTS accounts_deleted_at_idx ON accounts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS ms_restaurant_deleted_at_idx ON ms_restaurant (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS ms_recreation_deleted_at_idx ON ms_recreation (deleted_at) WHERE deleted_at IS NOT NULL;
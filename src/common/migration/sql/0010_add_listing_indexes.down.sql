@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS accounts_created_at_id_idx;
+DROP INDEX IF EXISTS ms_recreation_time_minute_id_idx;
+DROP INDEX IF EXISTS ms_recreation_price_id_idx;
+DROP INDEX IF EXISTS ms_recreation_name_id_idx;
+DROP INDEX IF EXISTS ms_recreation_created_at_id_idx;
+DROP INDEX IF EXISTS ms_restaurant_time_minute_id_idx;
+DROP INDEX IF EXISTS ms_restaurant_price_id_idx;
+DROP INDEX IF EXISTS ms_restaurant_name_id_idx;
+DROP INDEX IF EXISTS ms_restaurant_created_at_id_idx;
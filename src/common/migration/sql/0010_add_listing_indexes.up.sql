@@ -0,0 +1,10 @@
+-- Keyset pagination walks (sort column, id) over live rows.
+CREATE INDEX IF NOT EXISTS ms_restaurant_created_at_id_idx ON ms_restaurant (created_at, restaurant_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_restaurant_name_id_idx ON ms_restaurant (restaurant_name, restaurant_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_restaurant_price_id_idx ON ms_restaurant (restaurant_price, restaurant_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_restaurant_time_minute_id_idx ON ms_restaurant (restaurant_time_minute, restaurant_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_recreation_created_at_id_idx ON ms_recreation (created_at, recreation_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_recreation_name_id_idx ON ms_recreation (recreation_name, recreation_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_recreation_price_id_idx ON ms_recreation (recreation_price, recreation_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_recreation_time_minute_id_idx ON ms_recreation (recreation_time_minute, recreation_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS accounts_created_at_id_idx ON accounts (created_at, account_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ms_recreation_position_idx;
+DROP INDEX IF EXISTS ms_restaurant_position_idx;
@@ -0,0 +1,3 @@
+-- Nearby searches narrow candidates to a bounding box before measuring distances.
+CREATE INDEX IF NOT EXISTS ms_restaurant_position_idx ON ms_restaurant (position_lat, position_long) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS ms_recreation_position_idx ON ms_recreation (position_lat, position_long) WHERE deleted_at IS NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS ms_recreation_name_trgm_idx;
+DROP INDEX IF EXISTS ms_restaurant_name_trgm_idx;
+DROP INDEX IF EXISTS ms_recreation_search_idx;
+DROP INDEX IF EXISTS ms_restaurant_search_idx;
+
+ALTER TABLE ms_recreation DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE ms_restaurant DROP COLUMN IF EXISTS search_vector;
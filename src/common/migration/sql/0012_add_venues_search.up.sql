@@ -0,0 +1,18 @@
+-- Full-text search over names (weighted highest) and descriptions, with
+-- trigram matching on names to tolerate typos. The 'indonesian' stemmer
+-- needs PostgreSQL 12 or later.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE ms_restaurant ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('indonesian', restaurant_name), 'A') ||
+	setweight(to_tsvector('indonesian', restaurant_description), 'B')
+) STORED;
+ALTER TABLE ms_recreation ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('indonesian', recreation_name), 'A') ||
+	setweight(to_tsvector('indonesian', recreation_description), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS ms_restaurant_search_idx ON ms_restaurant USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS ms_recreation_search_idx ON ms_recreation USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS ms_restaurant_name_trgm_idx ON ms_restaurant USING GIN (restaurant_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS ms_recreation_name_trgm_idx ON ms_recreation USING GIN (recreation_name gin_trgm_ops);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS swipes (
+	swipe_id   BIGSERIAL PRIMARY KEY,
+	account_id BIGINT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+	venue_type VARCHAR(16) NOT NULL CHECK (venue_type IN ('restaurant', 'recreation')),
+	venue_id   BIGINT NOT NULL,
+	direction  VARCHAR(16) NOT NULL CHECK (direction IN ('like', 'dislike', 'superlike')),
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP NOT NULL DEFAULT now(),
+	UNIQUE (account_id, venue_type, venue_id)
+);
+
+-- Undo takes an account's latest swipe; liked lists walk it newest first.
+CREATE INDEX IF NOT EXISTS swipes_account_updated_at_idx ON swipes (account_id, updated_at);
@@ -0,0 +1,2 @@
+ALTER TABLE swipes DROP COLUMN IF EXISTS previous_updated_at;
+ALTER TABLE swipes DROP COLUMN IF EXISTS previous_direction;
@@ -0,0 +1,13 @@
+-- Undo puts back the direction a re-swipe replaced, and when it was set,
+-- instead of deleting the swipe. Only one direction is kept: once undone,
+-- the swipe counts as a first swipe, so undoing it again deletes it even if
+-- it had replaced an earlier direction too.
+ALTER TABLE swipes ADD COLUMN IF NOT EXISTS previous_direction VARCHAR(16)
+	CHECK (previous_direction IN ('like', 'dislike', 'superlike'));
+ALTER TABLE swipes ADD COLUMN IF NOT EXISTS previous_updated_at TIMESTAMP;
+
+-- Swipes of venues purged before the purge learned to delete them.
+DELETE FROM swipes s
+WHERE
+	(s.venue_type = 'restaurant' AND NOT EXISTS (SELECT 1 FROM ms_restaurant WHERE restaurant_id = s.venue_id)) OR
+	(s.venue_type = 'recreation' AND NOT EXISTS (SELECT 1 FROM ms_recreation WHERE recreation_id = s.venue_id));
@@ -0,0 +1,35 @@
+package purge
+
+import (
+	"log"
+	"time"
+)
+
+// Func hard-deletes rows soft-deleted before deletedBefore and returns how
+// many it removed.
+type Func func(deletedBefore time.Time) (int64, error)
+
+// Run calls every purge func once per interval with a cutoff of retention
+// ago, for as long as the process lives. Each instance may run it: purging
+// the same rows twice is harmless.
+func Run(interval, retention time.Duration, funcs map[string]Func) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deletedBefore := time.Now().Add(-retention)
+		for name, purge := range funcs {
+			purged, err := purge(deletedBefore)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			if purged > 0 {
+				log.Printf("purged %d %s deleted before %s", purged, name, deletedBefore.Format(time.RFC3339))
+			}
+		}
+
+		<-ticker.C
+	}
+}
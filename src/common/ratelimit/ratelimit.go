@@ -0,0 +1,251 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Ways a group's clients are told apart, see config.RateLimitConfig.KeyBy.
+const (
+	KeyByIP      = "ip"
+	KeyByAccount = "account"
+	KeyByAPIKey  = "api_key"
+)
+
+const keyBucket = "ratelimit:%s:%s"
+
+// takeScript is a token bucket refilled at ARGV[1] tokens per second up to
+// ARGV[2] tokens. It takes one token if there is one and returns whether it
+// did, the tokens left, and the milliseconds until the next token and until
+// the bucket is full again.
+var takeScript = redigo.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+local full = math.ceil((capacity - tokens) / rate * 1000)
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(full, 1))
+
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) / rate * 1000)
+end
+
+return {allowed, math.floor(tokens), retry, full}
+`)
+
+type group struct {
+	rate     float64
+	capacity int
+	keyBy    string
+	apiKeys  map[string]bool
+}
+
+// Limiter throttles clients per route group with token buckets kept in
+// Redis, so a limit holds across every instance. Redis is reached through
+// the cache store's breaker; while it is down requests are let through
+// rather than failing or waiting on it.
+type Limiter struct {
+	store          *cache.Store
+	groups         map[string]*group
+	trustedProxies []*net.IPNet
+}
+
+// NewLimiter takes trustedProxies as a comma-separated list of IPs or
+// CIDRs, see config.ServerConfig.
+func NewLimiter(store *cache.Store, cfgs map[string]*config.RateLimitConfig, trustedProxies string) (*Limiter, error) {
+	l := &Limiter{
+		store:  store,
+		groups: make(map[string]*group),
+	}
+
+	for _, proxy := range splitList(trustedProxies) {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimit: trusted proxy %q: %v", proxy, err)
+		}
+		l.trustedProxies = append(l.trustedProxies, network)
+	}
+
+	for name, cfg := range cfgs {
+		if cfg.Requests <= 0 || cfg.Period <= 0 {
+			continue
+		}
+
+		capacity := cfg.Burst
+		if capacity <= 0 {
+			capacity = cfg.Requests
+		}
+
+		apiKeys := make(map[string]bool)
+		for _, apiKey := range splitList(cfg.APIKeys) {
+			apiKeys[apiKey] = true
+		}
+
+		l.groups[name] = &group{
+			rate:     float64(cfg.Requests) / (cfg.Period * time.Second).Seconds(),
+			capacity: capacity,
+			keyBy:    cfg.KeyBy,
+			apiKeys:  apiKeys,
+		}
+	}
+
+	return l, nil
+}
+
+// Limit throttles the routes it is attached to under the [RateLimit "name"]
+// config section. An unconfigured group isn't limited. Groups keyed by
+// account must run after auth.Middleware.AuthUserToken; anonymous callers,
+// and callers without a known API key, are keyed by IP instead.
+func (l *Limiter) Limit(name string) gin.HandlerFunc {
+	g, found := l.groups[name]
+	if !found {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		key := fmt.Sprintf(keyBucket, name, l.clientKey(c, g))
+		allowed, remaining, retry, full, err := l.take(key, g)
+		if err != nil {
+			if err != cache.ErrUnavailable {
+				log.Println(err)
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(g.capacity))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(full), 10))
+
+		if !allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(retry), 10))
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.TooManyRequests)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (l *Limiter) take(key string, g *group) (allowed bool, remaining, retry, full int64, err error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	values, err := redigo.Int64s(l.store.Eval(takeScript, key, g.rate, g.capacity, now))
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+
+	if len(values) != 4 {
+		return false, 0, 0, 0, fmt.Errorf("ratelimit: unexpected reply %v", values)
+	}
+
+	return values[0] == 1, values[1], values[2], values[3], nil
+}
+
+func (l *Limiter) clientKey(c *gin.Context, g *group) string {
+	switch g.keyBy {
+	case KeyByAccount:
+		if accountID, ok := auth.AccountID(c); ok {
+			return "account:" + strconv.FormatInt(accountID, 10)
+		}
+
+	case KeyByAPIKey:
+		if apiKey := c.GetHeader("X-API-Key"); g.apiKeys[apiKey] {
+			return "api_key:" + apiKey
+		}
+	}
+
+	return "ip:" + l.clientIP(c.Request.RemoteAddr, c.GetHeader("X-Forwarded-For"))
+}
+
+// clientIP is the peer's address, unless the peer is a trusted proxy. Then
+// it is the nearest address in forwardedFor that isn't one, since anything
+// further left may have been made up by the client.
+func (l *Limiter) clientIP(remoteAddr, forwardedFor string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	if !l.trusted(net.ParseIP(host)) {
+		return host
+	}
+
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+
+		if !l.trusted(ip) {
+			return ip.String()
+		}
+	}
+
+	return host
+}
+
+func (l *Limiter) trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range l.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func ceilSeconds(milliseconds int64) int64 {
+	return (milliseconds + 999) / 1000
+}
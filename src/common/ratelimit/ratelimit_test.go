@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/redistest"
+	"github.com/gin-gonic/gin"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func newTestLimiter(t *testing.T, cfgs map[string]*config.RateLimitConfig, trustedProxies string) (*Limiter, *miniredis.Miniredis) {
+	pool, mr := redistest.NewPool(t)
+
+	l, err := NewLimiter(cache.NewStore(pool, time.Minute, 1, time.Minute), cfgs, trustedProxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, mr
+}
+
+func TestClientIP(t *testing.T) {
+	l, _ := newTestLimiter(t, nil, "10.0.0.0/8, 192.168.1.1, 2001:db8::/32")
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct client", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"untrusted peer can't forward", "203.0.113.5:4000", "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy without header", "10.0.0.1:4000", "", "10.0.0.1"},
+		{"trusted proxy", "10.0.0.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"single trusted IP", "192.168.1.1:4000", "198.51.100.7", "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.1:4000", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"client made up the left hops", "10.0.0.1:4000", "1.1.1.1, 198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"only trusted hops", "10.0.0.1:4000", "10.0.0.2, 192.168.1.1", "10.0.0.1"},
+		{"garbage before the client", "10.0.0.1:4000", "garbage, 198.51.100.7", "198.51.100.7"},
+		{"garbage after the client", "10.0.0.1:4000", "198.51.100.7, garbage", "10.0.0.1"},
+		{"IPv6 client", "[2001:db9::1]:4000", "198.51.100.7", "2001:db9::1"},
+		{"IPv6 trusted proxy", "[2001:db8::1]:4000", "2001:db9::1", "2001:db9::1"},
+		{"no port", "203.0.113.5", "", "203.0.113.5"},
+	}
+
+	for _, tt := range tests {
+		if got := l.clientIP(tt.remoteAddr, tt.forwardedFor); got != tt.want {
+			t.Errorf("%s: clientIP(%q, %q) = %q, want %q", tt.name, tt.remoteAddr, tt.forwardedFor, got, tt.want)
+		}
+	}
+}
+
+func TestNewLimiterRejectsBadProxies(t *testing.T) {
+	if _, err := NewLimiter(nil, nil, "10.0.0.1, not-an-ip"); err == nil {
+		t.Error("NewLimiter() accepted an invalid trusted proxy")
+	}
+}
+
+// The bucket is driven with explicit timestamps so refilling doesn't
+// depend on the clock.
+func TestTakeScript(t *testing.T) {
+	l, _ := newTestLimiter(t, nil, "")
+
+	// Two tokens, refilled at one every 500ms.
+	const rate, capacity = 2.0, 2
+	take := func(now int64) []int64 {
+		values, err := redigo.Int64s(l.store.Eval(takeScript, "ratelimit:test:ip:1", rate, capacity, now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	steps := []struct {
+		now  int64
+		want [4]int64 // allowed, remaining, retry ms, full ms
+	}{
+		{1000, [4]int64{1, 1, 0, 500}},
+		{1000, [4]int64{1, 0, 0, 1000}},
+		{1000, [4]int64{0, 0, 500, 1000}},
+		{1250, [4]int64{0, 0, 250, 750}},
+		{1500, [4]int64{1, 0, 0, 1000}},
+		{5000, [4]int64{1, 1, 0, 500}},
+	}
+
+	for _, step := range steps {
+		got := take(step.now)
+		if len(got) != 4 || got[0] != step.want[0] || got[1] != step.want[1] || got[2] != step.want[2] || got[3] != step.want[3] {
+			t.Errorf("take at %dms = %v, want %v", step.now, got, step.want)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l, mr := newTestLimiter(t, map[string]*config.RateLimitConfig{
+		"api": {Requests: 2, Period: 60, KeyBy: KeyByIP},
+	}, "")
+
+	router := gin.New()
+	router.GET("/limited", l.Limit("api"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/unlimited", l.Limit("unconfigured"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := get("/limited", "203.0.113.5:4000")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %q", i+1, got, remaining)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 2", i+1, got)
+		}
+	}
+
+	w := get("/limited", "203.0.113.5:4000")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+
+	var body struct {
+		StatusCode int         `json:"status_code"`
+		Messages   []string    `json:"messages"`
+		Data       interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.StatusCode != apperror.TooManyRequests.Code || len(body.Messages) != 1 || body.Messages[0] != apperror.TooManyRequests.Message || body.Data != nil {
+		t.Errorf("429 body = %s, want the TooManyRequests envelope", w.Body)
+	}
+
+	if w := get("/limited", "198.51.100.7:4000"); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := get("/unlimited", "203.0.113.5:4000"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("unconfigured group: status = %d with limit headers %v, want no limit", w.Code, w.Header())
+	}
+
+	// Without Redis requests are let through rather than refused.
+	mr.Close()
+	for i := 0; i < 2; i++ {
+		if w := get("/limited", "203.0.113.5:4000"); w.Code != http.StatusOK {
+			t.Errorf("request without redis: status = %d, want %d", w.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// Package redistest runs an in-memory Redis for tests.
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/server"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// NewPool starts a miniredis server and returns it with a pool dialing it.
+// Both are closed when the test ends. The server may be closed earlier to
+// simulate an outage; the pool then fails to dial.
+//
+// miniredis lacks some commands, which the pool emulates:
+//
+//   - GEOADD and GEOSEARCH: members go in a sorted set under the key, as in
+//     Redis, and their positions in a hash under key + ":positions".
+//   - PUBLISH, SUBSCRIBE and PSUBSCRIBE, see NewPubSubPool.
+func NewPool(t testing.TB) (*redigo.Pool, *miniredis.Miniredis) {
+	pool, mr, _ := NewPubSubPool(t)
+	return pool, mr
+}
+
+// NewPubSubPool is NewPool along with the PubSub delivering what is
+// published, from commands or scripts, to the pool's subscribed
+// connections. PUBLISH is added to the server itself so scripts can call
+// it; a Restart of the server drops it.
+func NewPubSubPool(t testing.TB) (*redigo.Pool, *miniredis.Miniredis, *PubSub) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+
+	pubSub := &PubSub{subscribers: make(map[*conn]bool)}
+	if err := register(mr, "PUBLISH", pubSub.publishCommand); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := mr.Addr()
+	pool := &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			c, err := redigo.Dial("tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			return &conn{Conn: c, pubSub: pubSub}, nil
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	return pool, mr, pubSub
+}
+
+// register adds command to mr's server, which miniredis doesn't expose.
+func register(mr *miniredis.Miniredis, command string, handler server.Cmd) error {
+	field := reflect.ValueOf(mr).Elem().FieldByName("srv")
+	if !field.IsValid() || field.Type() != reflect.TypeOf((*server.Server)(nil)) {
+		return errors.New("redistest: miniredis has no srv field to register commands on")
+	}
+
+	srv := *(**server.Server)(unsafe.Pointer(field.UnsafeAddr()))
+	return srv.Register(command, handler)
+}
+
+// PubSub tracks the pool's subscribed connections.
+type PubSub struct {
+	mu          sync.Mutex
+	subscribers map[*conn]bool
+}
+
+// Subscribers returns how many connections are subscribed.
+func (p *PubSub) Subscribers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.subscribers)
+}
+
+// Drop cuts every subscribed connection, as a Redis restart would.
+func (p *PubSub) Drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for c := range p.subscribers {
+		c.broken = true
+		close(c.replies)
+		delete(p.subscribers, c)
+	}
+}
+
+func (p *PubSub) publishCommand(peer *server.Peer, command string, args []string) {
+	if len(args) != 2 {
+		peer.WriteError("ERR wrong number of arguments for 'publish' command")
+		return
+	}
+
+	peer.WriteInt(p.publish(args[0], args[1]))
+}
+
+func (p *PubSub) publish(channel, data string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	received := 0
+	for c := range p.subscribers {
+		if c.channels[channel] {
+			c.replies <- []interface{}{[]byte("message"), []byte(channel), []byte(data)}
+			received++
+		}
+		for pattern := range c.patterns {
+			if matched, _ := path.Match(pattern, channel); matched {
+				c.replies <- []interface{}{[]byte("pmessage"), []byte(pattern), []byte(channel), []byte(data)}
+				received++
+			}
+		}
+	}
+	return received
+}
+
+// conn sends the commands miniredis lacks to the emulations and the rest to
+// miniredis. Once subscribed it only reads from replies, and it is not
+// reused after unsubscribing.
+type conn struct {
+	redigo.Conn
+	pubSub *PubSub
+
+	// Guarded by pubSub.mu.
+	channels     map[string]bool
+	patterns     map[string]bool
+	replies      chan interface{}
+	broken       bool
+	unsubscribed bool
+}
+
+func (c *conn) Do(command string, args ...interface{}) (interface{}, error) {
+	if c.subscribed() {
+		return nil, c.Err()
+	}
+
+	switch strings.ToUpper(command) {
+	case "GEOADD":
+		return c.geoAdd(args)
+	case "GEOSEARCH":
+		return c.geoSearch(args)
+	}
+	return c.Conn.Do(command, args...)
+}
+
+func (c *conn) DoWithTimeout(timeout time.Duration, command string, args ...interface{}) (interface{}, error) {
+	return c.Do(command, args...)
+}
+
+func (c *conn) Send(command string, args ...interface{}) error {
+	c.pubSub.mu.Lock()
+	defer c.pubSub.mu.Unlock()
+
+	command = strings.ToUpper(command)
+	switch command {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		if c.replies == nil {
+			c.replies = make(chan interface{}, 1024)
+			c.channels = make(map[string]bool)
+			c.patterns = make(map[string]bool)
+		}
+		c.pubSub.subscribers[c] = true
+
+		for _, arg := range args {
+			name := fmt.Sprint(arg)
+			if command == "SUBSCRIBE" {
+				c.channels[name] = true
+			} else {
+				c.patterns[name] = true
+			}
+			c.replies <- []interface{}{[]byte(strings.ToLower(command)), []byte(name), int64(len(c.channels) + len(c.patterns))}
+		}
+		return nil
+
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		if c.replies != nil {
+			delete(c.pubSub.subscribers, c)
+			c.unsubscribed = true
+		}
+		return nil
+
+	case "PING", "ECHO":
+		if c.replies == nil {
+			break
+		}
+		if !c.broken {
+			var reply []byte
+			if len(args) > 0 {
+				reply, _ = redigo.Bytes(args[0], nil)
+				if reply == nil {
+					reply = []byte(fmt.Sprint(args[0]))
+				}
+			}
+			if command == "PING" {
+				c.replies <- []interface{}{[]byte("pong"), reply}
+			} else {
+				c.replies <- reply
+			}
+		}
+		return nil
+	}
+
+	if c.replies != nil {
+		return errors.New("redistest: only (P)SUBSCRIBE, (P)UNSUBSCRIBE, PING and ECHO are allowed while subscribed")
+	}
+	return c.Conn.Send(command, args...)
+}
+
+func (c *conn) Flush() error {
+	if c.subscribed() {
+		return c.Err()
+	}
+	return c.Conn.Flush()
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	return c.ReceiveWithTimeout(0)
+}
+
+func (c *conn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if !c.subscribed() {
+		return redigo.ReceiveWithTimeout(c.Conn, timeout)
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	select {
+	case reply, ok := <-c.replies:
+		if !ok {
+			return nil, errors.New("redistest: connection reset")
+		}
+		return reply, nil
+	case <-expired:
+		return nil, errors.New("redistest: i/o timeout")
+	}
+}
+
+// Err makes the pool discard a connection that was cut or unsubscribed.
+func (c *conn) Err() error {
+	c.pubSub.mu.Lock()
+	defer c.pubSub.mu.Unlock()
+
+	if c.broken {
+		return errors.New("redistest: connection reset")
+	}
+	if c.unsubscribed {
+		return errors.New("redistest: connection was subscribed")
+	}
+	return c.Conn.Err()
+}
+
+func (c *conn) Close() error {
+	c.pubSub.mu.Lock()
+	delete(c.pubSub.subscribers, c)
+	c.pubSub.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+func (c *conn) subscribed() bool {
+	c.pubSub.mu.Lock()
+	defer c.pubSub.mu.Unlock()
+
+	return c.replies != nil
+}
+
+// geoAdd takes key followed by longitude, latitude, member triples.
+func (c *conn) geoAdd(args []interface{}) (interface{}, error) {
+	if len(args) < 4 || (len(args)-1)%3 != 0 {
+		return nil, redigo.Error("ERR wrong number of arguments for 'geoadd' command")
+	}
+
+	key := args[0]
+	added := int64(0)
+	for i := 1; i < len(args); i += 3 {
+		long, lat, member := args[i], args[i+1], args[i+2]
+
+		n, err := redigo.Int64(c.Conn.Do("ZADD", key, 0, member))
+		if err != nil {
+			return nil, err
+		}
+		added += n
+
+		if _, err := c.Conn.Do("HSET", fmt.Sprint(key)+":positions", member, fmt.Sprint(lat, " ", long)); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+// geoSearch supports the FROMLONLAT, BYRADIUS in km, ASC, COUNT and
+// WITHDIST form of the command.
+func (c *conn) geoSearch(args []interface{}) (interface{}, error) {
+	if len(args) < 1 {
+		return nil, redigo.Error("ERR wrong number of arguments for 'geosearch' command")
+	}
+
+	key := args[0]
+	var lat, long, radiusKM float64
+	count := -1
+	withDist := false
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(fmt.Sprint(args[i])) {
+		case "FROMLONLAT":
+			long, lat = toFloat(args[i+1]), toFloat(args[i+2])
+			i += 2
+		case "BYRADIUS":
+			radiusKM = toFloat(args[i+1])
+			i += 2
+		case "COUNT":
+			count = int(toFloat(args[i+1]))
+			i++
+		case "WITHDIST":
+			withDist = true
+		}
+	}
+
+	members, err := redigo.Strings(c.Conn.Do("ZRANGE", key, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	type hit struct {
+		member     string
+		distanceKM float64
+	}
+	hits := []hit{}
+	for _, member := range members {
+		position, err := redigo.String(c.Conn.Do("HGET", fmt.Sprint(key)+":positions", member))
+		if err != nil {
+			return nil, err
+		}
+
+		var memberLat, memberLong float64
+		if _, err := fmt.Sscan(position, &memberLat, &memberLong); err != nil {
+			return nil, err
+		}
+
+		if distanceKM := distanceKM(lat, long, memberLat, memberLong); distanceKM <= radiusKM {
+			hits = append(hits, hit{member, distanceKM})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].distanceKM < hits[j].distanceKM })
+	if count >= 0 && len(hits) > count {
+		hits = hits[:count]
+	}
+
+	replies := make([]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		if !withDist {
+			replies = append(replies, []byte(hit.member))
+			continue
+		}
+		replies = append(replies, []interface{}{
+			[]byte(hit.member),
+			[]byte(strconv.FormatFloat(hit.distanceKM, 'f', 4, 64)),
+		})
+	}
+	return replies, nil
+}
+
+func toFloat(arg interface{}) float64 {
+	value, _ := strconv.ParseFloat(fmt.Sprint(arg), 64)
+	return value
+}
+
+// distanceKM is the haversine distance Redis uses, on its earth radius.
+func distanceKM(lat1, long1, lat2, long2 float64) float64 {
+	const earthRadiusKM = 6372.797560856
+
+	toRadians := func(degrees float64) float64 {
+		return degrees * math.Pi / 180
+	}
+
+	dLat := toRadians(lat2 - lat1)
+	dLong := toRadians(long2 - long1)
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Pow(math.Sin(dLong/2), 2)
+	return earthRadiusKM * 2 * math.Asin(math.Sqrt(a))
+}
@@ -4,19 +4,56 @@ import (
 	"time"
 )
 
+// Account roles, from least to most privileged. Curators manage the venue
+// catalog; admins can also manage accounts.
+const (
+	RoleUser    = "user"
+	RoleCurator = "curator"
+	RoleAdmin   = "admin"
+)
+
+// IsRole reports whether role is one of the account roles.
+func IsRole(role string) bool {
+	return role == RoleUser || role == RoleCurator || role == RoleAdmin
+}
+
 type Account struct {
-	AccountID int64     `json:"account_id"`
-	Email     string    `json:"user_email"`
-	Fullname  string    `json:"user_fullname"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	AccountID    int64      `json:"account_id"`
+	Email        string     `json:"user_email"`
+	Fullname     string     `json:"user_fullname"`
+	Role         string     `json:"user_role"`
+	PasswordHash string     `json:"-"`
+	VerifiedAt   *time.Time `json:"verified_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 type Accounts []*Account
 
-func NewAccount(email, fullname string) *Account {
+// AccountPage is one page of an account list. NextCursor is empty on the last
+// page.
+type AccountPage struct {
+	Accounts   Accounts `json:"accounts"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+// IsVerified reports whether the account confirmed its email address.
+func (a *Account) IsVerified() bool {
+	return a.VerifiedAt != nil
+}
+
+// IsDeactivated reports whether the account was deactivated and not
+// reactivated since.
+func (a *Account) IsDeactivated() bool {
+	return a.DeletedAt != nil
+}
+
+func NewAccount(email, fullname, passwordHash string) *Account {
 	return &Account{
-		Email:    email,
-		Fullname: fullname,
+		Email:        email,
+		Fullname:     fullname,
+		Role:         RoleUser,
+		PasswordHash: passwordHash,
 	}
 }
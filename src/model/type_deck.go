@@ -0,0 +1,46 @@
+package model
+
+// DeckCard is one venue in an account's swipe deck. Type is the venue type,
+// saying which of Restaurant and Recreation is set; Score is how well the
+// venue suits the account, higher first.
+type DeckCard struct {
+	Type       string      `json:"type"`
+	DistanceKM float64     `json:"distance_km"`
+	Score      float64     `json:"score"`
+	Restaurant *Restaurant `json:"restaurant,omitempty"`
+	Recreation *Recreation `json:"recreation,omitempty"`
+}
+
+type DeckCards []*DeckCard
+
+// VenueID returns the ID of the card's venue.
+func (c *DeckCard) VenueID() int64 {
+	if c.Restaurant != nil {
+		return c.Restaurant.RestaurantID
+	}
+	return c.Recreation.RecreationID
+}
+
+// Price returns the price of the card's venue.
+func (c *DeckCard) Price() int {
+	if c.Restaurant != nil {
+		return c.Restaurant.RestaurantPrice
+	}
+	return c.Recreation.RecreationPrice
+}
+
+// TimeMinute returns how long the card's venue takes.
+func (c *DeckCard) TimeMinute() int {
+	if c.Restaurant != nil {
+		return c.Restaurant.RestaurantTimeMinute
+	}
+	return c.Recreation.RecreationTimeMinute
+}
+
+// City returns the city of the card's venue.
+func (c *DeckCard) City() string {
+	if c.Restaurant != nil {
+		return c.Restaurant.RestaurantCity
+	}
+	return c.Recreation.RecreationCity
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	GroupEventMemberJoined = "member_joined"
+	GroupEventSwipe        = "swipe"
+	GroupEventMatch        = "match"
+)
+
+// GroupSession is a group of accounts swiping on one shared deck. A venue
+// is a match once Quorum members liked it; a Quorum of 0 means every
+// member.
+type GroupSession struct {
+	SessionID  string    `json:"session_id"`
+	InviteCode string    `json:"invite_code"`
+	OwnerID    int64     `json:"owner_id"`
+	Quorum     int       `json:"quorum"`
+	Lat        float64   `json:"lat"`
+	Long       float64   `json:"long"`
+	Members    []int64   `json:"members"`
+	Matches    DeckCards `json:"matches"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewGroupSession(ownerID int64, quorum int, lat, long float64) *GroupSession {
+	return &GroupSession{
+		OwnerID:   ownerID,
+		Quorum:    quorum,
+		Lat:       lat,
+		Long:      long,
+		Members:   []int64{ownerID},
+		Matches:   DeckCards{},
+		CreatedAt: time.Now(),
+	}
+}
+
+// GroupSwipeResult tells a member how their swipe stands: how many members
+// like the venue, how many are needed, and whether it just became a match.
+type GroupSwipeResult struct {
+	Match  bool      `json:"match"`
+	Likes  int       `json:"likes"`
+	Quorum int       `json:"quorum"`
+	Card   *DeckCard `json:"card"`
+}
+
+// GroupEvent is something that happened in a session, streamed to its
+// members. IDs increase per session so clients can resume after the last
+// one they saw.
+type GroupEvent struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// GroupMemberJoined is the data of a member_joined event.
+type GroupMemberJoined struct {
+	AccountID int64 `json:"account_id"`
+	Members   int   `json:"members"`
+}
+
+// GroupSwipeProgress is the data of a swipe event. It leaves out the
+// direction, which only the likes count hints at.
+type GroupSwipeProgress struct {
+	AccountID int64  `json:"account_id"`
+	VenueType string `json:"venue_type"`
+	VenueID   int64  `json:"venue_id"`
+	Likes     int    `json:"likes"`
+	Quorum    int    `json:"quorum"`
+}
@@ -15,6 +15,7 @@ type Recreation struct {
 	RecreationImage       string    `json:"recreation_image"`
 	RecreationDescription string    `json:"recreation_description"`
 	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 type Recreations []*Recreation
@@ -31,3 +32,19 @@ func NewRecreation(recreationName, recreationCity, recreationImage, recreationDe
 		RecreationDescription: recreationDescription,
 	}
 }
+
+// RecreationPage is one page of a recreation list. NextCursor is empty on the
+// last page.
+type RecreationPage struct {
+	Recreations Recreations `json:"recreations"`
+	NextCursor  string      `json:"next_cursor"`
+}
+
+// NearbyRecreation is a recreation found by a nearby search, with its
+// distance from the search center.
+type NearbyRecreation struct {
+	Recreation
+	DistanceKM float64 `json:"distance_km"`
+}
+
+type NearbyRecreations []*NearbyRecreation
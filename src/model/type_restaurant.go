@@ -15,6 +15,7 @@ type Restaurant struct {
 	RestaurantImage       string    `json:"restaurant_image"`
 	RestaurantDescription string    `json:"restaurant_description"`
 	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 type Restaurants []*Restaurant
@@ -31,3 +32,19 @@ func NewRestaurant(restaurantName, restaurantCity, restaurantImage, restaurantDe
 		RestaurantDescription: restaurantDescription,
 	}
 }
+
+// RestaurantPage is one page of a restaurant list. NextCursor is empty on the
+// last page.
+type RestaurantPage struct {
+	Restaurants Restaurants `json:"restaurants"`
+	NextCursor  string      `json:"next_cursor"`
+}
+
+// NearbyRestaurant is a restaurant found by a nearby search, with its
+// distance from the search center.
+type NearbyRestaurant struct {
+	Restaurant
+	DistanceKM float64 `json:"distance_km"`
+}
+
+type NearbyRestaurants []*NearbyRestaurant
@@ -0,0 +1,12 @@
+package model
+
+// SearchResult is one search hit. Type is the venue type, saying which of
+// Restaurant and Recreation is set; Score is its relevance, higher first.
+type SearchResult struct {
+	Type       string      `json:"type"`
+	Score      float64     `json:"score"`
+	Restaurant *Restaurant `json:"restaurant,omitempty"`
+	Recreation *Recreation `json:"recreation,omitempty"`
+}
+
+type SearchResults []*SearchResult
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+)
+
+// Swipe directions. Likes and superlikes count as liking the venue.
+const (
+	SwipeLike      = "like"
+	SwipeDislike   = "dislike"
+	SwipeSuperlike = "superlike"
+)
+
+// Swipe is an account's decision on one venue. An account has at most one
+// swipe per venue; swiping again changes its direction.
+type Swipe struct {
+	SwipeID   int64     `json:"swipe_id"`
+	AccountID int64     `json:"account_id"`
+	VenueType string    `json:"venue_type"`
+	VenueID   int64     `json:"venue_id"`
+	Direction string    `json:"direction"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewSwipe(accountID int64, venueType string, venueID int64, direction string) *Swipe {
+	return &Swipe{
+		AccountID: accountID,
+		VenueType: venueType,
+		VenueID:   venueID,
+		Direction: direction,
+	}
+}
+
+// IsLike reports whether the swipe likes its venue.
+func (s *Swipe) IsLike() bool {
+	return s.Direction == SwipeLike || s.Direction == SwipeSuperlike
+}
+
+// LikedRestaurant is a restaurant an account liked, with how and when.
+type LikedRestaurant struct {
+	Restaurant
+	Direction string    `json:"direction"`
+	SwipedAt  time.Time `json:"swiped_at"`
+}
+
+// LikedRestaurantPage is one page of an account's liked restaurants.
+type LikedRestaurantPage struct {
+	Restaurants []*LikedRestaurant `json:"restaurants"`
+	NextCursor  string             `json:"next_cursor"`
+}
+
+// LikedRecreation is a recreation an account liked, with how and when.
+type LikedRecreation struct {
+	Recreation
+	Direction string    `json:"direction"`
+	SwipedAt  time.Time `json:"swiped_at"`
+}
+
+// LikedRecreationPage is one page of an account's liked recreations.
+type LikedRecreationPage struct {
+	Recreations []*LikedRecreation `json:"recreations"`
+	NextCursor  string             `json:"next_cursor"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// Venue types, naming the module a venue belongs to wherever restaurants and
+// recreations are handled together.
+const (
+	VenueTypeRestaurant = "restaurant"
+	VenueTypeRecreation = "recreation"
+)
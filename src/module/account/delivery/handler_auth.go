@@ -0,0 +1,460 @@
+package delivery
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/ratelimit"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/account"
+	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	au        account.Usecase
+	tokenizer *auth.Tokenizer
+	sessions  *auth.SessionStore
+	oidc      *auth.OIDC
+}
+
+func NewAuthHandler(router *gin.Engine, m *auth.Middleware, rl *ratelimit.Limiter, tokenizer *auth.Tokenizer, sessions *auth.SessionStore, oidc *auth.OIDC, au account.Usecase) *gin.Engine {
+	handler := &AuthHandler{au, tokenizer, sessions, oidc}
+
+	v1 := router.Group("/api/v1/auth", rl.Limit("auth"))
+	v1.POST("/login", handler.LoginEndpoint())
+	v1.POST("/refresh", handler.RefreshEndpoint())
+	v1.POST("/reactivate", handler.ReactivateEndpoint())
+	v1.POST("/verify-email", handler.VerifyEmailEndpoint())
+	v1.POST("/password/forgot", handler.ForgotPasswordEndpoint())
+	v1.POST("/password/reset", handler.ResetPasswordEndpoint())
+	v1.GET("/oidc/:provider/start", handler.StartOIDCEndpoint())
+	v1.GET("/oidc/:provider/callback", handler.OIDCCallbackEndpoint())
+
+	v1.Use(m.AuthUserToken())
+	{
+		v1.POST("/logout", handler.LogoutEndpoint())
+		v1.POST("/logout-all", handler.LogoutAllEndpoint())
+		v1.POST("/verify-email/request", handler.RequestVerifyEmailEndpoint())
+	}
+
+	return router
+}
+
+type loginRequest struct {
+	Email    string `json:"user_email" form:"user_email" binding:"required,email"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" binding:"required"`
+}
+
+type actionTokenRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"user_email" form:"user_email" binding:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" form:"token" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required,min=8,max=72"`
+}
+
+type oidcCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+type oidcStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type tokenResponse struct {
+	AccountID    int64  `json:"account_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// issueTokens signs an access token for the session and pairs it with the
+// session's current refresh token.
+func (h *AuthHandler) issueTokens(account *model.Account, sessionID, refreshToken string) (*tokenResponse, error) {
+	accessToken, claims, err := h.tokenizer.Issue(account, sessionID)
+	if err != nil {
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return &tokenResponse{
+		AccountID:    account.AccountID,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    claims.ExpiresAt - claims.IssuedAt,
+	}, nil
+}
+
+// startSession logs account in on a new session and responds with its
+// tokens.
+func (h *AuthHandler) startSession(c *gin.Context, startTime time.Time, account *model.Account, message string) {
+	sessionID, refreshToken, err := h.sessions.Create(account.AccountID)
+	if err != nil {
+		log.Println(err)
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteErrorResponse(c, processTime, apperror.InternalServerError.Wrap(err))
+		return
+	}
+
+	resp, err := h.issueTokens(account, sessionID, refreshToken)
+	if err != nil {
+		log.Println(err)
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteErrorResponse(c, processTime, err)
+		return
+	}
+
+	processTime := time.Now().Sub(startTime).Seconds()
+	httputil.WriteResponse(c, []string{message}, processTime, resp)
+}
+
+func (h *AuthHandler) LoginEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := loginRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		account, err := h.au.Authenticate(req.Email, req.Password)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		h.startSession(c, startTime, account, "Success login")
+	}
+}
+
+func (h *AuthHandler) RefreshEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := refreshRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, sessionID, refreshToken, err := h.sessions.Rotate(req.RefreshToken)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		// The role is read again so a promotion or demotion applies from
+		// the next refresh on.
+		account, err := h.au.GetAccount(accountID)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		resp, err := h.issueTokens(account, sessionID, refreshToken)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success refresh token"}, processTime, resp)
+	}
+}
+
+func (h *AuthHandler) LogoutEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		claims, _ := auth.ClaimsFromContext(c)
+		if err := h.sessions.Revoke(claims.AccountID, claims.SessionID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.InternalServerError.Wrap(err))
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success logout"}, processTime, nil)
+	}
+}
+
+func (h *AuthHandler) LogoutAllEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, _ := auth.AccountID(c)
+		if err := h.sessions.RevokeAll(accountID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.InternalServerError.Wrap(err))
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success logout from all devices"}, processTime, nil)
+	}
+}
+
+func (h *AuthHandler) RequestVerifyEmailEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, _ := auth.AccountID(c)
+		if err := h.au.SendVerificationEmail(accountID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success send verification email"}, processTime, nil)
+	}
+}
+
+func (h *AuthHandler) VerifyEmailEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := actionTokenRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if err := h.au.VerifyEmail(req.Token); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success verify email"}, processTime, nil)
+	}
+}
+
+func (h *AuthHandler) ForgotPasswordEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := forgotPasswordRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if err := h.au.SendPasswordReset(req.Email); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"If the email has an account, a reset link was sent"}, processTime, nil)
+	}
+}
+
+func (h *AuthHandler) ResetPasswordEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := resetPasswordRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if err := h.au.ResetPassword(req.Token, req.Password); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success reset password"}, processTime, nil)
+	}
+}
+
+// StartOIDCEndpoint returns the provider URL the client should open to log
+// in, and sets the cookie binding the login to this browser. The provider
+// then redirects to OIDCCallbackEndpoint.
+func (h *AuthHandler) StartOIDCEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		authorizationURL, binding, err := h.oidc.Start(c.Param("provider"))
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		http.SetCookie(c.Writer, binding)
+
+		resp := oidcStartResponse{
+			AuthorizationURL: authorizationURL,
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success start login"}, processTime, resp)
+	}
+}
+
+// OIDCCallbackEndpoint finishes a login only in the browser that started
+// it, and drops the binding cookie whatever the outcome.
+func (h *AuthHandler) OIDCCallbackEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		if clear := h.oidc.ClearBinding(c.Param("provider")); clear != nil {
+			http.SetCookie(c.Writer, clear)
+		}
+
+		if providerError := c.Query("error"); providerError != "" {
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.LoginProviderFailed.Wrap(errors.New(providerError)))
+			return
+		}
+
+		req := oidcCallbackRequest{
+			Code:  c.Query("code"),
+			State: c.Query("state"),
+		}
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		binding := ""
+		if cookie, err := c.Request.Cookie(auth.OIDCBindingCookie); err == nil {
+			binding = cookie.Value
+		}
+
+		identity, err := h.oidc.Finish(c.Request.Context(), c.Param("provider"), req.State, req.Code, binding)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		account, err := h.au.AuthenticateIdentity(identity)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		h.startSession(c, startTime, account, "Success login")
+	}
+}
+
+// ReactivateEndpoint brings back a deactivated account and logs it in.
+func (h *AuthHandler) ReactivateEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := loginRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		account, err := h.au.ReactivateAccount(req.Email, req.Password)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		h.startSession(c, startTime, account, "Success reactivate account")
+	}
+}
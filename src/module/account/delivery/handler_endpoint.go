@@ -5,36 +5,50 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/atletaid/go-template/src/common/apperror"
 	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/common/ratelimit"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/account"
 	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
 )
 
 type AccountHandler struct {
 	au account.Usecase
 }
 
-func NewAccountHandler(router *gin.Engine, m *auth.Middleware, au account.Usecase) *gin.Engine {
+func NewAccountHandler(router *gin.Engine, m *auth.Middleware, rl *ratelimit.Limiter, au account.Usecase) *gin.Engine {
 	handler := &AccountHandler{au}
 
 	v1 := router.Group("/api/v1")
-	v1.POST("/account", handler.CreateAccountEndpoint())
-	v1.GET("/account/:account_id", handler.GetAccountEndpoint())
+	v1.POST("/account", rl.Limit("signup"), handler.CreateAccountEndpoint())
 
 	v1.Use(m.AuthUserToken())
 	{
-		v1.GET("/accounts", handler.GetAccountsEndpoint())
+		v1.GET("/accounts", m.RequireRole(model.RoleAdmin), handler.GetAccountsEndpoint())
+		v1.GET("/account/:account_id", handler.GetAccountEndpoint())
 		v1.PUT("/account/:account_id", handler.UpdateAccountEndpoint())
+		v1.PUT("/account/:account_id/role", m.RequireRole(model.RoleAdmin), handler.ChangeRoleEndpoint())
+		v1.POST("/account/:account_id/deactivate", handler.DeactivateAccountEndpoint())
+		v1.POST("/account/:account_id/restore", m.RequireRole(model.RoleAdmin), handler.RestoreAccountEndpoint())
 	}
 
 	return router
 }
 
+// canManageAccount reports whether the caller may see or change accountID:
+// users may only manage themselves, admins anyone.
+func canManageAccount(c *gin.Context, accountID int64) bool {
+	callerID, _ := auth.AccountID(c)
+	return callerID == accountID || auth.HasRole(c, model.RoleAdmin)
+}
+
 type createAccountRequest struct {
-	Email    string `json:"user_email" form:"user_email"`
-	Fullname string `json:"user_fullname" form:"user_fullname"`
+	Email    string `json:"user_email" form:"user_email" binding:"required,email,max=255"`
+	Fullname string `json:"user_fullname" form:"user_fullname" binding:"required,max=255"`
+	Password string `json:"password" form:"password" binding:"required,min=8,max=72"`
 }
 
 type createAccountResponse struct {
@@ -53,7 +67,14 @@ func (h *AccountHandler) CreateAccountEndpoint() gin.HandlerFunc {
 			return
 		}
 
-		accountID, err := h.au.CreateAccount(req.Email, req.Fullname)
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, err := h.au.CreateAccount(req.Email, req.Fullname, req.Password)
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
@@ -82,7 +103,13 @@ func (h *AccountHandler) GetAccountEndpoint() gin.HandlerFunc {
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
-			httputil.WriteErrorResponse(c, processTime, err)
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		if !canManageAccount(c, accountID) {
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.Forbidden)
 			return
 		}
 
@@ -103,15 +130,28 @@ func (h *AccountHandler) GetAccountEndpoint() gin.HandlerFunc {
 	}
 }
 
-type dataAccountsResponse struct {
-	Accounts model.Accounts `json:"accounts"`
-}
-
+// GetAccountsEndpoint lists accounts one page at a time. It takes the limit,
+// sort and cursor parameters of listing.Request.
 func (h *AccountHandler) GetAccountsEndpoint() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 
-		accounts, err := h.au.GetAccounts()
+		req := listing.Request{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.DecodeError.Wrap(err))
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		query, err := req.Query(account.ListSorts)
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
@@ -119,18 +159,22 @@ func (h *AccountHandler) GetAccountsEndpoint() gin.HandlerFunc {
 			return
 		}
 
-		resp := dataAccountsResponse{
-			Accounts: accounts,
+		page, err := h.au.GetAccounts(query)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
 		}
 
 		processTime := time.Now().Sub(startTime).Seconds()
-		httputil.WriteResponse(c, []string{"Success get accounts"}, processTime, resp)
+		httputil.WriteResponse(c, []string{"Success get accounts"}, processTime, page)
 	}
 }
 
 type updateAccountRequest struct {
-	Email    string `json:"user_email" form:"user_email"`
-	Fullname string `json:"user_fullname" form:"user_fullname"`
+	Email    string `json:"user_email" form:"user_email" binding:"required,email,max=255"`
+	Fullname string `json:"user_fullname" form:"user_fullname" binding:"required,max=255"`
 }
 
 func (h *AccountHandler) UpdateAccountEndpoint() gin.HandlerFunc {
@@ -141,7 +185,13 @@ func (h *AccountHandler) UpdateAccountEndpoint() gin.HandlerFunc {
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
-			httputil.WriteErrorResponse(c, processTime, err)
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		if !canManageAccount(c, accountID) {
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.Forbidden)
 			return
 		}
 
@@ -153,6 +203,13 @@ func (h *AccountHandler) UpdateAccountEndpoint() gin.HandlerFunc {
 			return
 		}
 
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
 		err = h.au.UpdateAccount(accountID, req.Email, req.Fullname)
 		if err != nil {
 			log.Println(err)
@@ -165,3 +222,102 @@ func (h *AccountHandler) UpdateAccountEndpoint() gin.HandlerFunc {
 		httputil.WriteResponse(c, []string{"Success update account"}, processTime, nil)
 	}
 }
+
+type changeRoleRequest struct {
+	Role string `json:"user_role" form:"user_role" binding:"required"`
+}
+
+// ChangeRoleEndpoint lets an admin promote or demote an account. The new
+// role applies from the account's next token refresh.
+func (h *AccountHandler) ChangeRoleEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, err := strconv.ParseInt(c.Param("account_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		req := changeRoleRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if err := h.au.ChangeRole(accountID, req.Role); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success change account role"}, processTime, nil)
+	}
+}
+
+func (h *AccountHandler) DeactivateAccountEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, err := strconv.ParseInt(c.Param("account_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		if !canManageAccount(c, accountID) {
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.Forbidden)
+			return
+		}
+
+		if err := h.au.DeactivateAccount(accountID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success deactivate account"}, processTime, nil)
+	}
+}
+
+func (h *AccountHandler) RestoreAccountEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, err := strconv.ParseInt(c.Param("account_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		if err := h.au.RestoreAccount(accountID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success restore account"}, processTime, nil)
+	}
+}
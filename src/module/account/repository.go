@@ -1,12 +1,28 @@
 package account
 
 import (
+	"time"
+
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 )
 
+// ListSorts are what account list queries may sort on, the first being the
+// default. Accounts take no filters.
+var ListSorts = []string{"id", "created_at"}
+
 type AccountRepository interface {
 	Create(account *model.Account) (int64, error)
 	FindByID(accountID int64) (*model.Account, error)
-	FindAll() (model.Accounts, error)
+	FindByEmail(email string) (*model.Account, error)
+	FindByIdentity(provider, subject string) (*model.Account, error)
+	FindAll(query *listing.Query) (*model.AccountPage, error)
 	Update(account *model.Account) error
+	UpdatePassword(accountID int64, passwordHash string) error
+	UpdateRole(accountID int64, role string) error
+	MarkVerified(accountID int64) error
+	LinkIdentity(accountID int64, provider, subject string) error
+	Deactivate(accountID int64) error
+	Reactivate(accountID int64) error
+	PurgeDeactivated(deletedBefore time.Time) (int64, error)
 }
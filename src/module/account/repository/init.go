@@ -5,10 +5,11 @@ import (
 	"time"
 
 	redigo "github.com/gomodule/redigo/redis"
-	cache "github.com/patrickmn/go-cache"
 )
 
-func NewPool(host string, dialTimeout time.Duration, idleTimeout time.Duration, poolSize int) (*redigo.Pool, error) {
+// NewPool never fails: Redis being down at startup only means the caches run
+// in memory until it comes back.
+func NewPool(host string, dialTimeout time.Duration, idleTimeout time.Duration, poolSize int) *redigo.Pool {
 	pool := redigo.Pool{
 		MaxActive:   poolSize,
 		MaxIdle:     poolSize,
@@ -23,18 +24,12 @@ func NewPool(host string, dialTimeout time.Duration, idleTimeout time.Duration,
 		},
 	}
 
-	if _, err := pool.Dial(); err != nil {
-		pool.Close()
-		log.Println(err)
-		return nil, err
+	conn, err := pool.Dial()
+	if err != nil {
+		log.Println("redis unavailable, starting with in-memory cache only:", err)
+		return &pool
 	}
+	conn.Close()
 
-	return &pool, nil
-}
-
-func NewAccountCache(cExpiration time.Duration, cIntervalPurges time.Duration) map[string]*cache.Cache {
-	return map[string]*cache.Cache{
-		KeyAccountsFind:    cache.New(cExpiration*time.Minute, cIntervalPurges*time.Minute),
-		KeyAccountsFindAll: cache.New(cExpiration*time.Minute, cIntervalPurges*time.Minute),
-	}
+	return &pool
 }
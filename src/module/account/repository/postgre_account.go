@@ -4,26 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"log"
+	"strconv"
 	"time"
 
-	"github.com/lib/pq"
 	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/account"
-	"github.com/tokopedia/sqlt"
+	"github.com/lib/pq"
 )
 
 type postgreAccountRepo struct {
-	DbMaster *sqlt.DB
-	DbSlave  *sqlt.DB
-	Timeout  time.Duration
+	DB      *database.DB
+	Timeout time.Duration
 }
 
-func NewAccountRepository(dbMaster *sqlt.DB, dbSlave *sqlt.DB, timeout time.Duration) account.AccountRepository {
+func NewAccountRepository(db *database.DB, timeout time.Duration) account.AccountRepository {
 	return &postgreAccountRepo{
-		DbMaster: dbMaster,
-		DbSlave:  dbSlave,
-		Timeout:  timeout,
+		DB:      db,
+		Timeout: timeout,
 	}
 }
 
@@ -37,6 +37,8 @@ func (repo *postgreAccountRepo) Create(account *model.Account) (int64, error) {
 		(
 			user_email,
 			user_fullname,
+			user_role,
+			password_hash,
 			created_at,
 			updated_at
 		)
@@ -44,6 +46,8 @@ func (repo *postgreAccountRepo) Create(account *model.Account) (int64, error) {
 		(
 			$1,
 			$2,
+			$3,
+			$4,
 			now(),
 			now()
 		)
@@ -53,15 +57,22 @@ func (repo *postgreAccountRepo) Create(account *model.Account) (int64, error) {
 
 	var lastInsertID int64
 
-	err := repo.DbMaster.QueryRowContext(
+	err := repo.DB.Writer(database.ScopeAccounts).QueryRowContext(
 		ctx,
 		query,
 		account.Email,
 		account.Fullname,
+		account.Role,
+		account.PasswordHash,
 	).Scan(&lastInsertID)
+	if database.IsUniqueViolation(err) {
+		log.Println(err)
+		return 0, apperror.AccountExists.Wrap(err)
+	}
+
 	if err != nil {
 		log.Println(err)
-		return 0, apperror.InternalServerError
+		return 0, apperror.InternalServerError.Wrap(err)
 	}
 
 	return lastInsertID, nil
@@ -76,26 +87,33 @@ func (repo *postgreAccountRepo) FindByID(accountID int64) (*model.Account, error
 			account_id,
 			user_email,
 			user_fullname,
+			user_role,
+			verified_at,
 			created_at,
 			updated_at
 		FROM
 			accounts
 		WHERE
-			account_id = $1
+			account_id = $1 AND
+			deleted_at IS NULL
 	`
 
 	var (
-		aAccountID sql.NullInt64
-		aEmail     sql.NullString
-		aFullname  sql.NullString
-		aCreatedAt pq.NullTime
-		aUpdatedAt pq.NullTime
+		aAccountID  sql.NullInt64
+		aEmail      sql.NullString
+		aFullname   sql.NullString
+		aRole       sql.NullString
+		aVerifiedAt pq.NullTime
+		aCreatedAt  pq.NullTime
+		aUpdatedAt  pq.NullTime
 	)
 
-	err := repo.DbSlave.QueryRowContext(ctx, query, accountID).Scan(
+	err := repo.DB.Reader(database.ScopeAccounts).QueryRowContext(ctx, query, accountID).Scan(
 		&aAccountID,
 		&aEmail,
 		&aFullname,
+		&aRole,
+		&aVerifiedAt,
 		&aCreatedAt,
 		&aUpdatedAt,
 	)
@@ -107,21 +125,23 @@ func (repo *postgreAccountRepo) FindByID(accountID int64) (*model.Account, error
 
 	if err != nil {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
 
 	account := model.Account{
-		AccountID: aAccountID.Int64,
-		Email:     aEmail.String,
-		Fullname:  aFullname.String,
-		CreatedAt: aCreatedAt.Time,
-		UpdatedAt: aUpdatedAt.Time,
+		AccountID:  aAccountID.Int64,
+		Email:      aEmail.String,
+		Fullname:   aFullname.String,
+		Role:       aRole.String,
+		VerifiedAt: nullTime(aVerifiedAt),
+		CreatedAt:  aCreatedAt.Time,
+		UpdatedAt:  aUpdatedAt.Time,
 	}
 
 	return &account, nil
 }
 
-func (repo *postgreAccountRepo) FindAll() (model.Accounts, error) {
+func (repo *postgreAccountRepo) FindByEmail(email string) (*model.Account, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
@@ -130,51 +150,226 @@ func (repo *postgreAccountRepo) FindAll() (model.Accounts, error) {
 			account_id,
 			user_email,
 			user_fullname,
+			user_role,
+			password_hash,
+			verified_at,
+			deleted_at,
 			created_at,
 			updated_at
 		FROM
 			accounts
+		WHERE
+			lower(user_email) = lower($1)
+	`
+
+	var (
+		aAccountID    sql.NullInt64
+		aEmail        sql.NullString
+		aFullname     sql.NullString
+		aRole         sql.NullString
+		aPasswordHash sql.NullString
+		aVerifiedAt   pq.NullTime
+		aDeletedAt    pq.NullTime
+		aCreatedAt    pq.NullTime
+		aUpdatedAt    pq.NullTime
+	)
+
+	err := repo.DB.Reader(database.ScopeAccounts).QueryRowContext(ctx, query, email).Scan(
+		&aAccountID,
+		&aEmail,
+		&aFullname,
+		&aRole,
+		&aPasswordHash,
+		&aVerifiedAt,
+		&aDeletedAt,
+		&aCreatedAt,
+		&aUpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.AccountNotExists
+	}
+
+	if err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	account := model.Account{
+		AccountID:    aAccountID.Int64,
+		Email:        aEmail.String,
+		Fullname:     aFullname.String,
+		Role:         aRole.String,
+		PasswordHash: aPasswordHash.String,
+		VerifiedAt:   nullTime(aVerifiedAt),
+		DeletedAt:    nullTime(aDeletedAt),
+		CreatedAt:    aCreatedAt.Time,
+		UpdatedAt:    aUpdatedAt.Time,
+	}
+
+	return &account, nil
+}
+
+// FindByIdentity returns the account linked to a login provider's subject.
+func (repo *postgreAccountRepo) FindByIdentity(provider, subject string) (*model.Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			a.account_id,
+			a.user_email,
+			a.user_fullname,
+			a.user_role,
+			a.verified_at,
+			a.deleted_at,
+			a.created_at,
+			a.updated_at
+		FROM
+			accounts a
+		JOIN
+			account_identities i ON i.account_id = a.account_id
+		WHERE
+			i.provider = $1 AND
+			i.subject = $2
 	`
 
-	rows, err := repo.DbSlave.QueryContext(ctx, query)
+	var (
+		aAccountID  sql.NullInt64
+		aEmail      sql.NullString
+		aFullname   sql.NullString
+		aRole       sql.NullString
+		aVerifiedAt pq.NullTime
+		aDeletedAt  pq.NullTime
+		aCreatedAt  pq.NullTime
+		aUpdatedAt  pq.NullTime
+	)
+
+	err := repo.DB.Reader(database.ScopeAccounts).QueryRowContext(ctx, query, provider, subject).Scan(
+		&aAccountID,
+		&aEmail,
+		&aFullname,
+		&aRole,
+		&aVerifiedAt,
+		&aDeletedAt,
+		&aCreatedAt,
+		&aUpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.AccountNotExists
+	}
+
+	if err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	account := model.Account{
+		AccountID:  aAccountID.Int64,
+		Email:      aEmail.String,
+		Fullname:   aFullname.String,
+		Role:       aRole.String,
+		VerifiedAt: nullTime(aVerifiedAt),
+		DeletedAt:  nullTime(aDeletedAt),
+		CreatedAt:  aCreatedAt.Time,
+		UpdatedAt:  aUpdatedAt.Time,
+	}
+
+	return &account, nil
+}
+
+var accountColumns = listing.Columns{
+	ID: "account_id",
+	Sorts: map[string]string{
+		"id":         "account_id",
+		"created_at": "created_at",
+	},
+}
+
+func (repo *postgreAccountRepo) FindAll(listQuery *listing.Query) (*model.AccountPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	clauses, args := listQuery.SQL(accountColumns, []string{"deleted_at IS NULL"}, nil)
+	query := `
+		SELECT
+			account_id,
+			user_email,
+			user_fullname,
+			user_role,
+			verified_at,
+			created_at,
+			updated_at
+		FROM
+			accounts
+	` + clauses
+
+	rows, err := repo.DB.Reader(database.ScopeAccounts).QueryContext(ctx, query, args...)
 	if err != nil && err != sql.ErrNoRows {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
+	defer rows.Close()
 
 	accounts := make(model.Accounts, 0)
 	for rows.Next() {
 		var (
-			aAccountID sql.NullInt64
-			aEmail     sql.NullString
-			aFullname  sql.NullString
-			aCreatedAt pq.NullTime
-			aUpdatedAt pq.NullTime
+			aAccountID  sql.NullInt64
+			aEmail      sql.NullString
+			aFullname   sql.NullString
+			aRole       sql.NullString
+			aVerifiedAt pq.NullTime
+			aCreatedAt  pq.NullTime
+			aUpdatedAt  pq.NullTime
 		)
 
 		if err := rows.Scan(
 			&aAccountID,
 			&aEmail,
 			&aFullname,
+			&aRole,
+			&aVerifiedAt,
 			&aCreatedAt,
 			&aUpdatedAt,
 		); err != nil {
 			log.Println(err)
-			return nil, apperror.InternalServerError
+			return nil, apperror.InternalServerError.Wrap(err)
 		}
 
 		account := model.Account{
-			AccountID: aAccountID.Int64,
-			Email:     aEmail.String,
-			Fullname:  aFullname.String,
-			CreatedAt: aCreatedAt.Time,
-			UpdatedAt: aUpdatedAt.Time,
+			AccountID:  aAccountID.Int64,
+			Email:      aEmail.String,
+			Fullname:   aFullname.String,
+			Role:       aRole.String,
+			VerifiedAt: nullTime(aVerifiedAt),
+			CreatedAt:  aCreatedAt.Time,
+			UpdatedAt:  aUpdatedAt.Time,
 		}
 
 		accounts = append(accounts, &account)
 	}
 
-	return accounts, nil
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	page := &model.AccountPage{Accounts: accounts}
+	if len(accounts) > listQuery.Limit {
+		page.Accounts = accounts[:listQuery.Limit]
+		last := page.Accounts[listQuery.Limit-1]
+
+		value := strconv.FormatInt(last.AccountID, 10)
+		if listQuery.Sort == "created_at" {
+			value = listing.TimeValue(last.CreatedAt)
+		}
+		page.NextCursor = listQuery.NextCursor(value, last.AccountID)
+	}
+
+	return page, nil
 }
 
 func (repo *postgreAccountRepo) Update(account *model.Account) error {
@@ -187,21 +382,235 @@ func (repo *postgreAccountRepo) Update(account *model.Account) error {
 		SET
 			user_email = $2,
 			user_fullname = $3,
+			verified_at = CASE WHEN lower(user_email) = lower($2) THEN verified_at END,
 			updated_at = now()
 		WHERE
-			account_id = $1
+			account_id = $1 AND
+			deleted_at IS NULL
 	`
 
-	if _, err := repo.DbMaster.ExecContext(
+	_, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(
 		ctx,
 		query,
 		account.AccountID,
 		account.Email,
 		account.Fullname,
-	); err != nil {
+	)
+	if database.IsUniqueViolation(err) {
+		log.Println(err)
+		return apperror.AccountExists.Wrap(err)
+	}
+
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return nil
+}
+
+func (repo *postgreAccountRepo) UpdatePassword(accountID int64, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			accounts
+		SET
+			password_hash = $2,
+			updated_at = now()
+		WHERE
+			account_id = $1
+	`
+
+	result, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, accountID, passwordHash)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return checkAffected(result)
+}
+
+func (repo *postgreAccountRepo) UpdateRole(accountID int64, role string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			accounts
+		SET
+			user_role = $2,
+			updated_at = now()
+		WHERE
+			account_id = $1
+	`
+
+	result, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, accountID, role)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return checkAffected(result)
+}
+
+// MarkVerified records that the account confirmed its email address. An
+// account that already did keeps its original verified_at.
+func (repo *postgreAccountRepo) MarkVerified(accountID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			accounts
+		SET
+			verified_at = COALESCE(verified_at, now()),
+			updated_at = now()
+		WHERE
+			account_id = $1
+	`
+
+	result, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, accountID)
+	if err != nil {
 		log.Println(err)
-		return apperror.InternalServerError
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return checkAffected(result)
+}
+
+func (repo *postgreAccountRepo) LinkIdentity(accountID int64, provider, subject string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO
+			account_identities
+		(
+			provider,
+			subject,
+			account_id,
+			created_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			now()
+		)
+	`
+
+	_, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, provider, subject, accountID)
+	if database.IsUniqueViolation(err) {
+		log.Println(err)
+		return apperror.AccountExists.Wrap(err)
+	}
+
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
 	}
 
 	return nil
 }
+
+// Deactivate soft-deletes the account. It can be reactivated until the
+// purge job removes it.
+func (repo *postgreAccountRepo) Deactivate(accountID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			accounts
+		SET
+			deleted_at = now(),
+			updated_at = now()
+		WHERE
+			account_id = $1 AND
+			deleted_at IS NULL
+	`
+
+	result, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, accountID)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return checkAffected(result)
+}
+
+func (repo *postgreAccountRepo) Reactivate(accountID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			accounts
+		SET
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE
+			account_id = $1 AND
+			deleted_at IS NOT NULL
+	`
+
+	result, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, accountID)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return checkAffected(result)
+}
+
+// PurgeDeactivated hard-deletes accounts deactivated before deletedBefore,
+// along with their linked identities.
+func (repo *postgreAccountRepo) PurgeDeactivated(deletedBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM
+			accounts
+		WHERE
+			deleted_at < $1
+	`
+
+	result, err := repo.DB.Writer(database.ScopeAccounts).ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		log.Println(err)
+		return 0, apperror.InternalServerError.Wrap(err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return 0, apperror.InternalServerError.Wrap(err)
+	}
+
+	return purged, nil
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	if affected == 0 {
+		return apperror.AccountNotExists
+	}
+
+	return nil
+}
+
+func nullTime(t pq.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
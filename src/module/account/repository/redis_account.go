@@ -1,15 +1,14 @@
 package repository
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
-	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/account"
-	redigo "github.com/gomodule/redigo/redis"
-	cache "github.com/patrickmn/go-cache"
 )
 
 const (
@@ -18,40 +17,23 @@ const (
 )
 
 type redisAccountRepo struct {
-	cache map[string]*cache.Cache
-	pool  *redigo.Pool
-	next  account.AccountRepository
+	find    *cache.Namespace
+	findAll *cache.Namespace
+	next    account.AccountRepository
 }
 
-func NewMiddlewareAccountRepository(cache map[string]*cache.Cache, pool *redigo.Pool, next account.AccountRepository) account.AccountRepository {
+func NewMiddlewareAccountRepository(store *cache.Store, localExpiration, remoteExpiration, staleExpiration time.Duration, next account.AccountRepository) account.AccountRepository {
 	return &redisAccountRepo{
-		cache: cache,
-		pool:  pool,
-		next:  next,
+		find: store.Namespace(KeyAccountsFind, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.Account)
+		})),
+		findAll: store.Namespace(KeyAccountsFindAll, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.AccountPage)
+		})).WithStaleWhileRevalidate(staleExpiration),
+		next: next,
 	}
 }
 
-func (repo *redisAccountRepo) do(command string, args ...interface{}) (reply interface{}, err error) {
-	conn := repo.pool.Get()
-	defer conn.Close()
-
-	return conn.Do(command, args...)
-}
-
-func (repo *redisAccountRepo) clearAllFindListCache() error {
-	keys := []interface{}{
-		KeyAccountsFindAll,
-	}
-
-	if _, err := repo.do("DEL", keys...); err != nil {
-		log.Println(err)
-		return apperror.InternalServerError
-	}
-
-	repo.cache[KeyAccountsFindAll].Flush()
-	return nil
-}
-
 func (repo *redisAccountRepo) Create(account *model.Account) (int64, error) {
 	lastID, err := repo.next.Create(account)
 	if err != nil {
@@ -59,121 +41,115 @@ func (repo *redisAccountRepo) Create(account *model.Account) (int64, error) {
 		return 0, err
 	}
 
-	if err := repo.clearAllFindListCache(); err != nil {
+	repo.findAll.InvalidateAll()
+	return lastID, nil
+}
+
+func (repo *redisAccountRepo) FindByID(accountID int64) (*model.Account, error) {
+	account, err := repo.find.GetOrLoad(fmt.Sprintf("%v", accountID), func() (interface{}, error) {
+		return repo.next.FindByID(accountID)
+	})
+	if err != nil {
 		log.Println(err)
-		return 0, err
+		return nil, err
 	}
 
-	return lastID, nil
+	return account.(*model.Account), nil
 }
 
-func (repo *redisAccountRepo) FindByID(accountID int64) (*model.Account, error) {
-	field := fmt.Sprintf("%v", accountID)
+// FindByEmail is not cached: it is only used to check credentials, and the
+// password hash never leaves the database.
+func (repo *redisAccountRepo) FindByEmail(email string) (*model.Account, error) {
+	return repo.next.FindByEmail(email)
+}
 
-	if accountCache, found := repo.cache[KeyAccountsFind].Get(field); found {
-		return accountCache.(*model.Account), nil
-	}
+// FindByIdentity is not cached either: it only runs on social login.
+func (repo *redisAccountRepo) FindByIdentity(provider, subject string) (*model.Account, error) {
+	return repo.next.FindByIdentity(provider, subject)
+}
 
-	accountJSON, err := redigo.Bytes(repo.do("HGET", KeyAccountsFind, field))
+func (repo *redisAccountRepo) FindAll(query *listing.Query) (*model.AccountPage, error) {
+	page, err := repo.findAll.GetOrLoad(query.Key(), func() (interface{}, error) {
+		return repo.next.FindAll(query)
+	})
 	if err != nil {
-		account, err := repo.next.FindByID(accountID)
-		if err != nil {
-			log.Println(err)
-			return nil, err
-		}
-
-		accountJSON, err := json.Marshal(&account)
-		if err != nil {
-			log.Println(err)
-			return nil, apperror.InternalServerError
-		}
-
-		if _, err := repo.do("HSET", KeyAccountsFind, field, accountJSON); err != nil {
-			log.Println(err)
-			return nil, apperror.InternalServerError
-		}
-
-		if _, err := repo.do("EXPIRE", KeyAccountsFind, 3600); err != nil {
-			log.Println(err)
-			return nil, apperror.InternalServerError
-		}
-
-		repo.cache[KeyAccountsFind].SetDefault(field, account)
-		return account, nil
+		log.Println(err)
+		return nil, err
 	}
 
-	var account *model.Account
-	if err := json.Unmarshal(accountJSON, &account); err != nil {
+	return page.(*model.AccountPage), nil
+}
+
+func (repo *redisAccountRepo) Update(account *model.Account) error {
+	if err := repo.next.Update(account); err != nil {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return err
 	}
 
-	repo.cache[KeyAccountsFind].SetDefault(field, account)
-	return account, nil
+	repo.findAll.InvalidateAll()
+	repo.find.Invalidate(fmt.Sprintf("%v", account.AccountID))
+	return nil
 }
 
-func (repo *redisAccountRepo) FindAll() (model.Accounts, error) {
-	field := "*"
-
-	if accountCache, found := repo.cache[KeyAccountsFindAll].Get(field); found {
-		return accountCache.(model.Accounts), nil
+func (repo *redisAccountRepo) UpdatePassword(accountID int64, passwordHash string) error {
+	if err := repo.next.UpdatePassword(accountID, passwordHash); err != nil {
+		log.Println(err)
+		return err
 	}
 
-	accountsJSON, err := redigo.Bytes(repo.do("HGET", KeyAccountsFindAll, field))
-	if err != nil {
-		accounts, err := repo.next.FindAll()
-		if err != nil {
-			log.Println(err)
-			return nil, err
-		}
-
-		accountsJSON, err := json.Marshal(&accounts)
-		if err != nil {
-			log.Println(err)
-			return nil, apperror.InternalServerError
-		}
-
-		if _, err := repo.do("HSET", KeyAccountsFindAll, field, accountsJSON); err != nil {
-			log.Println(err)
-			return nil, apperror.InternalServerError
-		}
-
-		if _, err := repo.do("EXPIRE", KeyAccountsFindAll, 3600); err != nil {
-			log.Println(err)
-			return nil, apperror.InternalServerError
-		}
-
-		repo.cache[KeyAccountsFindAll].SetDefault(field, accounts)
-		return accounts, nil
-	}
+	repo.findAll.InvalidateAll()
+	repo.find.Invalidate(fmt.Sprintf("%v", accountID))
+	return nil
+}
 
-	var accounts model.Accounts
-	if err := json.Unmarshal(accountsJSON, &accounts); err != nil {
+func (repo *redisAccountRepo) UpdateRole(accountID int64, role string) error {
+	if err := repo.next.UpdateRole(accountID, role); err != nil {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return err
 	}
 
-	repo.cache[KeyAccountsFindAll].SetDefault(field, accounts)
-	return accounts, nil
+	repo.findAll.InvalidateAll()
+	repo.find.Invalidate(fmt.Sprintf("%v", accountID))
+	return nil
 }
 
-func (repo *redisAccountRepo) Update(account *model.Account) error {
-	if err := repo.next.Update(account); err != nil {
+func (repo *redisAccountRepo) MarkVerified(accountID int64) error {
+	if err := repo.next.MarkVerified(accountID); err != nil {
 		log.Println(err)
 		return err
 	}
 
-	if err := repo.clearAllFindListCache(); err != nil {
+	repo.findAll.InvalidateAll()
+	repo.find.Invalidate(fmt.Sprintf("%v", accountID))
+	return nil
+}
+
+func (repo *redisAccountRepo) LinkIdentity(accountID int64, provider, subject string) error {
+	return repo.next.LinkIdentity(accountID, provider, subject)
+}
+
+func (repo *redisAccountRepo) Deactivate(accountID int64) error {
+	if err := repo.next.Deactivate(accountID); err != nil {
 		log.Println(err)
 		return err
 	}
 
-	field := fmt.Sprintf("%v", account.AccountID)
-	if _, err := repo.do("HDEL", KeyAccountsFind, field); err != nil {
+	repo.findAll.InvalidateAll()
+	repo.find.Invalidate(fmt.Sprintf("%v", accountID))
+	return nil
+}
+
+func (repo *redisAccountRepo) Reactivate(accountID int64) error {
+	if err := repo.next.Reactivate(accountID); err != nil {
 		log.Println(err)
-		return apperror.InternalServerError
+		return err
 	}
 
-	repo.cache[KeyAccountsFind].Delete(field)
+	repo.findAll.InvalidateAll()
+	repo.find.Invalidate(fmt.Sprintf("%v", accountID))
 	return nil
 }
+
+func (repo *redisAccountRepo) PurgeDeactivated(deletedBefore time.Time) (int64, error) {
+	return repo.next.PurgeDeactivated(deletedBefore)
+}
@@ -1,41 +1,197 @@
 package account
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/common/mail"
 	"github.com/atletaid/go-template/src/model"
 )
 
 type Usecase interface {
-	CreateAccount(email, fullname string) (int64, error)
+	CreateAccount(email, fullname, password string) (int64, error)
+	Authenticate(email, password string) (*model.Account, error)
+	AuthenticateIdentity(identity *auth.Identity) (*model.Account, error)
 	GetAccount(accountID int64) (*model.Account, error)
-	GetAccounts() (model.Accounts, error)
+	GetAccounts(query *listing.Query) (*model.AccountPage, error)
 	UpdateAccount(accountID int64, email, fullname string) error
+	ChangeRole(accountID int64, role string) error
+	SendVerificationEmail(accountID int64) error
+	VerifyEmail(token string) error
+	SendPasswordReset(email string) error
+	ResetPassword(token, password string) error
+	DeactivateAccount(accountID int64) error
+	ReactivateAccount(email, password string) (*model.Account, error)
+	RestoreAccount(accountID int64) error
+	PurgeDeactivatedAccounts(deletedBefore time.Time) (int64, error)
 }
 
 type usecase struct {
 	accountRepo AccountRepository
+	sessions    *auth.SessionStore
+	tokens      *auth.ActionTokenStore
+	mailer      mail.Mailer
+
+	linkBaseURL             string
+	verifyEmailExpiration   time.Duration
+	resetPasswordExpiration time.Duration
 }
 
+// NewAccountUsecase builds the account usecase. Verification and password
+// reset emails link to linkBaseURL, and their tokens expire after
+// verifyEmailExpiration and resetPasswordExpiration.
 func NewAccountUsecase(
 	accountRepo AccountRepository,
+	sessions *auth.SessionStore,
+	tokens *auth.ActionTokenStore,
+	mailer mail.Mailer,
+	linkBaseURL string,
+	verifyEmailExpiration time.Duration,
+	resetPasswordExpiration time.Duration,
 ) Usecase {
 	return &usecase{
-		accountRepo: accountRepo,
+		accountRepo:             accountRepo,
+		sessions:                sessions,
+		tokens:                  tokens,
+		mailer:                  mailer,
+		linkBaseURL:             linkBaseURL,
+		verifyEmailExpiration:   verifyEmailExpiration,
+		resetPasswordExpiration: resetPasswordExpiration,
 	}
 }
 
-func (u *usecase) CreateAccount(email, fullname string) (int64, error) {
-	newAccount := model.NewAccount(email, fullname)
+func (u *usecase) CreateAccount(email, fullname, password string) (int64, error) {
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Println(err)
+		return 0, apperror.InternalServerError.Wrap(err)
+	}
+
+	newAccount := model.NewAccount(email, fullname, passwordHash)
 	accountID, err := u.accountRepo.Create(newAccount)
 	if err != nil {
 		log.Println(err)
 		return 0, err
 	}
 
+	// The account exists either way; the user can ask for another email.
+	newAccount.AccountID = accountID
+	if err := u.sendVerificationEmail(newAccount); err != nil {
+		log.Println(err)
+	}
+
 	return accountID, nil
 }
 
+// Authenticate checks an email and password and returns the account, unless
+// it is deactivated.
+func (u *usecase) Authenticate(email, password string) (*model.Account, error) {
+	account, err := u.checkCredentials(email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.IsDeactivated() {
+		return nil, apperror.AccountDeactivated
+	}
+
+	return account, nil
+}
+
+func (u *usecase) checkCredentials(email, password string) (*model.Account, error) {
+	account, err := u.accountRepo.FindByEmail(email)
+	if errors.Is(err, apperror.AccountNotExists) {
+		auth.CheckPassword("", password)
+		return nil, apperror.InvalidCredentials
+	}
+
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if !auth.CheckPassword(account.PasswordHash, password) {
+		return nil, apperror.InvalidCredentials
+	}
+
+	return account, nil
+}
+
+// AuthenticateIdentity returns the account linked to a login provider's
+// identity. On first login the identity is linked to the account with the
+// same email, provided the provider verified it, or a new account without a
+// password is created for it.
+func (u *usecase) AuthenticateIdentity(identity *auth.Identity) (*model.Account, error) {
+	account, err := u.accountRepo.FindByIdentity(identity.Provider, identity.Subject)
+	if err == nil && account.IsDeactivated() {
+		return nil, apperror.AccountDeactivated
+	}
+
+	if err == nil {
+		return account, nil
+	}
+
+	if !errors.Is(err, apperror.AccountNotExists) {
+		log.Println(err)
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, apperror.LoginProviderFailed.Wrap(errors.New("provider did not share an email"))
+	}
+
+	account, err = u.accountRepo.FindByEmail(identity.Email)
+	switch {
+	case err == nil && account.IsDeactivated():
+		return nil, apperror.AccountDeactivated
+
+	case err == nil:
+		// Linking on an unverified email would let anyone who registers it
+		// at the provider take over the account. Linking to an account that
+		// never verified its email would let whoever signed up with it first
+		// keep their password on the account the owner now logs into.
+		if !identity.EmailVerified || !account.IsVerified() {
+			return nil, apperror.AccountExists
+		}
+
+	case errors.Is(err, apperror.AccountNotExists):
+		fullname := identity.Name
+		if fullname == "" {
+			fullname = strings.SplitN(identity.Email, "@", 2)[0]
+		}
+
+		account = model.NewAccount(identity.Email, fullname, "")
+		if account.AccountID, err = u.accountRepo.Create(account); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+	default:
+		log.Println(err)
+		return nil, err
+	}
+
+	if err := u.accountRepo.LinkIdentity(account.AccountID, identity.Provider, identity.Subject); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if identity.EmailVerified && !account.IsVerified() {
+		if err := u.accountRepo.MarkVerified(account.AccountID); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
+	return u.accountRepo.FindByID(account.AccountID)
+}
+
 func (u *usecase) GetAccount(accountID int64) (*model.Account, error) {
 	account, err := u.accountRepo.FindByID(accountID)
 	if err != nil {
@@ -46,14 +202,14 @@ func (u *usecase) GetAccount(accountID int64) (*model.Account, error) {
 	return account, nil
 }
 
-func (u *usecase) GetAccounts() (model.Accounts, error) {
-	accounts, err := u.accountRepo.FindAll()
+func (u *usecase) GetAccounts(query *listing.Query) (*model.AccountPage, error) {
+	page, err := u.accountRepo.FindAll(query)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return accounts, nil
+	return page, nil
 }
 
 func (u *usecase) UpdateAccount(accountID int64, email, fullname string) error {
@@ -74,3 +230,220 @@ func (u *usecase) UpdateAccount(accountID int64, email, fullname string) error {
 
 	return nil
 }
+
+func (u *usecase) SendVerificationEmail(accountID int64) error {
+	account, err := u.accountRepo.FindByID(accountID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if account.IsVerified() {
+		return nil
+	}
+
+	if err := u.sendVerificationEmail(account); err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return nil
+}
+
+func (u *usecase) sendVerificationEmail(account *model.Account) error {
+	token, err := u.tokens.Issue(auth.PurposeVerifyEmail, account.AccountID, account.Email, u.verifyEmailExpiration)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(mail.Message{
+		To:      account.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen this link to confirm your email address:\n%s/verify-email?token=%s\n\nThe link expires in %v.\n",
+			account.Fullname, u.linkBaseURL, token, u.verifyEmailExpiration,
+		),
+	})
+}
+
+func (u *usecase) VerifyEmail(token string) error {
+	accountID, err := u.redeemToken(auth.PurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	if err := u.accountRepo.MarkVerified(accountID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// SendPasswordReset mails a reset link to email. Unknown emails succeed too,
+// so the endpoint can't be used to find out who has an account.
+func (u *usecase) SendPasswordReset(email string) error {
+	account, err := u.accountRepo.FindByEmail(email)
+	if errors.Is(err, apperror.AccountNotExists) {
+		return nil
+	}
+
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	token, err := u.tokens.Issue(auth.PurposeResetPassword, account.AccountID, account.Email, u.resetPasswordExpiration)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	err = u.mailer.Send(mail.Message{
+		To:      account.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen this link to choose a new password:\n%s/reset-password?token=%s\n\nThe link expires in %v. If you didn't ask for it, ignore this email.\n",
+			account.Fullname, u.linkBaseURL, token, u.resetPasswordExpiration,
+		),
+	})
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password and logs the account out everywhere.
+// Redeeming the link also proves the user owns the email address.
+func (u *usecase) ResetPassword(token, password string) error {
+	accountID, err := u.redeemToken(auth.PurposeResetPassword, token)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	if err := u.accountRepo.UpdatePassword(accountID, passwordHash); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := u.accountRepo.MarkVerified(accountID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := u.sessions.RevokeAll(accountID); err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return nil
+}
+
+// redeemToken consumes a mailed token and returns its account, provided the
+// account still has the email the token was sent to. Otherwise the token
+// would vouch for an address the user never received it at.
+func (u *usecase) redeemToken(purpose, token string) (int64, error) {
+	accountID, email, err := u.tokens.Redeem(purpose, token)
+	if errors.Is(err, apperror.InvalidActionToken) {
+		return 0, err
+	}
+
+	if err != nil {
+		log.Println(err)
+		return 0, apperror.InternalServerError.Wrap(err)
+	}
+
+	account, err := u.accountRepo.FindByID(accountID)
+	if errors.Is(err, apperror.AccountNotExists) {
+		return 0, apperror.InvalidActionToken
+	}
+
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	if !strings.EqualFold(account.Email, email) {
+		return 0, apperror.InvalidActionToken
+	}
+
+	return accountID, nil
+}
+
+// ChangeRole grants the account role. Its access tokens keep the old role
+// until they are next refreshed.
+func (u *usecase) ChangeRole(accountID int64, role string) error {
+	if !model.IsRole(role) {
+		return apperror.InvalidRole
+	}
+
+	if err := u.accountRepo.UpdateRole(accountID, role); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// DeactivateAccount soft-deletes the account and logs it out everywhere.
+func (u *usecase) DeactivateAccount(accountID int64) error {
+	if err := u.accountRepo.Deactivate(accountID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := u.sessions.RevokeAll(accountID); err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	return nil
+}
+
+// ReactivateAccount lets the owner of a deactivated account bring it back
+// with its credentials. An active account is returned as is.
+func (u *usecase) ReactivateAccount(email, password string) (*model.Account, error) {
+	account, err := u.checkCredentials(email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if !account.IsDeactivated() {
+		return account, nil
+	}
+
+	if err := u.accountRepo.Reactivate(account.AccountID); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return u.accountRepo.FindByID(account.AccountID)
+}
+
+// RestoreAccount reactivates an account on an admin's behalf.
+func (u *usecase) RestoreAccount(accountID int64) error {
+	if err := u.accountRepo.Reactivate(accountID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (u *usecase) PurgeDeactivatedAccounts(deletedBefore time.Time) (int64, error) {
+	purged, err := u.accountRepo.PurgeDeactivated(deletedBefore)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	return purged, nil
+}
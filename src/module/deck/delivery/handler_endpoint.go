@@ -0,0 +1,79 @@
+package delivery
+
+import (
+	"log"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/deck"
+	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+type DeckHandler struct {
+	du          deck.Usecase
+	defaultSize int
+}
+
+func NewDeckHandler(router *gin.Engine, m *auth.Middleware, du deck.Usecase, defaultSize int) *gin.Engine {
+	handler := &DeckHandler{du, defaultSize}
+
+	v1 := router.Group("/api/v1", m.AuthUserToken(), m.RequireVerified())
+	v1.GET("/deck", handler.GetDeckEndpoint())
+
+	return router
+}
+
+type getDeckRequest struct {
+	Lat  *float64 `json:"lat" form:"lat" binding:"required,gte=-85,lte=85"`
+	Long *float64 `json:"long" form:"long" binding:"required,gte=-180,lte=180"`
+	Size int      `json:"size" form:"size" binding:"omitempty,min=1,max=50"`
+}
+
+type dataDeckResponse struct {
+	Cards model.DeckCards `json:"cards"`
+}
+
+// GetDeckEndpoint deals the caller's next cards to swipe on around lat and
+// long, best suited first.
+func (h *DeckHandler) GetDeckEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := getDeckRequest{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if req.Size == 0 {
+			req.Size = h.defaultSize
+		}
+
+		accountID, _ := auth.AccountID(c)
+		cards, err := h.du.GetDeck(accountID, *req.Lat, *req.Long, req.Size)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		resp := dataDeckResponse{
+			Cards: cards,
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get deck"}, processTime, resp)
+	}
+}
@@ -0,0 +1,126 @@
+package deck
+
+import (
+	"math"
+	"sort"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/model"
+)
+
+// neutralFit scores what the account's likes can't tell yet.
+const neutralFit = 0.5
+
+// taste summarizes an account's likes. Superlikes count twice.
+type taste struct {
+	likes      float64
+	price      map[string]float64
+	timeMinute map[string]float64
+	cities     map[string]float64
+}
+
+func newTaste(likes []*Like) *taste {
+	t := &taste{
+		price:      make(map[string]float64),
+		timeMinute: make(map[string]float64),
+		cities:     make(map[string]float64),
+	}
+
+	perType := make(map[string]float64)
+	for _, like := range likes {
+		weight := 1.0
+		if like.Superlike {
+			weight = 2
+		}
+
+		t.likes += weight
+		perType[like.VenueType] += weight
+		t.price[like.VenueType] += weight * float64(like.Price)
+		t.timeMinute[like.VenueType] += weight * float64(like.TimeMinute)
+		t.cities[like.City] += weight
+	}
+
+	for venueType, weight := range perType {
+		t.price[venueType] /= weight
+		t.timeMinute[venueType] /= weight
+	}
+
+	for city := range t.cities {
+		t.cities[city] /= t.likes
+	}
+
+	return t
+}
+
+// priceFit is 1 for a card priced like the venues of its type the account
+// liked, falling to 0 as the price strays by their average or more.
+func (t *taste) priceFit(card *model.DeckCard) float64 {
+	average, ok := t.price[card.Type]
+	if !ok {
+		return neutralFit
+	}
+	return closeness(float64(card.Price()), average)
+}
+
+// similarity averages how much of the account's likes are in the card's
+// city and how close its duration is to the liked venues of its type.
+func (t *taste) similarity(card *model.DeckCard) float64 {
+	if t.likes == 0 {
+		return neutralFit
+	}
+
+	timeFit := neutralFit
+	if average, ok := t.timeMinute[card.Type]; ok {
+		timeFit = closeness(float64(card.TimeMinute()), average)
+	}
+
+	return (t.cities[card.City()] + timeFit) / 2
+}
+
+func closeness(value, target float64) float64 {
+	return 1 - math.Min(math.Abs(value-target)/math.Max(target, 1), 1)
+}
+
+// rank scores the cards for the account and sorts them best first.
+func rank(cards model.DeckCards, t *taste, cfg config.DeckConfig) {
+	for _, card := range cards {
+		nearness := 1 - math.Min(card.DistanceKM/cfg.RadiusKM, 1)
+		card.Score = cfg.DistanceWeight*nearness +
+			cfg.PriceWeight*t.priceFit(card) +
+			cfg.SimilarityWeight*t.similarity(card)
+	}
+
+	sort.SliceStable(cards, func(i, j int) bool {
+		return cards[i].Score > cards[j].Score
+	})
+}
+
+// interleave deals restaurantRatio restaurants for every recreationRatio
+// recreations, in order, until one list runs out and the other fills the
+// rest.
+func interleave(restaurants, recreations model.DeckCards, restaurantRatio, recreationRatio int) model.DeckCards {
+	cards := make(model.DeckCards, 0, len(restaurants)+len(recreations))
+	for len(restaurants) > 0 || len(recreations) > 0 {
+		n := minInt(restaurantRatio, len(restaurants))
+		if len(recreations) == 0 {
+			n = len(restaurants)
+		}
+		cards = append(cards, restaurants[:n]...)
+		restaurants = restaurants[n:]
+
+		n = minInt(recreationRatio, len(recreations))
+		if len(restaurants) == 0 {
+			n = len(recreations)
+		}
+		cards = append(cards, recreations[:n]...)
+		recreations = recreations[n:]
+	}
+	return cards
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,178 @@
+package deck
+
+import (
+	"math"
+	"testing"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/model"
+)
+
+func restaurantCard(id int64, price, timeMinute int, city string, distanceKM float64) *model.DeckCard {
+	return &model.DeckCard{
+		Type:       model.VenueTypeRestaurant,
+		DistanceKM: distanceKM,
+		Restaurant: &model.Restaurant{
+			RestaurantID:         id,
+			RestaurantPrice:      price,
+			RestaurantTimeMinute: timeMinute,
+			RestaurantCity:       city,
+		},
+	}
+}
+
+func recreationCard(id int64, price, timeMinute int, city string, distanceKM float64) *model.DeckCard {
+	return &model.DeckCard{
+		Type:       model.VenueTypeRecreation,
+		DistanceKM: distanceKM,
+		Recreation: &model.Recreation{
+			RecreationID:         id,
+			RecreationPrice:      price,
+			RecreationTimeMinute: timeMinute,
+			RecreationCity:       city,
+		},
+	}
+}
+
+func venueIDs(cards model.DeckCards) []int64 {
+	ids := make([]int64, 0, len(cards))
+	for _, card := range cards {
+		ids = append(ids, card.VenueID())
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewTaste(t *testing.T) {
+	taste := newTaste([]*Like{
+		{VenueType: model.VenueTypeRestaurant, Price: 10, TimeMinute: 30, City: "Bandung"},
+		{VenueType: model.VenueTypeRestaurant, Price: 40, TimeMinute: 60, City: "Jakarta", Superlike: true},
+		{VenueType: model.VenueTypeRecreation, Price: 100, TimeMinute: 120, City: "Bandung"},
+	})
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"weighted likes", taste.likes, 4},
+		{"restaurant price", taste.price[model.VenueTypeRestaurant], 30},
+		{"restaurant time", taste.timeMinute[model.VenueTypeRestaurant], 50},
+		{"recreation price", taste.price[model.VenueTypeRecreation], 100},
+		{"Bandung share", taste.cities["Bandung"], 0.5},
+		{"Jakarta share", taste.cities["Jakarta"], 0.5},
+	}
+
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestTasteWithoutLikesIsNeutral(t *testing.T) {
+	taste := newTaste(nil)
+	card := restaurantCard(1, 50, 30, "Bandung", 1)
+
+	if got := taste.priceFit(card); got != neutralFit {
+		t.Errorf("priceFit() = %v, want %v", got, neutralFit)
+	}
+	if got := taste.similarity(card); got != neutralFit {
+		t.Errorf("similarity() = %v, want %v", got, neutralFit)
+	}
+}
+
+func TestClosenessIsClamped(t *testing.T) {
+	tests := []struct {
+		value, target, want float64
+	}{
+		{50, 50, 1},
+		{75, 50, 0.5},
+		{25, 50, 0.5},
+		{500, 50, 0},
+		{0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		if got := closeness(tt.value, tt.target); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("closeness(%v, %v) = %v, want %v", tt.value, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	cfg := config.DeckConfig{RadiusKM: 10, DistanceWeight: 1, PriceWeight: 1, SimilarityWeight: 1}
+	taste := newTaste([]*Like{
+		{VenueType: model.VenueTypeRestaurant, Price: 20, TimeMinute: 30, City: "Bandung"},
+	})
+
+	cards := model.DeckCards{
+		restaurantCard(1, 80, 90, "Jakarta", 9),
+		restaurantCard(2, 20, 30, "Bandung", 9),
+		restaurantCard(3, 20, 30, "Bandung", 1),
+		restaurantCard(4, 20, 30, "Bandung", 20),
+	}
+	rank(cards, taste, cfg)
+
+	if got, want := venueIDs(cards), []int64{3, 2, 4, 1}; !equalIDs(got, want) {
+		t.Errorf("rank() order = %v, want %v", got, want)
+	}
+
+	// Beyond the radius nearness bottoms out at zero instead of going
+	// negative.
+	if got, want := cards[2].Score, 2.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("score beyond radius = %v, want %v", got, want)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	restaurants := func(ids ...int64) model.DeckCards {
+		cards := model.DeckCards{}
+		for _, id := range ids {
+			cards = append(cards, restaurantCard(id, 0, 0, "", 0))
+		}
+		return cards
+	}
+	recreations := func(ids ...int64) model.DeckCards {
+		cards := model.DeckCards{}
+		for _, id := range ids {
+			cards = append(cards, recreationCard(id, 0, 0, "", 0))
+		}
+		return cards
+	}
+
+	tests := []struct {
+		name                             string
+		restaurants, recreations         model.DeckCards
+		restaurantRatio, recreationRatio int
+		want                             []int64
+	}{
+		{"one to one", restaurants(1, 2, 3), recreations(11, 12, 13), 1, 1, []int64{1, 11, 2, 12, 3, 13}},
+		{"two to one", restaurants(1, 2, 3, 4), recreations(11, 12), 2, 1, []int64{1, 2, 11, 3, 4, 12}},
+		{"restaurants run out", restaurants(1), recreations(11, 12, 13), 1, 1, []int64{1, 11, 12, 13}},
+		{"recreations run out", restaurants(1, 2, 3), recreations(11), 1, 1, []int64{1, 11, 2, 3}},
+		{"no recreations", restaurants(1, 2), nil, 1, 3, []int64{1, 2}},
+		{"no restaurants", nil, recreations(11, 12), 3, 1, []int64{11, 12}},
+		{"restaurants only ratio", restaurants(1, 2), recreations(11), 1, 0, []int64{1, 2, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := interleave(tt.restaurants, tt.recreations, tt.restaurantRatio, tt.recreationRatio)
+			if ids := venueIDs(got); !equalIDs(ids, tt.want) {
+				t.Errorf("interleave() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
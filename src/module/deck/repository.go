@@ -0,0 +1,71 @@
+package deck
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/atletaid/go-template/src/model"
+)
+
+// Like is a venue the account liked, as far as ranking is concerned.
+type Like struct {
+	VenueType  string
+	Price      int
+	TimeMinute int
+	City       string
+	Superlike  bool
+}
+
+// Deck is an account's stored deck: the cards not handed out yet, and the
+// point they were picked around.
+type Deck struct {
+	Lat   float64
+	Long  float64
+	Cards model.DeckCards
+}
+
+type DeckRepository interface {
+	// FindCandidates lists up to limit live venues of venueType within
+	// radiusKM that the account hasn't swiped, nearest first, leaving out
+	// the venue IDs in excluded.
+	FindCandidates(accountID int64, venueType string, lat, long, radiusKM float64, excluded []int64, limit int) (model.DeckCards, error)
+	// FindLikes lists the account's latest likes and superlikes.
+	FindLikes(accountID int64, limit int) ([]*Like, error)
+}
+
+// DeckStore keeps each account's deck between requests. Served records the
+// cards handed out lately, so that rebuilding the deck doesn't deal them
+// again before the account has swiped on them.
+type DeckStore interface {
+	// Take removes and returns up to count cards from the front of the
+	// deck, and how many are left. The deck is nil if none is stored.
+	Take(accountID int64, count int) (*Deck, int, error)
+	Replace(accountID int64, deck *Deck) error
+	MarkServed(accountID int64, cards model.DeckCards) error
+	Served(accountID int64) (map[string]bool, error)
+	// LockRefill claims the background refill of the account's deck. It
+	// reports false if a refill is already running.
+	LockRefill(accountID int64) (bool, error)
+}
+
+// CardKey identifies a card's venue across venue types.
+func CardKey(card *model.DeckCard) string {
+	return fmt.Sprintf("%s:%d", card.Type, card.VenueID())
+}
+
+// idsOfType picks the IDs of venueType out of a set of card keys.
+func idsOfType(cardKeys map[string]bool, venueType string) []int64 {
+	ids := make([]int64, 0)
+	for cardKey := range cardKeys {
+		parts := strings.SplitN(cardKey, ":", 2)
+		if len(parts) != 2 || parts[0] != venueType {
+			continue
+		}
+
+		if id, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/deck"
+	"github.com/lib/pq"
+)
+
+type postgreDeckRepo struct {
+	DB      *database.DB
+	Timeout time.Duration
+}
+
+func NewDeckRepository(db *database.DB, timeout time.Duration) deck.DeckRepository {
+	return &postgreDeckRepo{
+		DB:      db,
+		Timeout: timeout,
+	}
+}
+
+func (repo *postgreDeckRepo) FindCandidates(accountID int64, venueType string, lat, long, radiusKM float64, excluded []int64, limit int) (model.DeckCards, error) {
+	// A NULL array would filter out every row.
+	if excluded == nil {
+		excluded = []int64{}
+	}
+
+	if venueType == model.VenueTypeRecreation {
+		return repo.findRecreationCandidates(accountID, lat, long, radiusKM, excluded, limit)
+	}
+	return repo.findRestaurantCandidates(accountID, lat, long, radiusKM, excluded, limit)
+}
+
+func (repo *postgreDeckRepo) FindLikes(accountID int64, limit int) ([]*deck.Like, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+	SELECT
+		s.venue_type,
+		s.direction,
+		v.price,
+		v.time_minute,
+		v.city
+	FROM
+		swipes s
+	JOIN (
+		SELECT
+			'restaurant' AS venue_type,
+			restaurant_id AS venue_id,
+			restaurant_price AS price,
+			restaurant_time_minute AS time_minute,
+			restaurant_city AS city
+		FROM
+			ms_restaurant
+		WHERE
+			deleted_at IS NULL
+		UNION ALL
+		SELECT
+			'recreation' AS venue_type,
+			recreation_id AS venue_id,
+			recreation_price AS price,
+			recreation_time_minute AS time_minute,
+			recreation_city AS city
+		FROM
+			ms_recreation
+		WHERE
+			deleted_at IS NULL
+	) v ON v.venue_type = s.venue_type AND v.venue_id = s.venue_id
+	WHERE
+		s.account_id = $1 AND
+		s.direction IN ('like', 'superlike')
+	ORDER BY
+		s.updated_at DESC
+	LIMIT $2
+	`
+
+	rows, err := repo.DB.Reader(database.ScopeSwipes(accountID)).QueryContext(ctx, query, accountID, limit)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	likes := make([]*deck.Like, 0)
+	for rows.Next() {
+		var (
+			lVenueType  sql.NullString
+			lDirection  sql.NullString
+			lPrice      sql.NullInt64
+			lTimeMinute sql.NullInt64
+			lCity       sql.NullString
+		)
+
+		if err := rows.Scan(
+			&lVenueType,
+			&lDirection,
+			&lPrice,
+			&lTimeMinute,
+			&lCity,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		likes = append(likes, &deck.Like{
+			VenueType:  lVenueType.String,
+			Price:      int(lPrice.Int64),
+			TimeMinute: int(lTimeMinute.Int64),
+			City:       lCity.String,
+			Superlike:  lDirection.String == model.SwipeSuperlike,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return likes, nil
+}
+
+func (repo *postgreDeckRepo) findRestaurantCandidates(accountID int64, lat, long, radiusKM float64, excluded []int64, limit int) (model.DeckCards, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+	SELECT
+		restaurant_id,
+		restaurant_name,
+		restaurant_time_minute,
+		restaurant_price,
+		position_lat,
+		position_long,
+		restaurant_city,
+		restaurant_image,
+		restaurant_description,
+		created_at,
+		updated_at,
+		distance_km
+	FROM (
+		SELECT
+			*,
+			6371 * 2 * asin(sqrt(
+				power(sin(radians(position_lat - $2) / 2), 2) +
+				cos(radians($2)) * cos(radians(position_lat)) *
+				power(sin(radians(position_long - $3) / 2), 2)
+			)) AS distance_km
+		FROM
+			ms_restaurant rt
+		WHERE
+			deleted_at IS NULL AND
+			position_lat BETWEEN $2 - $6 AND $2 + $6 AND
+			position_long BETWEEN $3 - $7 AND $3 + $7 AND
+			NOT (rt.restaurant_id = ANY($8)) AND
+			NOT EXISTS (
+				SELECT
+					1
+				FROM
+					swipes s
+				WHERE
+					s.account_id = $1 AND
+					s.venue_type = 'restaurant' AND
+					s.venue_id = rt.restaurant_id
+			)
+	) candidates
+	WHERE
+		distance_km <= $4
+	ORDER BY
+		distance_km,
+		restaurant_id
+	LIMIT $5
+	`
+
+	latDelta, longDelta := geo.BoundingBox(lat, radiusKM)
+	rows, err := repo.DB.Reader(database.ScopeSwipes(accountID), database.ScopeRestaurants).QueryContext(ctx, query, accountID, lat, long, radiusKM, limit, latDelta, longDelta, pq.Array(excluded))
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	cards := make(model.DeckCards, 0)
+	for rows.Next() {
+		var (
+			rtrestaurantID          sql.NullInt64
+			rtrestaurantName        sql.NullString
+			rtrestaurantTimeMinute  sql.NullInt64
+			rtrestaurantPrice       sql.NullInt64
+			rtPositionLat           sql.NullFloat64
+			rtPositionLong          sql.NullFloat64
+			rtrestaurantCity        sql.NullString
+			rtrestaurantImage       sql.NullString
+			rtrestaurantDescription sql.NullString
+			rtCreatedAt             pq.NullTime
+			rtUpdatedAt             pq.NullTime
+			rtDistanceKM            sql.NullFloat64
+		)
+
+		if err := rows.Scan(
+			&rtrestaurantID,
+			&rtrestaurantName,
+			&rtrestaurantTimeMinute,
+			&rtrestaurantPrice,
+			&rtPositionLat,
+			&rtPositionLong,
+			&rtrestaurantCity,
+			&rtrestaurantImage,
+			&rtrestaurantDescription,
+			&rtCreatedAt,
+			&rtUpdatedAt,
+			&rtDistanceKM,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		cards = append(cards, &model.DeckCard{
+			Type:       model.VenueTypeRestaurant,
+			DistanceKM: rtDistanceKM.Float64,
+			Restaurant: &model.Restaurant{
+				RestaurantID:          rtrestaurantID.Int64,
+				RestaurantName:        rtrestaurantName.String,
+				RestaurantTimeMinute:  int(rtrestaurantTimeMinute.Int64),
+				RestaurantPrice:       int(rtrestaurantPrice.Int64),
+				PositionLat:           rtPositionLat.Float64,
+				PositionLong:          rtPositionLong.Float64,
+				RestaurantCity:        rtrestaurantCity.String,
+				RestaurantImage:       rtrestaurantImage.String,
+				RestaurantDescription: rtrestaurantDescription.String,
+				CreatedAt:             rtCreatedAt.Time,
+				UpdatedAt:             rtUpdatedAt.Time,
+			},
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return cards, nil
+}
+
+func (repo *postgreDeckRepo) findRecreationCandidates(accountID int64, lat, long, radiusKM float64, excluded []int64, limit int) (model.DeckCards, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+	SELECT
+		recreation_id,
+		recreation_name,
+		recreation_time_minute,
+		recreation_price,
+		position_lat,
+		position_long,
+		recreation_city,
+		recreation_image,
+		recreation_description,
+		created_at,
+		updated_at,
+		distance_km
+	FROM (
+		SELECT
+			*,
+			6371 * 2 * asin(sqrt(
+				power(sin(radians(position_lat - $2) / 2), 2) +
+				cos(radians($2)) * cos(radians(position_lat)) *
+				power(sin(radians(position_long - $3) / 2), 2)
+			)) AS distance_km
+		FROM
+			ms_recreation r
+		WHERE
+			deleted_at IS NULL AND
+			position_lat BETWEEN $2 - $6 AND $2 + $6 AND
+			position_long BETWEEN $3 - $7 AND $3 + $7 AND
+			NOT (r.recreation_id = ANY($8)) AND
+			NOT EXISTS (
+				SELECT
+					1
+				FROM
+					swipes s
+				WHERE
+					s.account_id = $1 AND
+					s.venue_type = 'recreation' AND
+					s.venue_id = r.recreation_id
+			)
+	) candidates
+	WHERE
+		distance_km <= $4
+	ORDER BY
+		distance_km,
+		recreation_id
+	LIMIT $5
+	`
+
+	latDelta, longDelta := geo.BoundingBox(lat, radiusKM)
+	rows, err := repo.DB.Reader(database.ScopeSwipes(accountID), database.ScopeRecreations).QueryContext(ctx, query, accountID, lat, long, radiusKM, limit, latDelta, longDelta, pq.Array(excluded))
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	cards := make(model.DeckCards, 0)
+	for rows.Next() {
+		var (
+			rRecreationID          sql.NullInt64
+			rRecreationName        sql.NullString
+			rRecreationTimeMinute  sql.NullInt64
+			rRecreationPrice       sql.NullInt64
+			rPositionLat           sql.NullFloat64
+			rPositionLong          sql.NullFloat64
+			rRecreationCity        sql.NullString
+			rRecreationImage       sql.NullString
+			rRecreationDescription sql.NullString
+			rCreatedAt             pq.NullTime
+			rUpdatedAt             pq.NullTime
+			rDistanceKM            sql.NullFloat64
+		)
+
+		if err := rows.Scan(
+			&rRecreationID,
+			&rRecreationName,
+			&rRecreationTimeMinute,
+			&rRecreationPrice,
+			&rPositionLat,
+			&rPositionLong,
+			&rRecreationCity,
+			&rRecreationImage,
+			&rRecreationDescription,
+			&rCreatedAt,
+			&rUpdatedAt,
+			&rDistanceKM,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		cards = append(cards, &model.DeckCard{
+			Type:       model.VenueTypeRecreation,
+			DistanceKM: rDistanceKM.Float64,
+			Recreation: &model.Recreation{
+				RecreationID:          rRecreationID.Int64,
+				RecreationName:        rRecreationName.String,
+				RecreationTimeMinute:  int(rRecreationTimeMinute.Int64),
+				RecreationPrice:       int(rRecreationPrice.Int64),
+				PositionLat:           rPositionLat.Float64,
+				PositionLong:          rPositionLong.Float64,
+				RecreationCity:        rRecreationCity.String,
+				RecreationImage:       rRecreationImage.String,
+				RecreationDescription: rRecreationDescription.String,
+				CreatedAt:             rCreatedAt.Time,
+				UpdatedAt:             rUpdatedAt.Time,
+			},
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return cards, nil
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/deck"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	KeyDeck       = "deck:%d"
+	KeyDeckOrigin = "deck:%d:origin"
+	KeyDeckServed = "deck:%d:served"
+	KeyDeckRefill = "deck:%d:refill"
+
+	refillLockTimeout = 30 * time.Second
+)
+
+// redisDeckStore keeps each deck as a list of JSON cards next to the point
+// it was built around, expiring expiration after it was last built. Served
+// cards are scored by when they were dealt, and each is forgotten
+// expiration after that, however active the account stays.
+type redisDeckStore struct {
+	pool       *redigo.Pool
+	expiration time.Duration
+	now        func() time.Time
+}
+
+func NewDeckStore(pool *redigo.Pool, expiration time.Duration) deck.DeckStore {
+	return &redisDeckStore{
+		pool:       pool,
+		expiration: expiration,
+		now:        time.Now,
+	}
+}
+
+func (store *redisDeckStore) Take(accountID int64, count int) (*deck.Deck, int, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf(KeyDeck, accountID)
+	conn.Send("MULTI")
+	conn.Send("GET", fmt.Sprintf(KeyDeckOrigin, accountID))
+	conn.Send("LRANGE", key, 0, count-1)
+	conn.Send("LTRIM", key, count, -1)
+	conn.Send("LLEN", key)
+	replies, err := redigo.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	origin, err := redigo.String(replies[0], nil)
+	if err == redigo.ErrNil {
+		return nil, 0, nil
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	taken := &deck.Deck{}
+	if taken.Lat, taken.Long, err = parseOrigin(origin); err != nil {
+		return nil, 0, err
+	}
+
+	values, err := redigo.ByteSlices(replies[1], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	taken.Cards = make(model.DeckCards, 0, len(values))
+	for _, value := range values {
+		card := &model.DeckCard{}
+		if err := json.Unmarshal(value, card); err != nil {
+			return nil, 0, err
+		}
+		taken.Cards = append(taken.Cards, card)
+	}
+
+	remaining, err := redigo.Int(replies[3], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return taken, remaining, nil
+}
+
+func (store *redisDeckStore) Replace(accountID int64, built *deck.Deck) error {
+	key := fmt.Sprintf(KeyDeck, accountID)
+	args := redigo.Args{}.Add(key)
+	for _, card := range built.Cards {
+		value, err := json.Marshal(card)
+		if err != nil {
+			return err
+		}
+		args = args.Add(value)
+	}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	expiration := int64(store.expiration / time.Second)
+	conn.Send("MULTI")
+	conn.Send("DEL", key)
+	if len(built.Cards) > 0 {
+		conn.Send("RPUSH", args...)
+		conn.Send("EXPIRE", key, expiration)
+	}
+	conn.Send("SET", fmt.Sprintf(KeyDeckOrigin, accountID), formatOrigin(built.Lat, built.Long), "EX", expiration)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (store *redisDeckStore) MarkServed(accountID int64, cards model.DeckCards) error {
+	if len(cards) == 0 {
+		return nil
+	}
+
+	now := store.now()
+	key := fmt.Sprintf(KeyDeckServed, accountID)
+	args := redigo.Args{}.Add(key)
+	for _, card := range cards {
+		args = args.Add(now.Unix(), deck.CardKey(card))
+	}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("ZADD", args...)
+	conn.Send("ZREMRANGEBYSCORE", key, "-inf", "("+strconv.FormatInt(now.Add(-store.expiration).Unix(), 10))
+	conn.Send("EXPIRE", key, int64(store.expiration/time.Second))
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (store *redisDeckStore) Served(accountID int64) (map[string]bool, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	since := store.now().Add(-store.expiration).Unix()
+	members, err := redigo.Strings(conn.Do("ZRANGEBYSCORE", fmt.Sprintf(KeyDeckServed, accountID), since, "+inf"))
+	if err != nil {
+		return nil, err
+	}
+
+	served := make(map[string]bool, len(members))
+	for _, member := range members {
+		served[member] = true
+	}
+
+	return served, nil
+}
+
+func (store *redisDeckStore) LockRefill(accountID int64) (bool, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	_, err := redigo.String(conn.Do("SET", fmt.Sprintf(KeyDeckRefill, accountID), 1, "NX", "EX", int64(refillLockTimeout/time.Second)))
+	if err == redigo.ErrNil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func formatOrigin(lat, long float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(long, 'f', -1, 64)
+}
+
+func parseOrigin(origin string) (float64, float64, error) {
+	parts := strings.SplitN(origin, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("malformed deck origin %q", origin)
+	}
+
+	lat, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	long, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return lat, long, nil
+}
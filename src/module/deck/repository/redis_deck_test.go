@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/redistest"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/deck"
+)
+
+func restaurantCards(ids ...int64) model.DeckCards {
+	cards := model.DeckCards{}
+	for _, id := range ids {
+		cards = append(cards, &model.DeckCard{Type: model.VenueTypeRestaurant, Restaurant: &model.Restaurant{RestaurantID: id}})
+	}
+	return cards
+}
+
+// Served cards must age out one by one even while the account keeps
+// getting dealt new ones, or a busy account would never see them again.
+func TestServedCardsAgeOut(t *testing.T) {
+	pool, mr := redistest.NewPool(t)
+
+	now := time.Unix(1700000000, 0)
+	store := &redisDeckStore{pool: pool, expiration: time.Hour, now: func() time.Time { return now }}
+
+	if err := store.MarkServed(7, restaurantCards(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(40 * time.Minute)
+	if err := store.MarkServed(7, restaurantCards(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rebuilding the deck leaves the served cards' expiry alone.
+	mr.FastForward(10 * time.Minute)
+	servedKey := fmt.Sprintf(KeyDeckServed, 7)
+	ttl := mr.TTL(servedKey)
+	if err := store.Replace(7, &deck.Deck{Cards: restaurantCards(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mr.TTL(servedKey); got != ttl {
+		t.Errorf("served TTL = %v after Replace, want it left at %v", got, ttl)
+	}
+
+	now = now.Add(30 * time.Minute)
+	served, err := store.Served(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if served["restaurant:1"] || !served["restaurant:2"] || len(served) != 1 {
+		t.Errorf("Served() = %v, want only restaurant:2 after restaurant:1 aged out", served)
+	}
+}
@@ -0,0 +1,179 @@
+package deck
+
+import (
+	"log"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/model"
+)
+
+// likesConsidered bounds how many recent likes shape an account's taste.
+const likesConsidered = 100
+
+type Usecase interface {
+	GetDeck(accountID int64, lat, long float64, size int) (model.DeckCards, error)
+	BuildSharedDeck(lat, long float64) (model.DeckCards, error)
+}
+
+type usecase struct {
+	deckRepo  DeckRepository
+	deckStore DeckStore
+	config    config.DeckConfig
+}
+
+func NewDeckUsecase(
+	deckRepo DeckRepository,
+	deckStore DeckStore,
+	deckConfig config.DeckConfig,
+) Usecase {
+	return &usecase{
+		deckRepo:  deckRepo,
+		deckStore: deckStore,
+		config:    deckConfig,
+	}
+}
+
+// GetDeck deals the account's next size cards around the given point. The
+// stored deck is rebuilt on the spot when there is none, it ran out or the
+// account moved away from where it was built, and refilled in the
+// background when it runs low. Without the store, a deck is built for this
+// request alone.
+func (u *usecase) GetDeck(accountID int64, lat, long float64, size int) (model.DeckCards, error) {
+	deck, remaining, err := u.deckStore.Take(accountID, size)
+	if err != nil {
+		log.Println(err)
+		cards, err := u.buildDeck(accountID, lat, long, nil)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return cards[:minInt(size, len(cards))], nil
+	}
+
+	stale := deck == nil || len(deck.Cards) == 0 ||
+		geo.DistanceKM(deck.Lat, deck.Long, lat, long) > u.config.RebuildDistanceKM
+	if stale {
+		deck, err = u.rebuildDeck(accountID, lat, long)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		n := minInt(size, len(deck.Cards))
+		dealt := deck.Cards[:n]
+		deck.Cards = deck.Cards[n:]
+		if err := u.deckStore.Replace(accountID, deck); err != nil {
+			log.Println(err)
+		}
+		deck.Cards = dealt
+	} else if remaining < u.config.LowWatermark {
+		go u.refillDeck(accountID, deck.Lat, deck.Long)
+	}
+
+	if err := u.deckStore.MarkServed(accountID, deck.Cards); err != nil {
+		log.Println(err)
+	}
+
+	return deck.Cards, nil
+}
+
+// BuildSharedDeck builds a deck around the given point that suits no one
+// account in particular, ranked without anyone's swipes or likes.
+func (u *usecase) BuildSharedDeck(lat, long float64) (model.DeckCards, error) {
+	cards, err := u.buildDeck(0, lat, long, nil)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+// rebuildDeck builds a new deck around the given point, leaving out the
+// cards already served.
+func (u *usecase) rebuildDeck(accountID int64, lat, long float64) (*Deck, error) {
+	served, err := u.deckStore.Served(accountID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	cards, err := u.buildDeck(accountID, lat, long, served)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &Deck{Lat: lat, Long: long, Cards: cards}, nil
+}
+
+// refillDeck rebuilds the deck around the point it was built for. Cards
+// dealt while it was being built are dropped from it before it's stored.
+func (u *usecase) refillDeck(accountID int64, lat, long float64) {
+	locked, err := u.deckStore.LockRefill(accountID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !locked {
+		return
+	}
+
+	deck, err := u.rebuildDeck(accountID, lat, long)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	served, err := u.deckStore.Served(accountID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	cards := deck.Cards[:0]
+	for _, card := range deck.Cards {
+		if !served[CardKey(card)] {
+			cards = append(cards, card)
+		}
+	}
+	deck.Cards = cards
+
+	if err := u.deckStore.Replace(accountID, deck); err != nil {
+		log.Println(err)
+	}
+}
+
+// buildDeck ranks the unswiped venues near the given point for the account
+// and interleaves restaurants and recreations. The excluded cards are left
+// out by the candidate query itself, so farther venues take their place.
+func (u *usecase) buildDeck(accountID int64, lat, long float64, excluded map[string]bool) (model.DeckCards, error) {
+	likes, err := u.deckRepo.FindLikes(accountID, likesConsidered)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	accountTaste := newTaste(likes)
+
+	ranked := make(map[string]model.DeckCards)
+	for _, venueType := range []string{model.VenueTypeRestaurant, model.VenueTypeRecreation} {
+		cards, err := u.deckRepo.FindCandidates(accountID, venueType, lat, long, u.config.RadiusKM, idsOfType(excluded, venueType), u.config.Capacity)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		rank(cards, accountTaste, u.config)
+		ranked[venueType] = cards
+	}
+
+	cards := interleave(
+		ranked[model.VenueTypeRestaurant],
+		ranked[model.VenueTypeRecreation],
+		u.config.RestaurantRatio,
+		u.config.RecreationRatio,
+	)
+	return cards[:minInt(u.config.Capacity, len(cards))], nil
+}
@@ -0,0 +1,109 @@
+package deck
+
+import (
+	"testing"
+
+	"github.com/atletaid/go-template/config"
+	"github.com/atletaid/go-template/src/model"
+)
+
+// fakeDeckRepo answers candidates from a fixed list, nearest first, the way
+// the SQL does.
+type fakeDeckRepo struct {
+	restaurants model.DeckCards
+	excluded    map[string][]int64
+}
+
+func (repo *fakeDeckRepo) FindCandidates(accountID int64, venueType string, lat, long, radiusKM float64, excluded []int64, limit int) (model.DeckCards, error) {
+	repo.excluded[venueType] = excluded
+
+	cards := model.DeckCards{}
+	if venueType != model.VenueTypeRestaurant {
+		return cards, nil
+	}
+
+	for _, card := range repo.restaurants {
+		if len(cards) == limit {
+			break
+		}
+		if !containsID(excluded, card.VenueID()) {
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
+
+func (repo *fakeDeckRepo) FindLikes(accountID int64, limit int) ([]*Like, error) {
+	return nil, nil
+}
+
+type fakeDeckStore struct {
+	served map[string]bool
+	stored *Deck
+}
+
+func (store *fakeDeckStore) Take(accountID int64, count int) (*Deck, int, error) {
+	return nil, 0, nil
+}
+
+func (store *fakeDeckStore) Replace(accountID int64, deck *Deck) error {
+	stored := *deck
+	store.stored = &stored
+	return nil
+}
+
+func (store *fakeDeckStore) MarkServed(accountID int64, cards model.DeckCards) error {
+	for _, card := range cards {
+		store.served[CardKey(card)] = true
+	}
+	return nil
+}
+
+func (store *fakeDeckStore) Served(accountID int64) (map[string]bool, error) {
+	return store.served, nil
+}
+
+func (store *fakeDeckStore) LockRefill(accountID int64) (bool, error) {
+	return false, nil
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// An account dealt the nearest Capacity venues without swiping them must
+// still get the farther ones on the next rebuild.
+func TestRebuildSkipsServedCardsInTheQuery(t *testing.T) {
+	repo := &fakeDeckRepo{
+		restaurants: model.DeckCards{
+			restaurantCard(1, 0, 0, "", 1),
+			restaurantCard(2, 0, 0, "", 2),
+			restaurantCard(3, 0, 0, "", 3),
+			restaurantCard(4, 0, 0, "", 4),
+		},
+		excluded: map[string][]int64{},
+	}
+	store := &fakeDeckStore{served: map[string]bool{"restaurant:1": true, "restaurant:2": true}}
+	u := NewDeckUsecase(repo, store, config.DeckConfig{Capacity: 2, RadiusKM: 10, RestaurantRatio: 1, RecreationRatio: 1})
+
+	cards, err := u.GetDeck(7, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := repo.excluded[model.VenueTypeRestaurant]; len(got) != 2 || !containsID(got, 1) || !containsID(got, 2) {
+		t.Errorf("FindCandidates() excluded = %v, want the served restaurants 1 and 2", got)
+	}
+
+	if got := venueIDs(cards); len(got) != 1 || got[0] != 3 {
+		t.Errorf("GetDeck() = %v, want restaurant 3", got)
+	}
+	if got := venueIDs(store.stored.Cards); len(got) != 1 || got[0] != 4 {
+		t.Errorf("stored deck = %v, want restaurant 4 left", got)
+	}
+}
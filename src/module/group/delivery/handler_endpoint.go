@@ -0,0 +1,306 @@
+package delivery
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/group"
+	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+type GroupHandler struct {
+	gu                group.Usecase
+	defaultSize       int
+	heartbeatInterval time.Duration
+}
+
+func NewGroupHandler(router *gin.Engine, m *auth.Middleware, gu group.Usecase, defaultSize int, heartbeatInterval time.Duration) *gin.Engine {
+	handler := &GroupHandler{gu, defaultSize, heartbeatInterval}
+
+	v1 := router.Group("/api/v1", m.AuthUserToken(), m.RequireVerified())
+	v1.POST("/sessions", handler.CreateSessionEndpoint())
+	v1.POST("/session-invites/join", handler.JoinSessionEndpoint())
+	v1.GET("/sessions/:session_id", handler.GetSessionEndpoint())
+	v1.GET("/sessions/:session_id/deck", handler.GetSessionDeckEndpoint())
+	v1.POST("/sessions/:session_id/swipe", handler.SwipeEndpoint())
+	v1.GET("/sessions/:session_id/events", handler.EventsEndpoint())
+
+	return router
+}
+
+type createSessionRequest struct {
+	Quorum int      `json:"quorum" form:"quorum" binding:"omitempty,min=1"`
+	Lat    *float64 `json:"lat" form:"lat" binding:"required,gte=-85,lte=85"`
+	Long   *float64 `json:"long" form:"long" binding:"required,gte=-180,lte=180"`
+}
+
+type joinSessionRequest struct {
+	InviteCode string `json:"invite_code" form:"invite_code" binding:"required,len=8"`
+}
+
+type getSessionDeckRequest struct {
+	Size int `json:"size" form:"size" binding:"omitempty,min=1,max=50"`
+}
+
+type groupSwipeRequest struct {
+	VenueType string `json:"venue_type" form:"venue_type" binding:"required,eq=restaurant|eq=recreation"`
+	VenueID   int64  `json:"venue_id" form:"venue_id" binding:"required,min=1"`
+	Direction string `json:"direction" form:"direction" binding:"required,eq=like|eq=dislike|eq=superlike"`
+}
+
+type eventsRequest struct {
+	LastEventID int64 `json:"last_event_id" form:"last_event_id" binding:"omitempty,min=0"`
+}
+
+type dataSessionDeckResponse struct {
+	Cards model.DeckCards `json:"cards"`
+}
+
+// CreateSessionEndpoint starts a group session around lat and long owned by
+// the caller. A quorum of 0 means every member has to like a venue; one
+// above the member limit is refused.
+func (h *GroupHandler) CreateSessionEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := createSessionRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		session, err := h.gu.CreateSession(accountID, req.Quorum, *req.Lat, *req.Long)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success create session"}, processTime, session)
+	}
+}
+
+// JoinSessionEndpoint adds the caller to the session of the invite code.
+func (h *GroupHandler) JoinSessionEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := joinSessionRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		session, err := h.gu.JoinSession(accountID, req.InviteCode)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success join session"}, processTime, session)
+	}
+}
+
+// GetSessionEndpoint returns the session with its members and matches.
+func (h *GroupHandler) GetSessionEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, _ := auth.AccountID(c)
+		session, err := h.gu.GetSession(accountID, c.Param("session_id"))
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get session"}, processTime, session)
+	}
+}
+
+// GetSessionDeckEndpoint returns the shared deck cards the caller has not
+// swiped on yet.
+func (h *GroupHandler) GetSessionDeckEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := getSessionDeckRequest{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if req.Size == 0 {
+			req.Size = h.defaultSize
+		}
+
+		accountID, _ := auth.AccountID(c)
+		cards, err := h.gu.GetSessionDeck(accountID, c.Param("session_id"), req.Size)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		resp := dataSessionDeckResponse{
+			Cards: cards,
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get session deck"}, processTime, resp)
+	}
+}
+
+// SwipeEndpoint records the caller's swipe on a card of the shared deck.
+func (h *GroupHandler) SwipeEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := groupSwipeRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		result, err := h.gu.Swipe(accountID, c.Param("session_id"), req.VenueType, req.VenueID, req.Direction)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success swipe"}, processTime, result)
+	}
+}
+
+// EventsEndpoint streams the session's member joins, swipes and matches as
+// server-sent events. A reconnecting client resumes after the ID in its
+// Last-Event-ID header, or in last_event_id for clients that can't set
+// it. Comments are sent as heartbeats to keep idle connections open.
+func (h *GroupHandler) EventsEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := eventsRequest{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if header := c.GetHeader("Last-Event-ID"); header != "" {
+			lastEventID, err := strconv.ParseInt(header, 10, 64)
+			if err != nil {
+				log.Println(err)
+				processTime := time.Now().Sub(startTime).Seconds()
+				httputil.WriteDecodeErrorResponse(c, processTime, &req)
+				return
+			}
+			req.LastEventID = lastEventID
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		events, cancel, err := h.gu.SubscribeEvents(accountID, c.Param("session_id"), req.LastEventID)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+		defer cancel()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(200)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(h.heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data); err != nil {
+					log.Println(err)
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+					log.Println(err)
+					return
+				}
+			case <-c.Request.Context().Done():
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package group
+
+import (
+	"github.com/atletaid/go-template/src/model"
+)
+
+// GroupStore keeps group sessions, shared by every instance. Each call on a
+// session counts as activity and pushes back its expiry.
+type GroupStore interface {
+	CreateSession(session *model.GroupSession, cards model.DeckCards) error
+	FindSessionIDByInvite(inviteCode string) (string, error)
+	FindSession(sessionID string) (*model.GroupSession, error)
+	// AddMember reports whether accountID newly joined, rather than
+	// already being a member.
+	AddMember(sessionID string, accountID int64, maxMembers int) (bool, error)
+	IsMember(sessionID string, accountID int64) (bool, error)
+	// FindDeck lists the deck's cards the member hasn't swiped yet, in
+	// deck order.
+	FindDeck(sessionID string, accountID int64) (model.DeckCards, error)
+	SaveSwipe(sessionID string, accountID int64, venueType string, venueID int64, direction string) (*model.GroupSwipeResult, error)
+}
+
+// EventBus fans session events out to every instance, and keeps the latest
+// ones so a client reconnecting can catch up on what it missed.
+type EventBus interface {
+	Publish(sessionID, eventType string, data interface{}) error
+	FindEventsAfter(sessionID string, lastEventID int64) ([]*model.GroupEvent, error)
+	// Subscribe delivers the session's events published from now on until
+	// unsubscribe is called. The channel is closed early if the instance
+	// loses its subscription, so a client can resume from its last event.
+	// It fails with apperror.ServiceUnavailable when no subscription can be
+	// made.
+	Subscribe(sessionID string) (events <-chan *model.GroupEvent, unsubscribe func(), err error)
+}
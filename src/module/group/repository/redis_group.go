@@ -0,0 +1,485 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/deck"
+	"github.com/atletaid/go-template/src/module/group"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	KeyGroupInvite = "group:invite:%s"
+	// KeyGroupSession hash-tags the session ID so all of a session's keys
+	// share a cluster slot and can be used together in one script.
+	KeyGroupSession = "group:session:{%s}"
+
+	// inviteAttempts bounds the retries when a new invite code is taken.
+	inviteAttempts = 5
+
+	// inviteAlphabet leaves out characters that are easily misread.
+	inviteAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	inviteCodeLength = 8
+)
+
+// sessionKeys are the keys of one session, all under its session key:
+// 0 the session hash, 1 its members, 2 the deck cards by card key, 3 the
+// deck order, 4 the votes by "card key|member", 5 the likes per card key,
+// 6 the matches scored by time, 7 the swipe count per member, 8 the last
+// event ID and 9 the event log. The invite code key lives in another slot,
+// so it is never part of a script or transaction with them.
+func sessionKeys(sessionID string) []interface{} {
+	base := fmt.Sprintf(KeyGroupSession, sessionID)
+	return []interface{}{
+		base,
+		base + ":members",
+		base + ":deck",
+		base + ":order",
+		base + ":votes",
+		base + ":likes",
+		base + ":matches",
+		base + ":progress",
+		base + ":event_id",
+		base + ":events",
+	}
+}
+
+// touchScript pushes back the expiry of every key of a session. The
+// timeout in seconds is the script's last argument.
+const touchScript = `
+for i = 1, #KEYS do
+	redis.call('EXPIRE', KEYS[i], ARGV[#ARGV])
+end
+`
+
+// joinScript adds ARGV[1] to the members unless there are ARGV[2] already,
+// and pushes back the expiry by ARGV[3] seconds. It returns 1 on joining,
+// 0 for a member, -1 for a full session and -2 for an expired one.
+var joinScript = redigo.NewScript(len(sessionKeys("")), `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -2
+end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+	return 0
+end
+if redis.call('SCARD', KEYS[2]) >= tonumber(ARGV[2]) then
+	return -1
+end
+redis.call('SADD', KEYS[2], ARGV[1])
+`+touchScript+`
+return 1
+`)
+
+// swipeScript records member ARGV[1]'s vote ARGV[3] on card ARGV[2] at
+// time ARGV[4], then matches the card once enough members like it and
+// pushes back the expiry by ARGV[5] seconds. It returns {-1} for a
+// stranger, {-2} for a card not in the deck, {-3} for an expired session,
+// and otherwise {matched, likes, quorum}.
+var swipeScript = redigo.NewScript(len(sessionKeys("")), `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {-3}
+end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 0 then
+	return {-1}
+end
+if redis.call('HEXISTS', KEYS[3], ARGV[2]) == 0 then
+	return {-2}
+end
+
+local vote = ARGV[2] .. '|' .. ARGV[1]
+local previous = redis.call('HGET', KEYS[5], vote)
+local liked = ARGV[3] ~= 'dislike'
+local wasLiked = previous and previous ~= 'dislike'
+redis.call('HSET', KEYS[5], vote, ARGV[3])
+if not previous then
+	redis.call('HINCRBY', KEYS[8], ARGV[1], 1)
+end
+
+local likes = tonumber(redis.call('HGET', KEYS[6], ARGV[2]) or '0')
+if liked and not wasLiked then
+	likes = redis.call('HINCRBY', KEYS[6], ARGV[2], 1)
+elseif wasLiked and not liked then
+	likes = redis.call('HINCRBY', KEYS[6], ARGV[2], -1)
+end
+
+local quorum = tonumber(redis.call('HGET', KEYS[1], 'quorum'))
+if quorum == 0 then
+	quorum = redis.call('SCARD', KEYS[2])
+end
+
+local matched = 0
+if likes >= quorum and not redis.call('ZSCORE', KEYS[7], ARGV[2]) then
+	redis.call('ZADD', KEYS[7], ARGV[4], ARGV[2])
+	matched = 1
+end
+`+touchScript+`
+return {matched, likes, quorum}
+`)
+
+// redisGroupStore keeps each session in a handful of keys that expire
+// together after timeout without activity.
+type redisGroupStore struct {
+	pool    *redigo.Pool
+	timeout time.Duration
+}
+
+func NewGroupStore(pool *redigo.Pool, timeout time.Duration) group.GroupStore {
+	return &redisGroupStore{
+		pool:    pool,
+		timeout: timeout,
+	}
+}
+
+func (store *redisGroupStore) CreateSession(session *model.GroupSession, cards model.DeckCards) error {
+	sessionID, err := randomSessionID()
+	if err != nil {
+		return err
+	}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	inviteCode, err := store.claimInviteCode(conn, sessionID)
+	if err != nil {
+		return err
+	}
+
+	session.SessionID = sessionID
+	session.InviteCode = inviteCode
+	keys := sessionKeys(sessionID)
+
+	deckArgs := redigo.Args{}.Add(keys[2])
+	orderArgs := redigo.Args{}.Add(keys[3])
+	for _, card := range cards {
+		value, err := json.Marshal(card)
+		if err != nil {
+			return err
+		}
+		deckArgs = deckArgs.Add(deck.CardKey(card), value)
+		orderArgs = orderArgs.Add(deck.CardKey(card))
+	}
+
+	conn.Send("MULTI")
+	conn.Send("HMSET", keys[0],
+		"invite_code", inviteCode,
+		"owner_id", session.OwnerID,
+		"quorum", session.Quorum,
+		"lat", session.Lat,
+		"long", session.Long,
+		"created_at", session.CreatedAt.Unix(),
+	)
+	conn.Send("SADD", keys[1], session.OwnerID)
+	if len(cards) > 0 {
+		conn.Send("HMSET", deckArgs...)
+		conn.Send("RPUSH", orderArgs...)
+	}
+	store.sendTouch(conn, keys)
+	if _, err := conn.Do("EXEC"); err != nil {
+		conn.Do("DEL", fmt.Sprintf(KeyGroupInvite, inviteCode))
+		return err
+	}
+
+	return nil
+}
+
+// claimInviteCode reserves a random invite code for sessionID, drawing
+// again if the code is already taken by another live session.
+func (store *redisGroupStore) claimInviteCode(conn redigo.Conn, sessionID string) (string, error) {
+	for attempt := 0; attempt < inviteAttempts; attempt++ {
+		inviteCode, err := randomInviteCode()
+		if err != nil {
+			return "", err
+		}
+
+		_, err = redigo.String(conn.Do("SET", fmt.Sprintf(KeyGroupInvite, inviteCode), sessionID, "EX", int64(store.timeout/time.Second), "NX"))
+		if err == redigo.ErrNil {
+			continue
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		return inviteCode, nil
+	}
+
+	return "", fmt.Errorf("no free invite code after %d attempts", inviteAttempts)
+}
+
+func (store *redisGroupStore) FindSessionIDByInvite(inviteCode string) (string, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	sessionID, err := redigo.String(conn.Do("GET", fmt.Sprintf(KeyGroupInvite, inviteCode)))
+	if err == redigo.ErrNil {
+		return "", apperror.GroupSessionNotExists
+	}
+
+	return sessionID, err
+}
+
+func (store *redisGroupStore) FindSession(sessionID string) (*model.GroupSession, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	keys := sessionKeys(sessionID)
+	conn.Send("MULTI")
+	conn.Send("HGETALL", keys[0])
+	conn.Send("SMEMBERS", keys[1])
+	conn.Send("ZRANGE", keys[6], 0, -1)
+	replies, err := redigo.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := redigo.StringMap(replies[0], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, apperror.GroupSessionNotExists
+	}
+
+	session := &model.GroupSession{
+		SessionID:  sessionID,
+		InviteCode: fields["invite_code"],
+	}
+	session.OwnerID, _ = strconv.ParseInt(fields["owner_id"], 10, 64)
+	session.Quorum, _ = strconv.Atoi(fields["quorum"])
+	session.Lat, _ = strconv.ParseFloat(fields["lat"], 64)
+	session.Long, _ = strconv.ParseFloat(fields["long"], 64)
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	session.CreatedAt = time.Unix(createdAt, 0)
+
+	if session.Members, err = redigo.Int64s(replies[1], nil); err != nil {
+		return nil, err
+	}
+
+	matchKeys, err := redigo.Strings(replies[2], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Matches, err = store.findCards(conn, keys[2], matchKeys); err != nil {
+		return nil, err
+	}
+
+	if err := store.touch(conn, keys, session.InviteCode); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (store *redisGroupStore) AddMember(sessionID string, accountID int64, maxMembers int) (bool, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	keys, inviteCode, err := store.existingKeys(conn, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	joined, err := redigo.Int(joinScript.Do(conn, append(keys, accountID, maxMembers, int64(store.timeout/time.Second))...))
+	if err != nil {
+		return false, err
+	}
+
+	switch joined {
+	case -1:
+		return false, apperror.GroupSessionFull
+	case -2:
+		return false, apperror.GroupSessionNotExists
+	}
+
+	if err := store.touchInvite(conn, inviteCode); err != nil {
+		return false, err
+	}
+
+	return joined == 1, nil
+}
+
+func (store *redisGroupStore) IsMember(sessionID string, accountID int64) (bool, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	keys, _, err := store.existingKeys(conn, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	return redigo.Bool(conn.Do("SISMEMBER", keys[1], accountID))
+}
+
+func (store *redisGroupStore) FindDeck(sessionID string, accountID int64) (model.DeckCards, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	keys, inviteCode, err := store.existingKeys(conn, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := redigo.Strings(conn.Do("LRANGE", keys[3], 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	votes := redigo.Args{}.Add(keys[4])
+	for _, cardKey := range order {
+		votes = votes.Add(fmt.Sprintf("%s|%d", cardKey, accountID))
+	}
+
+	cardKeys := make([]string, 0, len(order))
+	if len(order) > 0 {
+		voted, err := redigo.Strings(conn.Do("HMGET", votes...))
+		if err != nil {
+			return nil, err
+		}
+
+		for i, cardKey := range order {
+			if voted[i] == "" {
+				cardKeys = append(cardKeys, cardKey)
+			}
+		}
+	}
+
+	cards, err := store.findCards(conn, keys[2], cardKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.touch(conn, keys, inviteCode); err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (store *redisGroupStore) SaveSwipe(sessionID string, accountID int64, venueType string, venueID int64, direction string) (*model.GroupSwipeResult, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	keys, inviteCode, err := store.existingKeys(conn, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	cardKey := fmt.Sprintf("%s:%d", venueType, venueID)
+	args := append(keys, accountID, cardKey, direction, time.Now().UnixNano(), int64(store.timeout/time.Second))
+	replies, err := redigo.Ints(swipeScript.Do(conn, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	switch replies[0] {
+	case -1:
+		return nil, apperror.NotGroupMember
+	case -2:
+		return nil, apperror.VenueNotInDeck
+	case -3:
+		return nil, apperror.GroupSessionNotExists
+	}
+
+	if err := store.touchInvite(conn, inviteCode); err != nil {
+		return nil, err
+	}
+
+	cards, err := store.findCards(conn, keys[2], []string{cardKey})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.GroupSwipeResult{
+		Match:  replies[0] == 1,
+		Likes:  replies[1],
+		Quorum: replies[2],
+		Card:   cards[0],
+	}, nil
+}
+
+// existingKeys returns the keys and invite code of the session, or
+// GroupSessionNotExists once it has expired.
+func (store *redisGroupStore) existingKeys(conn redigo.Conn, sessionID string) ([]interface{}, string, error) {
+	inviteCode, err := redigo.String(conn.Do("HGET", fmt.Sprintf(KeyGroupSession, sessionID), "invite_code"))
+	if err == redigo.ErrNil {
+		return nil, "", apperror.GroupSessionNotExists
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return sessionKeys(sessionID), inviteCode, nil
+}
+
+func (store *redisGroupStore) findCards(conn redigo.Conn, deckKey interface{}, cardKeys []string) (model.DeckCards, error) {
+	cards := make(model.DeckCards, 0, len(cardKeys))
+	if len(cardKeys) == 0 {
+		return cards, nil
+	}
+
+	values, err := redigo.ByteSlices(conn.Do("HMGET", redigo.Args{}.Add(deckKey).AddFlat(cardKeys)...))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		card := &model.DeckCard{}
+		if err := json.Unmarshal(value, card); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, nil
+}
+
+func (store *redisGroupStore) touch(conn redigo.Conn, keys []interface{}, inviteCode string) error {
+	conn.Send("MULTI")
+	store.sendTouch(conn, keys)
+	if _, err := conn.Do("EXEC"); err != nil {
+		return err
+	}
+
+	return store.touchInvite(conn, inviteCode)
+}
+
+func (store *redisGroupStore) touchInvite(conn redigo.Conn, inviteCode string) error {
+	_, err := conn.Do("EXPIRE", fmt.Sprintf(KeyGroupInvite, inviteCode), int64(store.timeout/time.Second))
+	return err
+}
+
+func (store *redisGroupStore) sendTouch(conn redigo.Conn, keys []interface{}) {
+	for _, key := range keys {
+		conn.Send("EXPIRE", key, int64(store.timeout/time.Second))
+	}
+}
+
+func randomSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func randomInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+	}
+	return string(b), nil
+}
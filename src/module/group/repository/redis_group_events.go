@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/group"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const (
+	ChannelGroupEvents = "group:events:%s"
+
+	// eventBuffer is how far a subscriber may fall behind before it is
+	// dropped and left to resume from its last event.
+	eventBuffer = 64
+
+	pingInterval = 30 * time.Second
+)
+
+// publishScript numbers event ARGV[2] with data ARGV[3], appends it to the
+// log trimmed to ARGV[4] entries and publishes it on channel ARGV[1]. The
+// event is encoded as "id type data" both in the log and on the channel.
+var publishScript = redigo.NewScript(2, `
+local id = redis.call('INCR', KEYS[1])
+local event = id .. ' ' .. ARGV[2] .. ' ' .. ARGV[3]
+redis.call('RPUSH', KEYS[2], event)
+redis.call('LTRIM', KEYS[2], -tonumber(ARGV[4]), -1)
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+redis.call('EXPIRE', KEYS[2], ARGV[5])
+redis.call('PUBLISH', ARGV[1], event)
+return id
+`)
+
+// redisEventBus shares one pattern subscription per instance between all
+// local subscribers. It is opened by the first subscriber, and when it
+// breaks every subscriber is closed so the next one opens it again.
+type redisEventBus struct {
+	pool    *redigo.Pool
+	timeout time.Duration
+	logSize int
+
+	mu          sync.Mutex
+	conn        *subscription
+	subscribers map[string]map[chan *model.GroupEvent]struct{}
+}
+
+// subscription is one connection holding the pattern subscription. ready
+// is closed once it is confirmed, or has failed with err.
+type subscription struct {
+	ready chan struct{}
+	err   error
+}
+
+func NewEventBus(pool *redigo.Pool, timeout time.Duration, logSize int) group.EventBus {
+	return &redisEventBus{
+		pool:        pool,
+		timeout:     timeout,
+		logSize:     logSize,
+		subscribers: make(map[string]map[chan *model.GroupEvent]struct{}),
+	}
+}
+
+func (bus *redisEventBus) Publish(sessionID, eventType string, data interface{}) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	conn := bus.pool.Get()
+	defer conn.Close()
+
+	keys := sessionKeys(sessionID)
+	_, err = publishScript.Do(conn, keys[8], keys[9],
+		fmt.Sprintf(ChannelGroupEvents, sessionID), eventType, value, bus.logSize, int64(bus.timeout/time.Second))
+	return err
+}
+
+func (bus *redisEventBus) FindEventsAfter(sessionID string, lastEventID int64) ([]*model.GroupEvent, error) {
+	conn := bus.pool.Get()
+	defer conn.Close()
+
+	values, err := redigo.Strings(conn.Do("LRANGE", sessionKeys(sessionID)[9], 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*model.GroupEvent, 0, len(values))
+	for _, value := range values {
+		event, err := decodeEvent(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if event.ID > lastEventID {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (bus *redisEventBus) Subscribe(sessionID string) (<-chan *model.GroupEvent, func(), error) {
+	events := make(chan *model.GroupEvent, eventBuffer)
+
+	bus.mu.Lock()
+	if bus.conn == nil {
+		bus.conn = &subscription{ready: make(chan struct{})}
+		go bus.listen(bus.conn)
+	}
+	conn := bus.conn
+
+	if bus.subscribers[sessionID] == nil {
+		bus.subscribers[sessionID] = make(map[chan *model.GroupEvent]struct{})
+	}
+	bus.subscribers[sessionID][events] = struct{}{}
+	bus.mu.Unlock()
+
+	// Wait for the subscription to be in place so nothing published after
+	// Subscribe returns is missed. If it failed, events is already closed.
+	<-conn.ready
+	if conn.err != nil {
+		return nil, nil, apperror.ServiceUnavailable.Wrap(conn.err)
+	}
+
+	return events, func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		bus.drop(sessionID, events)
+	}, nil
+}
+
+// listen holds the pattern subscription until it breaks, then closes every
+// subscriber so their clients resume through a new one.
+func (bus *redisEventBus) listen(conn *subscription) {
+	psc := redigo.PubSubConn{Conn: bus.pool.Get()}
+	defer psc.Close()
+
+	err := bus.receive(psc, conn)
+	log.Println("group event subscriber disconnected:", err)
+
+	bus.mu.Lock()
+	for sessionID, events := range bus.subscribers {
+		for ch := range events {
+			bus.drop(sessionID, ch)
+		}
+	}
+	bus.conn = nil
+	bus.mu.Unlock()
+
+	select {
+	case <-conn.ready:
+	default:
+		conn.err = err
+		close(conn.ready)
+	}
+}
+
+// receive dispatches published events until the connection fails. Like the
+// cache invalidation subscriber it pings the server and gives up when
+// nothing, not even a pong, arrives for two ping intervals, so a silently
+// dropped connection is noticed.
+func (bus *redisEventBus) receive(psc redigo.PubSubConn, conn *subscription) error {
+	if err := psc.PSubscribe(fmt.Sprintf(ChannelGroupEvents, "*")); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					log.Println(err)
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * pingInterval).(type) {
+		case redigo.Message:
+			bus.dispatch(v.Channel, string(v.Data))
+		case redigo.Subscription:
+			if v.Kind == "psubscribe" && conn.err == nil {
+				select {
+				case <-conn.ready:
+				default:
+					close(conn.ready)
+				}
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+func (bus *redisEventBus) dispatch(channel, value string) {
+	event, err := decodeEvent(value)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	sessionID := strings.TrimPrefix(channel, fmt.Sprintf(ChannelGroupEvents, ""))
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for ch := range bus.subscribers[sessionID] {
+		select {
+		case ch <- event:
+		default:
+			log.Println("dropping slow subscriber of group session", sessionID)
+			bus.drop(sessionID, ch)
+		}
+	}
+}
+
+// drop closes a subscriber unless that already happened. The caller holds
+// bus.mu.
+func (bus *redisEventBus) drop(sessionID string, ch chan *model.GroupEvent) {
+	events := bus.subscribers[sessionID]
+	if _, ok := events[ch]; !ok {
+		return
+	}
+
+	delete(events, ch)
+	close(ch)
+	if len(events) == 0 {
+		delete(bus.subscribers, sessionID)
+	}
+}
+
+func decodeEvent(value string) (*model.GroupEvent, error) {
+	parts := strings.SplitN(value, " ", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed group event %q", value)
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.GroupEvent{
+		ID:   id,
+		Type: parts[1],
+		Data: json.RawMessage(parts[2]),
+	}, nil
+}
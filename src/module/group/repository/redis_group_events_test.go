@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/redistest"
+	"github.com/atletaid/go-template/src/model"
+)
+
+func newTestEventBus(t *testing.T, logSize int) (*redisEventBus, *miniredis.Miniredis, *redistest.PubSub) {
+	pool, mr, pubSub := redistest.NewPubSubPool(t)
+
+	return NewEventBus(pool, testTimeout, logSize).(*redisEventBus), mr, pubSub
+}
+
+func subscribe(t *testing.T, bus *redisEventBus, sessionID string) (<-chan *model.GroupEvent, func()) {
+	events, unsubscribe, err := bus.Subscribe(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events, unsubscribe
+}
+
+func publish(t *testing.T, bus *redisEventBus, sessionID string, n int) {
+	for i := 0; i < n; i++ {
+		if err := bus.Publish(sessionID, "test", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// receive reads the next event, or reports ok=false if events is closed.
+func receive(t *testing.T, events <-chan *model.GroupEvent) (*model.GroupEvent, bool) {
+	select {
+	case event, ok := <-events:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return nil, false
+	}
+}
+
+func eventIDs(events []*model.GroupEvent) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestPublishKeepsTheLatestEvents(t *testing.T) {
+	bus, mr, _ := newTestEventBus(t, 3)
+
+	publish(t, bus, "s1", 5)
+
+	events, err := bus.FindEventsAfter("s1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventIDs(events); fmt.Sprint(got) != "[3 4 5]" {
+		t.Errorf("FindEventsAfter(0) = %v, want the last 3 of 5", got)
+	}
+	if string(events[2].Data) != `{"n":4}` || events[2].Type != "test" {
+		t.Errorf("event 5 = %s %s, want test {\"n\":4}", events[2].Type, events[2].Data)
+	}
+
+	if events, _ := bus.FindEventsAfter("s1", 4); fmt.Sprint(eventIDs(events)) != "[5]" {
+		t.Errorf("FindEventsAfter(4) = %v, want [5]", eventIDs(events))
+	}
+	if events, _ := bus.FindEventsAfter("s2", 0); len(events) != 0 {
+		t.Errorf("FindEventsAfter() of another session = %v, want none", eventIDs(events))
+	}
+
+	keys := sessionKeys("s1")
+	for _, key := range []interface{}{keys[8], keys[9]} {
+		if got := mr.TTL(key.(string)); got != testTimeout {
+			t.Errorf("%s TTL = %v, want %v", key, got, testTimeout)
+		}
+	}
+}
+
+func TestSubscribeDeliversTheSessionsEvents(t *testing.T) {
+	bus, _, _ := newTestEventBus(t, 10)
+
+	first, unsubscribeFirst := subscribe(t, bus, "s1")
+	second, unsubscribeSecond := subscribe(t, bus, "s1")
+	other, unsubscribeOther := subscribe(t, bus, "s2")
+	defer unsubscribeSecond()
+	defer unsubscribeOther()
+
+	publish(t, bus, "s1", 1)
+
+	for _, events := range []<-chan *model.GroupEvent{first, second} {
+		if event, ok := receive(t, events); !ok || event.ID != 1 {
+			t.Errorf("received %+v, want event 1", event)
+		}
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("subscriber of s2 received %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("events still open after unsubscribe")
+	}
+}
+
+// A subscriber that stops reading is dropped rather than holding up the
+// others; it resumes from its last event through the log.
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus, _, _ := newTestEventBus(t, 100)
+
+	slow, unsubscribeSlow := subscribe(t, bus, "s1")
+	fast, unsubscribeFast := subscribe(t, bus, "s1")
+	defer unsubscribeSlow()
+	defer unsubscribeFast()
+
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range fast {
+			if n++; n == eventBuffer+1 {
+				break
+			}
+		}
+		received <- n
+	}()
+
+	publish(t, bus, "s1", eventBuffer+1)
+
+	if n := <-received; n != eventBuffer+1 {
+		t.Errorf("fast subscriber received %d events, want %d", n, eventBuffer+1)
+	}
+
+	n := 0
+	for {
+		if _, ok := receive(t, slow); !ok {
+			break
+		}
+		n++
+	}
+	if n != eventBuffer {
+		t.Errorf("slow subscriber received %d events before being dropped, want %d", n, eventBuffer)
+	}
+}
+
+// When the shared subscription breaks every stream is closed, and the next
+// Subscribe opens a new one.
+func TestSubscriptionLossClosesEveryStream(t *testing.T) {
+	bus, _, pubSub := newTestEventBus(t, 10)
+
+	first, _ := subscribe(t, bus, "s1")
+	second, _ := subscribe(t, bus, "s2")
+	if got := pubSub.Subscribers(); got != 1 {
+		t.Fatalf("%d redis subscriptions, want 1 shared one", got)
+	}
+
+	pubSub.Drop()
+
+	for _, events := range []<-chan *model.GroupEvent{first, second} {
+		if event, ok := receive(t, events); ok {
+			t.Errorf("received %+v, want the stream closed", event)
+		}
+	}
+
+	again, unsubscribe := subscribe(t, bus, "s1")
+	defer unsubscribe()
+
+	publish(t, bus, "s1", 1)
+	if event, ok := receive(t, again); !ok || event.ID != 1 {
+		t.Errorf("received %+v after resubscribing, want event 1", event)
+	}
+}
+
+func TestSubscribeWithoutRedis(t *testing.T) {
+	bus, mr, _ := newTestEventBus(t, 10)
+	mr.Close()
+
+	if _, _, err := bus.Subscribe("s1"); !errors.Is(err, apperror.ServiceUnavailable) {
+		t.Errorf("Subscribe() error = %v, want ServiceUnavailable", err)
+	}
+}
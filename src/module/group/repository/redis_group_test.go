@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/redistest"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/group"
+)
+
+const testTimeout = time.Hour
+
+func newTestStore(t *testing.T) (group.GroupStore, *miniredis.Miniredis) {
+	pool, mr := redistest.NewPool(t)
+
+	return NewGroupStore(pool, testTimeout), mr
+}
+
+func createTestSession(t *testing.T, store group.GroupStore, quorum int) *model.GroupSession {
+	session := model.NewGroupSession(1, quorum, -6.9, 107.6)
+	cards := model.DeckCards{
+		{Type: model.VenueTypeRestaurant, Restaurant: &model.Restaurant{RestaurantID: 10}},
+		{Type: model.VenueTypeRecreation, Recreation: &model.Recreation{RecreationID: 20}},
+	}
+
+	if err := store.CreateSession(session, cards); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// Every write to a session must push back the expiry of all of its keys,
+// including the invite code, or parts of it outlive the rest.
+func TestSessionKeysExpireTogether(t *testing.T) {
+	store, mr := newTestStore(t)
+	session := createTestSession(t, store, 2)
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"create", func() error { return nil }},
+		{"join", func() error {
+			_, err := store.AddMember(session.SessionID, 2, 5)
+			return err
+		}},
+		{"swipe", func() error {
+			_, err := store.SaveSwipe(session.SessionID, 1, model.VenueTypeRestaurant, 10, model.SwipeLike)
+			return err
+		}},
+		{"match", func() error {
+			_, err := store.SaveSwipe(session.SessionID, 2, model.VenueTypeRestaurant, 10, model.SwipeLike)
+			return err
+		}},
+		{"find", func() error {
+			_, err := store.FindSession(session.SessionID)
+			return err
+		}},
+	}
+
+	keys := []string{fmt.Sprintf(KeyGroupInvite, session.InviteCode)}
+	for _, key := range sessionKeys(session.SessionID) {
+		keys = append(keys, key.(string))
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		for _, key := range keys {
+			if !mr.Exists(key) {
+				continue
+			}
+			if got := mr.TTL(key); got != testTimeout {
+				t.Errorf("%s: TTL(%s) = %v, want %v", step.name, key, got, testTimeout)
+			}
+		}
+
+		// Each step comes half a timeout after the last, so a key the
+		// previous step left alone would expire before the next one.
+		mr.FastForward(testTimeout / 2)
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	store, mr := newTestStore(t)
+	session := createTestSession(t, store, 0)
+	expired := createTestSession(t, store, 0)
+	mr.Del(fmt.Sprintf(KeyGroupSession, expired.SessionID))
+
+	tests := []struct {
+		name       string
+		sessionID  string
+		accountID  int64
+		maxMembers int
+		joined     bool
+		err        error
+	}{
+		{"new member", session.SessionID, 2, 3, true, nil},
+		{"member again", session.SessionID, 2, 3, false, nil},
+		{"owner", session.SessionID, 1, 3, false, nil},
+		{"full", session.SessionID, 3, 2, false, apperror.GroupSessionFull},
+		{"expired", expired.SessionID, 2, 3, false, apperror.GroupSessionNotExists},
+		{"unknown", "unknown", 2, 3, false, apperror.GroupSessionNotExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joined, err := store.AddMember(tt.sessionID, tt.accountID, tt.maxMembers)
+			if !errors.Is(err, tt.err) || joined != tt.joined {
+				t.Errorf("AddMember() = %t, %v, want %t, %v", joined, err, tt.joined, tt.err)
+			}
+		})
+	}
+}
+
+func TestSaveSwipe(t *testing.T) {
+	store, _ := newTestStore(t)
+	session := createTestSession(t, store, 2)
+	if _, err := store.AddMember(session.SessionID, 2, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		accountID int64
+		venueType string
+		venueID   int64
+		direction string
+		match     bool
+		likes     int
+		err       error
+	}{
+		{"stranger", 3, model.VenueTypeRestaurant, 10, model.SwipeLike, false, 0, apperror.NotGroupMember},
+		{"not in deck", 1, model.VenueTypeRestaurant, 99, model.SwipeLike, false, 0, apperror.VenueNotInDeck},
+		{"first like", 1, model.VenueTypeRestaurant, 10, model.SwipeLike, false, 1, nil},
+		{"changed to dislike", 1, model.VenueTypeRestaurant, 10, model.SwipeDislike, false, 0, nil},
+		{"liked again", 1, model.VenueTypeRestaurant, 10, model.SwipeSuperlike, false, 1, nil},
+		{"quorum reached", 2, model.VenueTypeRestaurant, 10, model.SwipeLike, true, 2, nil},
+		{"matched once", 2, model.VenueTypeRestaurant, 10, model.SwipeSuperlike, false, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.SaveSwipe(session.SessionID, tt.accountID, tt.venueType, tt.venueID, tt.direction)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SaveSwipe() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if result.Match != tt.match || result.Likes != tt.likes || result.Quorum != 2 {
+				t.Errorf("SaveSwipe() = %+v, want match %t with %d of 2 likes", result, tt.match, tt.likes)
+			}
+		})
+	}
+
+	found, err := store.FindSession(session.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Matches) != 1 || found.Matches[0].VenueID() != 10 {
+		t.Errorf("FindSession() matches = %v, want venue 10", found.Matches)
+	}
+}
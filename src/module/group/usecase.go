@@ -0,0 +1,240 @@
+package group
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/deck"
+)
+
+type Usecase interface {
+	CreateSession(ownerID int64, quorum int, lat, long float64) (*model.GroupSession, error)
+	JoinSession(accountID int64, inviteCode string) (*model.GroupSession, error)
+	GetSession(accountID int64, sessionID string) (*model.GroupSession, error)
+	GetSessionDeck(accountID int64, sessionID string, size int) (model.DeckCards, error)
+	Swipe(accountID int64, sessionID, venueType string, venueID int64, direction string) (*model.GroupSwipeResult, error)
+	SubscribeEvents(accountID int64, sessionID string, lastEventID int64) (<-chan *model.GroupEvent, func(), error)
+}
+
+type usecase struct {
+	groupStore  GroupStore
+	eventBus    EventBus
+	deckUsecase deck.Usecase
+	maxMembers  int
+}
+
+func NewGroupUsecase(
+	groupStore GroupStore,
+	eventBus EventBus,
+	deckUsecase deck.Usecase,
+	maxMembers int,
+) Usecase {
+	return &usecase{
+		groupStore:  groupStore,
+		eventBus:    eventBus,
+		deckUsecase: deckUsecase,
+		maxMembers:  maxMembers,
+	}
+}
+
+// CreateSession starts a session around the given point with its owner as
+// the only member, and deals it a shared deck. A quorum above the member
+// limit could never be reached, so it is refused.
+func (u *usecase) CreateSession(ownerID int64, quorum int, lat, long float64) (*model.GroupSession, error) {
+	if quorum > u.maxMembers {
+		return nil, apperror.ValidationError.WithDetails(apperror.FieldError{
+			Field:   "quorum",
+			Message: fmt.Sprintf("must be at most %d, the member limit", u.maxMembers),
+		})
+	}
+
+	cards, err := u.deckUsecase.BuildSharedDeck(lat, long)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	session := model.NewGroupSession(ownerID, quorum, lat, long)
+	if err := u.groupStore.CreateSession(session, cards); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// JoinSession adds the account to the session the invite code belongs to.
+// Joining again is a no-op.
+func (u *usecase) JoinSession(accountID int64, inviteCode string) (*model.GroupSession, error) {
+	sessionID, err := u.groupStore.FindSessionIDByInvite(inviteCode)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	joined, err := u.groupStore.AddMember(sessionID, accountID, u.maxMembers)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	session, err := u.groupStore.FindSession(sessionID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if joined {
+		u.publish(sessionID, model.GroupEventMemberJoined, &model.GroupMemberJoined{
+			AccountID: accountID,
+			Members:   len(session.Members),
+		})
+	}
+
+	return session, nil
+}
+
+func (u *usecase) GetSession(accountID int64, sessionID string) (*model.GroupSession, error) {
+	if err := u.checkMember(sessionID, accountID); err != nil {
+		return nil, err
+	}
+
+	session, err := u.groupStore.FindSession(sessionID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSessionDeck returns the member's next size cards of the shared deck.
+func (u *usecase) GetSessionDeck(accountID int64, sessionID string, size int) (model.DeckCards, error) {
+	if err := u.checkMember(sessionID, accountID); err != nil {
+		return nil, err
+	}
+
+	cards, err := u.groupStore.FindDeck(sessionID, accountID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if len(cards) > size {
+		cards = cards[:size]
+	}
+
+	return cards, nil
+}
+
+// Swipe records the member's decision on a venue of the shared deck and
+// reports whether that made it a match.
+func (u *usecase) Swipe(accountID int64, sessionID, venueType string, venueID int64, direction string) (*model.GroupSwipeResult, error) {
+	result, err := u.groupStore.SaveSwipe(sessionID, accountID, venueType, venueID, direction)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	u.publish(sessionID, model.GroupEventSwipe, &model.GroupSwipeProgress{
+		AccountID: accountID,
+		VenueType: venueType,
+		VenueID:   venueID,
+		Likes:     result.Likes,
+		Quorum:    result.Quorum,
+	})
+
+	if result.Match {
+		u.publish(sessionID, model.GroupEventMatch, result.Card)
+	}
+
+	return result, nil
+}
+
+// SubscribeEvents streams the session's events to a member, starting with
+// the ones after lastEventID that are still in the log. The stream ends
+// when cancel is called or the subscription breaks; the member then
+// resumes with the last event ID it got.
+func (u *usecase) SubscribeEvents(accountID int64, sessionID string, lastEventID int64) (<-chan *model.GroupEvent, func(), error) {
+	if err := u.checkMember(sessionID, accountID); err != nil {
+		return nil, nil, err
+	}
+
+	// Subscribe before reading the log so nothing falls in between; the
+	// overlap is skipped by ID below.
+	live, unsubscribe, err := u.eventBus.Subscribe(sessionID)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, err
+	}
+
+	backlog, err := u.eventBus.FindEventsAfter(sessionID, lastEventID)
+	if err != nil {
+		log.Println(err)
+		unsubscribe()
+		return nil, nil, err
+	}
+
+	events := make(chan *model.GroupEvent)
+	done := make(chan struct{})
+	go func() {
+		defer close(events)
+
+		for _, event := range backlog {
+			select {
+			case events <- event:
+				lastEventID = event.ID
+			case <-done:
+				return
+			}
+		}
+
+		for event := range live {
+			if event.ID <= lastEventID {
+				continue
+			}
+
+			select {
+			case events <- event:
+				lastEventID = event.ID
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+
+	return events, cancel, nil
+}
+
+// publish tells the session's members about a change that is already
+// saved, so a failure is only logged.
+func (u *usecase) publish(sessionID, eventType string, data interface{}) {
+	if err := u.eventBus.Publish(sessionID, eventType, data); err != nil {
+		log.Println(err)
+	}
+}
+
+func (u *usecase) checkMember(sessionID string, accountID int64) error {
+	member, err := u.groupStore.IsMember(sessionID, accountID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if !member {
+		return apperror.NotGroupMember
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/model"
+)
+
+// A quorum above the member limit could never be reached, so the session
+// would take swipes without ever matching.
+func TestCreateSessionRefusesUnreachableQuorum(t *testing.T) {
+	u := NewGroupUsecase(nil, nil, nil, 5)
+
+	if _, err := u.CreateSession(1, 6, -6.9, 107.6); !errors.Is(err, apperror.ValidationError) {
+		t.Errorf("CreateSession() error = %v, want ValidationError", err)
+	}
+}
+
+type fakeGroupStore struct {
+	GroupStore
+
+	members map[int64]bool
+}
+
+func (store *fakeGroupStore) IsMember(sessionID string, accountID int64) (bool, error) {
+	return store.members[accountID], nil
+}
+
+// fakeEventBus serves a fixed backlog and hands the test the live channel.
+type fakeEventBus struct {
+	EventBus
+
+	backlog      []*model.GroupEvent
+	live         chan *model.GroupEvent
+	afterID      int64
+	unsubscribed int
+}
+
+// Subscribe's unsubscribe closes live, as the real bus does.
+func (bus *fakeEventBus) Subscribe(sessionID string) (<-chan *model.GroupEvent, func(), error) {
+	return bus.live, func() {
+		bus.unsubscribed++
+		close(bus.live)
+	}, nil
+}
+
+func (bus *fakeEventBus) FindEventsAfter(sessionID string, lastEventID int64) ([]*model.GroupEvent, error) {
+	bus.afterID = lastEventID
+	return bus.backlog, nil
+}
+
+func groupEvents(ids ...int64) []*model.GroupEvent {
+	events := make([]*model.GroupEvent, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, &model.GroupEvent{ID: id})
+	}
+	return events
+}
+
+// Events published between Subscribe and reading the log arrive both ways;
+// each must reach the member once, in order.
+func TestSubscribeEventsSkipsDuplicates(t *testing.T) {
+	bus := &fakeEventBus{backlog: groupEvents(3, 4), live: make(chan *model.GroupEvent, 10)}
+	u := NewGroupUsecase(&fakeGroupStore{members: map[int64]bool{1: true}}, bus, nil, 5)
+
+	for _, event := range groupEvents(4, 5, 3, 6) {
+		bus.live <- event
+	}
+
+	events, cancel, err := u.SubscribeEvents(1, "s1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bus.afterID != 2 {
+		t.Errorf("FindEventsAfter() got last event %d, want 2", bus.afterID)
+	}
+
+	var got []int64
+	for len(got) < 4 {
+		select {
+		case event := <-events:
+			got = append(got, event.ID)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("received events %v, then nothing", got)
+		}
+	}
+	cancel()
+
+	if _, open := <-events; open || fmt.Sprint(got) != "[3 4 5 6]" {
+		t.Errorf("received events %v, want [3 4 5 6]", got)
+	}
+}
+
+func TestSubscribeEventsCancel(t *testing.T) {
+	bus := &fakeEventBus{live: make(chan *model.GroupEvent)}
+	u := NewGroupUsecase(&fakeGroupStore{members: map[int64]bool{1: true}}, bus, nil, 5)
+
+	events, cancel, err := u.SubscribeEvents(1, "s1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	cancel()
+	if bus.unsubscribed != 1 {
+		t.Errorf("unsubscribed %d times, want 1", bus.unsubscribed)
+	}
+
+	select {
+	case _, open := <-events:
+		if open {
+			t.Error("event received after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("stream still open after cancel")
+	}
+}
+
+func TestSubscribeEventsRequiresMembership(t *testing.T) {
+	bus := &fakeEventBus{live: make(chan *model.GroupEvent)}
+	u := NewGroupUsecase(&fakeGroupStore{members: map[int64]bool{1: true}}, bus, nil, 5)
+
+	if _, _, err := u.SubscribeEvents(2, "s1", 0); !errors.Is(err, apperror.NotGroupMember) {
+		t.Errorf("SubscribeEvents() error = %v, want NotGroupMember", err)
+	}
+}
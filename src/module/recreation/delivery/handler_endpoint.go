@@ -5,6 +5,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/recreation"
 	"github.com/atletaid/go-template/util/httputil"
@@ -15,27 +18,39 @@ type RecreationHandler struct {
 	ru recreation.Usecase
 }
 
-func NewRecreationHandler(router *gin.Engine, ru recreation.Usecase) *gin.Engine {
+func NewRecreationHandler(router *gin.Engine, m *auth.Middleware, ru recreation.Usecase) *gin.Engine {
 	handler := &RecreationHandler{ru}
 
 	v1 := router.Group("/api")
-	v1.POST("/recreation", handler.CreateRecreationEndpoint())
 	v1.GET("/recreation/:recreation_id", handler.GetRecreationEndpoint())
 	v1.GET("/recreations", handler.GetAllRecreationsEndpoint())
+	v1.GET("/recreations/nearby", handler.GetNearbyRecreationsEndpoint())
 	v1.POST("/recreation/city", handler.GetRecreationsByCityEndpoint())
-	v1.DELETE("/recreation/:recreation_id", handler.DeleteRecreationEndpoint())
+
+	v1.Use(m.AuthUserToken(), m.RequireVerified(), m.RequireRole(model.RoleCurator, model.RoleAdmin))
+	{
+		v1.POST("/recreation", handler.CreateRecreationEndpoint())
+		v1.PUT("/recreation/:recreation_id", handler.UpdateRecreationEndpoint())
+		v1.PATCH("/recreation/:recreation_id", handler.PatchRecreationEndpoint())
+		v1.DELETE("/recreation/:recreation_id", handler.DeleteRecreationEndpoint())
+	}
+
+	admin := v1.Group("", m.RequireRole(model.RoleAdmin))
+	{
+		admin.POST("/recreations/:recreation_id/restore", handler.RestoreRecreationEndpoint())
+	}
 
 	return router
 }
 
 type createRecreationRequest struct {
-	RecreationName        string  `json:"recreation_name" form:"recreation_name"`
-	RecreationTimeMinute  int     `json:"recreation_time_minute" form:"recreation_time_minute"`
-	RecreationPrice       int     `json:"recreation_price" form:"recreation_price"`
-	PositionLat           float64 `json:"position_lat" form:"position_lat"`
-	PositionLong          float64 `json:"position_long" form:"position_long"`
-	RecreationCity        string  `json:"recreation_city" form:"recreation_city"`
-	RecreationImage       string  `json:"recreation_image" form:"recreation_image"`
+	RecreationName        string  `json:"recreation_name" form:"recreation_name" binding:"required,max=255"`
+	RecreationTimeMinute  int     `json:"recreation_time_minute" form:"recreation_time_minute" binding:"gte=0"`
+	RecreationPrice       int     `json:"recreation_price" form:"recreation_price" binding:"gte=0"`
+	PositionLat           float64 `json:"position_lat" form:"position_lat" binding:"gte=-90,lte=90"`
+	PositionLong          float64 `json:"position_long" form:"position_long" binding:"gte=-180,lte=180"`
+	RecreationCity        string  `json:"recreation_city" form:"recreation_city" binding:"required,max=255"`
+	RecreationImage       string  `json:"recreation_image" form:"recreation_image" binding:"omitempty,url"`
 	RecreationDescription string  `json:"recreation_description" form:"recreation_description"`
 }
 
@@ -55,6 +70,13 @@ func (h *RecreationHandler) CreateRecreationEndpoint() gin.HandlerFunc {
 			return
 		}
 
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
 		recreationID, err := h.ru.CreateRecreation(req.RecreationName, req.RecreationCity, req.RecreationImage, req.RecreationDescription, req.RecreationTimeMinute, req.RecreationPrice, req.PositionLat, req.PositionLong)
 		if err != nil {
 			log.Println(err)
@@ -84,7 +106,7 @@ func (h *RecreationHandler) GetRecreationEndpoint() gin.HandlerFunc {
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
-			httputil.WriteErrorResponse(c, processTime, err)
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
 			return
 		}
 
@@ -105,15 +127,26 @@ func (h *RecreationHandler) GetRecreationEndpoint() gin.HandlerFunc {
 	}
 }
 
-type dataRecreationsResponse struct {
-	Recreations model.Recreations `json:"recreations"`
+func decodeListQuery(c *gin.Context) (*listing.Query, error) {
+	req := listing.Request{}
+	if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+		return nil, apperror.DecodeError.Wrap(err)
+	}
+
+	if err := httputil.ValidateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	return req.Query(recreation.ListSorts, recreation.ListFilters...)
 }
 
+// GetAllRecreationsEndpoint lists recreations one page at a time. See
+// listing.Request for the query parameters.
 func (h *RecreationHandler) GetAllRecreationsEndpoint() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 
-		recreations, err := h.ru.GetAllRecrations()
+		query, err := decodeListQuery(c)
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
@@ -121,19 +154,25 @@ func (h *RecreationHandler) GetAllRecreationsEndpoint() gin.HandlerFunc {
 			return
 		}
 
-		resp := dataRecreationsResponse{
-			Recreations: recreations,
+		page, err := h.ru.GetAllRecrations(query)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
 		}
 
 		processTime := time.Now().Sub(startTime).Seconds()
-		httputil.WriteResponse(c, []string{"Success get all recreations"}, processTime, resp)
+		httputil.WriteResponse(c, []string{"Success get all recreations"}, processTime, page)
 	}
 }
 
 type getRecreationByCityRequest struct {
-	City string `json:"recreation_city" form:"recreation_city"`
+	City string `json:"recreation_city" form:"recreation_city" binding:"required"`
 }
 
+// GetRecreationsByCityEndpoint takes the city in the body and the list query
+// parameters in the URL, like GetAllRecreationsEndpoint.
 func (h *RecreationHandler) GetRecreationsByCityEndpoint() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
@@ -146,7 +185,22 @@ func (h *RecreationHandler) GetRecreationsByCityEndpoint() gin.HandlerFunc {
 			return
 		}
 
-		recreations, err := h.ru.GetRecreationsByCity(req.City)
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		query, err := decodeListQuery(c)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		page, err := h.ru.GetRecreationsByCity(req.City, query)
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
@@ -155,7 +209,64 @@ func (h *RecreationHandler) GetRecreationsByCityEndpoint() gin.HandlerFunc {
 		}
 
 		processTime := time.Now().Sub(startTime).Seconds()
-		httputil.WriteResponse(c, []string{"Success get recreations by city"}, processTime, recreations)
+		httputil.WriteResponse(c, []string{"Success get recreations by city"}, processTime, page)
+	}
+}
+
+type dataNearbyRecreationsResponse struct {
+	Recreations model.NearbyRecreations `json:"recreations"`
+}
+
+type getNearbyRecreationsRequest struct {
+	Lat      *float64 `json:"lat" form:"lat" binding:"required,gte=-85,lte=85"`
+	Long     *float64 `json:"long" form:"long" binding:"required,gte=-180,lte=180"`
+	RadiusKM float64  `json:"radius_km" form:"radius_km" binding:"omitempty,gt=0,lte=100"`
+	Limit    int      `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// GetNearbyRecreationsEndpoint lists the recreations within radius_km (default 5)
+// of lat and long, nearest first, each with its distance_km.
+func (h *RecreationHandler) GetNearbyRecreationsEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := getNearbyRecreationsRequest{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if req.RadiusKM == 0 {
+			req.RadiusKM = 5
+		}
+
+		if req.Limit == 0 {
+			req.Limit = listing.DefaultLimit
+		}
+
+		recreations, err := h.ru.GetNearbyRecreations(*req.Lat, *req.Long, req.RadiusKM, req.Limit)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		resp := dataNearbyRecreationsResponse{
+			Recreations: recreations,
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get nearby recreations"}, processTime, resp)
 	}
 }
 
@@ -167,7 +278,7 @@ func (h *RecreationHandler) DeleteRecreationEndpoint() gin.HandlerFunc {
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
-			httputil.WriteErrorResponse(c, processTime, err)
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
 			return
 		}
 
@@ -183,3 +294,115 @@ func (h *RecreationHandler) DeleteRecreationEndpoint() gin.HandlerFunc {
 		httputil.WriteResponse(c, []string{"Success delete recreation"}, processTime, nil)
 	}
 }
+
+// UpdateRecreationEndpoint replaces the recreation; it takes the same body as create.
+func (h *RecreationHandler) UpdateRecreationEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		recreationID, err := strconv.ParseInt(c.Param("recreation_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		req := createRecreationRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		h.updateRecreation(c, startTime, recreationID, &req)
+	}
+}
+
+// PatchRecreationEndpoint applies a JSON merge patch to the recreation. The patched
+// recreation must pass the same validation as a created one.
+func (h *RecreationHandler) PatchRecreationEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		recreationID, err := strconv.ParseInt(c.Param("recreation_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		recreation, err := h.ru.GetRecreation(recreationID)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		req := createRecreationRequest{
+			RecreationName:        recreation.RecreationName,
+			RecreationTimeMinute:  recreation.RecreationTimeMinute,
+			RecreationPrice:       recreation.RecreationPrice,
+			PositionLat:           recreation.PositionLat,
+			PositionLong:          recreation.PositionLong,
+			RecreationCity:        recreation.RecreationCity,
+			RecreationImage:       recreation.RecreationImage,
+			RecreationDescription: recreation.RecreationDescription,
+		}
+		if err := httputil.DecodeMergePatch(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		h.updateRecreation(c, startTime, recreationID, &req)
+	}
+}
+
+func (h *RecreationHandler) updateRecreation(c *gin.Context, startTime time.Time, recreationID int64, req *createRecreationRequest) {
+	if err := httputil.ValidateRequest(req); err != nil {
+		log.Println(err)
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteErrorResponse(c, processTime, err)
+		return
+	}
+
+	err := h.ru.UpdateRecreation(recreationID, req.RecreationName, req.RecreationCity, req.RecreationImage, req.RecreationDescription, req.RecreationTimeMinute, req.RecreationPrice, req.PositionLat, req.PositionLong)
+	if err != nil {
+		log.Println(err)
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteErrorResponse(c, processTime, err)
+		return
+	}
+
+	processTime := time.Now().Sub(startTime).Seconds()
+	httputil.WriteResponse(c, []string{"Success update recreation"}, processTime, nil)
+}
+
+func (h *RecreationHandler) RestoreRecreationEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		recreationID, err := strconv.ParseInt(c.Param("recreation_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		if err := h.ru.RestoreRecreationByID(recreationID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success restore recreation"}, processTime, nil)
+	}
+}
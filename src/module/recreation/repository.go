@@ -1,13 +1,28 @@
 package recreation
 
 import (
+	"time"
+
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 )
 
+// ListSorts and ListFilters are what recreation list queries may sort and
+// filter on. The first sort is the default.
+var (
+	ListSorts   = []string{"id", "created_at", "name", "price", "time"}
+	ListFilters = []string{listing.FilterCity, listing.FilterPrice, listing.FilterTime}
+)
+
 type RecreationRepository interface {
 	CreateRecreation(recreation *model.Recreation) (int64, error)
 	FindRecreationByID(recreationID int64) (*model.Recreation, error)
-	FindAllRecreations() (model.Recreations, error)
-	FindByLocation(cityName string) (model.Recreations, error)
+	FindRecreationsByIDs(recreationIDs []int64) (model.Recreations, error)
+	FindAllRecreations(query *listing.Query) (*model.RecreationPage, error)
+	FindByLocation(cityName string, query *listing.Query) (*model.RecreationPage, error)
+	FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRecreations, error)
+	UpdateRecreation(recreation *model.Recreation) error
 	DeleteRecreation(recreationID int64) error
+	RestoreRecreation(recreationID int64) error
+	PurgeRecreations(deletedBefore time.Time) (int64, error)
 }
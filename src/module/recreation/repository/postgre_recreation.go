@@ -4,26 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/recreation"
 	"github.com/lib/pq"
-	"github.com/tokopedia/sqlt"
 )
 
 type postgreRecreationRepo struct {
-	DbMaster *sqlt.DB
-	DbSlave  *sqlt.DB
-	Timeout  time.Duration
+	DB      *database.DB
+	Timeout time.Duration
 }
 
-func NewRecreationRepository(dbMaster *sqlt.DB, dbSlave *sqlt.DB, timeout time.Duration) recreation.RecreationRepository {
+func NewRecreationRepository(db *database.DB, timeout time.Duration) recreation.RecreationRepository {
 	return &postgreRecreationRepo{
-		DbMaster: dbMaster,
-		DbSlave:  dbSlave,
-		Timeout:  timeout,
+		DB:      db,
+		Timeout: timeout,
 	}
 }
 
@@ -43,7 +44,8 @@ func (repo *postgreRecreationRepo) CreateRecreation(recreation *model.Recreation
 			recreation_city,
 			recreation_image,
 			recreation_description,
-			created_at
+			created_at,
+			updated_at
 		)
 		VALUES
 		(
@@ -55,6 +57,7 @@ func (repo *postgreRecreationRepo) CreateRecreation(recreation *model.Recreation
 			$6,
 			$7,
 			$8,
+			now(),
 			now()
 		)
 		RETURNING
@@ -63,7 +66,7 @@ func (repo *postgreRecreationRepo) CreateRecreation(recreation *model.Recreation
 
 	var lastInsertID int64
 
-	err := repo.DbMaster.QueryRowContext(
+	err := repo.DB.Writer(database.ScopeRecreations).QueryRowContext(
 		ctx,
 		query,
 		recreation.RecreationName,
@@ -77,7 +80,7 @@ func (repo *postgreRecreationRepo) CreateRecreation(recreation *model.Recreation
 	).Scan(&lastInsertID)
 	if err != nil {
 		log.Println(err)
-		return 0, apperror.InternalServerError
+		return 0, apperror.InternalServerError.Wrap(err)
 	}
 
 	return lastInsertID, nil
@@ -98,11 +101,13 @@ func (repo *postgreRecreationRepo) FindRecreationByID(recreationID int64) (*mode
 			recreation_city,
 			recreation_image,
 			recreation_description,
-			created_at
+			created_at,
+			updated_at
 		FROM
 			ms_recreation
 		WHERE
-			recreation_id = $1
+			recreation_id = $1 AND
+			deleted_at IS NULL
 	`
 
 	var (
@@ -116,9 +121,10 @@ func (repo *postgreRecreationRepo) FindRecreationByID(recreationID int64) (*mode
 		rRecreationImage       sql.NullString
 		rRecreationDescription sql.NullString
 		rCreatedAt             pq.NullTime
+		rUpdatedAt             pq.NullTime
 	)
 
-	err := repo.DbSlave.QueryRowContext(ctx, query, recreationID).Scan(
+	err := repo.DB.Reader(database.ScopeRecreations).QueryRowContext(ctx, query, recreationID).Scan(
 		&rRecreationID,
 		&rRecreationName,
 		&rRecreationTimeMinute,
@@ -129,6 +135,7 @@ func (repo *postgreRecreationRepo) FindRecreationByID(recreationID int64) (*mode
 		&rRecreationImage,
 		&rRecreationDescription,
 		&rCreatedAt,
+		&rUpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -138,7 +145,7 @@ func (repo *postgreRecreationRepo) FindRecreationByID(recreationID int64) (*mode
 
 	if err != nil {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
 
 	recreation := model.Recreation{
@@ -152,15 +159,140 @@ func (repo *postgreRecreationRepo) FindRecreationByID(recreationID int64) (*mode
 		RecreationCity:        rRecreationCity.String,
 		RecreationDescription: rRecreationDescription.String,
 		CreatedAt:             rCreatedAt.Time,
+		UpdatedAt:             rUpdatedAt.Time,
 	}
 
 	return &recreation, nil
 }
 
-func (repo *postgreRecreationRepo) FindAllRecreations() (model.Recreations, error) {
+// FindRecreationsByIDs loads the recreations with the given IDs in one
+// query, in no particular order. Deleted and unknown IDs are left out.
+func (repo *postgreRecreationRepo) FindRecreationsByIDs(recreationIDs []int64) (model.Recreations, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
+	query := `
+		SELECT
+			recreation_id,
+			recreation_name,
+			recreation_time_minute,
+			recreation_price,
+			position_lat,
+			position_long,
+			recreation_city,
+			recreation_image,
+			recreation_description,
+			created_at,
+			updated_at
+		FROM
+			ms_recreation
+		WHERE
+			recreation_id = ANY($1) AND
+			deleted_at IS NULL
+	`
+
+	rows, err := repo.DB.Reader(database.ScopeRecreations).QueryContext(ctx, query, pq.Array(recreationIDs))
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	recreations := make(model.Recreations, 0, len(recreationIDs))
+	for rows.Next() {
+		var (
+			rRecreationID          sql.NullInt64
+			rRecreationName        sql.NullString
+			rRecreationTimeMinute  sql.NullInt64
+			rRecreationPrice       sql.NullInt64
+			rPositionLat           sql.NullFloat64
+			rPositionLong          sql.NullFloat64
+			rRecreationCity        sql.NullString
+			rRecreationImage       sql.NullString
+			rRecreationDescription sql.NullString
+			rCreatedAt             pq.NullTime
+			rUpdatedAt             pq.NullTime
+		)
+
+		if err := rows.Scan(
+			&rRecreationID,
+			&rRecreationName,
+			&rRecreationTimeMinute,
+			&rRecreationPrice,
+			&rPositionLat,
+			&rPositionLong,
+			&rRecreationCity,
+			&rRecreationImage,
+			&rRecreationDescription,
+			&rCreatedAt,
+			&rUpdatedAt,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		recreation := model.Recreation{
+			RecreationID:          rRecreationID.Int64,
+			RecreationName:        rRecreationName.String,
+			RecreationTimeMinute:  int(rRecreationTimeMinute.Int64),
+			RecreationPrice:       int(rRecreationPrice.Int64),
+			PositionLat:           rPositionLat.Float64,
+			PositionLong:          rPositionLong.Float64,
+			RecreationCity:        rRecreationCity.String,
+			RecreationImage:       rRecreationImage.String,
+			RecreationDescription: rRecreationDescription.String,
+			CreatedAt:             rCreatedAt.Time,
+			UpdatedAt:             rUpdatedAt.Time,
+		}
+
+		recreations = append(recreations, &recreation)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return recreations, nil
+}
+
+var recreationColumns = listing.Columns{
+	ID:         "recreation_id",
+	City:       "recreation_city",
+	Price:      "recreation_price",
+	TimeMinute: "recreation_time_minute",
+	Sorts: map[string]string{
+		"id":         "recreation_id",
+		"created_at": "created_at",
+		"name":       "recreation_name",
+		"price":      "recreation_price",
+		"time":       "recreation_time_minute",
+	},
+}
+
+func recreationSortValue(recreation *model.Recreation, sort string) string {
+	switch sort {
+	case "created_at":
+		return listing.TimeValue(recreation.CreatedAt)
+	case "name":
+		return recreation.RecreationName
+	case "price":
+		return strconv.Itoa(recreation.RecreationPrice)
+	case "time":
+		return strconv.Itoa(recreation.RecreationTimeMinute)
+	}
+	return strconv.FormatInt(recreation.RecreationID, 10)
+}
+
+func (repo *postgreRecreationRepo) FindAllRecreations(query *listing.Query) (*model.RecreationPage, error) {
+	return repo.findRecreationPage(query)
+}
+
+func (repo *postgreRecreationRepo) findRecreationPage(listQuery *listing.Query) (*model.RecreationPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	clauses, args := listQuery.SQL(recreationColumns, []string{"deleted_at IS NULL"}, nil)
 	query := `
 	SELECT
 		recreation_id,
@@ -172,16 +304,18 @@ func (repo *postgreRecreationRepo) FindAllRecreations() (model.Recreations, erro
 		recreation_city,
 		recreation_image,
 		recreation_description,
-		created_at
+		created_at,
+		updated_at
 	FROM
-		ms_recreation	
-	`
+		ms_recreation
+	` + clauses
 
-	rows, err := repo.DbSlave.QueryContext(ctx, query)
+	rows, err := repo.DB.Reader(database.ScopeRecreations).QueryContext(ctx, query, args...)
 	if err != nil && err != sql.ErrNoRows {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
+	defer rows.Close()
 
 	recreations := make(model.Recreations, 0)
 	for rows.Next() {
@@ -196,6 +330,7 @@ func (repo *postgreRecreationRepo) FindAllRecreations() (model.Recreations, erro
 			rRecreationImage       sql.NullString
 			rRecreationDescription sql.NullString
 			rCreatedAt             pq.NullTime
+			rUpdatedAt             pq.NullTime
 		)
 
 		if err := rows.Scan(
@@ -209,9 +344,10 @@ func (repo *postgreRecreationRepo) FindAllRecreations() (model.Recreations, erro
 			&rRecreationImage,
 			&rRecreationDescription,
 			&rCreatedAt,
+			&rUpdatedAt,
 		); err != nil {
 			log.Println(err)
-			return nil, apperror.InternalServerError
+			return nil, apperror.InternalServerError.Wrap(err)
 		}
 
 		recreation := model.Recreation{
@@ -225,35 +361,67 @@ func (repo *postgreRecreationRepo) FindAllRecreations() (model.Recreations, erro
 			RecreationImage:       rRecreationImage.String,
 			RecreationDescription: rRecreationDescription.String,
 			CreatedAt:             rCreatedAt.Time,
+			UpdatedAt:             rUpdatedAt.Time,
 		}
 
 		recreations = append(recreations, &recreation)
 	}
 
-	return recreations, nil
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	page := &model.RecreationPage{Recreations: recreations}
+	if len(recreations) > listQuery.Limit {
+		page.Recreations = recreations[:listQuery.Limit]
+		last := page.Recreations[listQuery.Limit-1]
+		page.NextCursor = listQuery.NextCursor(recreationSortValue(last, listQuery.Sort), last.RecreationID)
+	}
+
+	return page, nil
 }
 
+// DeleteRecreation soft-deletes the recreation. Find* skip it from then on,
+// and the purge job removes it once the retention period has passed.
 func (repo *postgreRecreationRepo) DeleteRecreation(recreationID int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
 	query := `
-		DELETE FROM
+		UPDATE
 			ms_recreation
+		SET
+			deleted_at = now(),
+			updated_at = now()
 		WHERE
-			recreation_id = $1
+			recreation_id = $1 AND
+			deleted_at IS NULL
 	`
 
-	_, err := repo.DbMaster.ExecContext(ctx, query, recreationID)
+	_, err := repo.DB.Writer(database.ScopeRecreations).ExecContext(ctx, query, recreationID)
 	if err != nil {
 		log.Println(err)
-		return apperror.InternalServerError
+		return apperror.InternalServerError.Wrap(err)
 	}
 
 	return nil
 }
 
-func (repo *postgreRecreationRepo) FindByLocation(cityName string) (model.Recreations, error) {
+// FindByLocation lists the recreations in cityName, overriding any city
+// filter in query.
+func (repo *postgreRecreationRepo) FindByLocation(cityName string, query *listing.Query) (*model.RecreationPage, error) {
+	byCity := *query
+	byCity.City = cityName
+	return repo.findRecreationPage(&byCity)
+}
+
+// FindNearby lists the recreations within radiusKM of the given point,
+// nearest first, measuring great-circle distance. The bounding box lets the
+// position index narrow the scan before distances are computed. This is the
+// authoritative search; the Redis geo index in front of it is only a
+// faster path and falls back here whenever it isn't ready.
+func (repo *postgreRecreationRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRecreations, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
@@ -268,20 +436,41 @@ func (repo *postgreRecreationRepo) FindByLocation(cityName string) (model.Recrea
 		recreation_city,
 		recreation_image,
 		recreation_description,
-		created_at
-	FROM
-		ms_recreation	
+		created_at,
+		updated_at,
+		distance_km
+	FROM (
+		SELECT
+			*,
+			6371 * 2 * asin(sqrt(
+				power(sin(radians(position_lat - $1) / 2), 2) +
+				cos(radians($1)) * cos(radians(position_lat)) *
+				power(sin(radians(position_long - $2) / 2), 2)
+			)) AS distance_km
+		FROM
+			ms_recreation
+		WHERE
+			deleted_at IS NULL AND
+			position_lat BETWEEN $1 - $5 AND $1 + $5 AND
+			position_long BETWEEN $2 - $6 AND $2 + $6
+	) nearby
 	WHERE
-		recreation_city = $1
+		distance_km <= $3
+	ORDER BY
+		distance_km,
+		recreation_id
+	LIMIT $4
 	`
 
-	rows, err := repo.DbSlave.QueryContext(ctx, query, cityName)
+	latDelta, longDelta := geo.BoundingBox(lat, radiusKM)
+	rows, err := repo.DB.Reader(database.ScopeRecreations).QueryContext(ctx, query, lat, long, radiusKM, limit, latDelta, longDelta)
 	if err != nil && err != sql.ErrNoRows {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
+	defer rows.Close()
 
-	recreations := make(model.Recreations, 0)
+	recreations := make(model.NearbyRecreations, 0)
 	for rows.Next() {
 		var (
 			rRecreationID          sql.NullInt64
@@ -294,6 +483,8 @@ func (repo *postgreRecreationRepo) FindByLocation(cityName string) (model.Recrea
 			rRecreationImage       sql.NullString
 			rRecreationDescription sql.NullString
 			rCreatedAt             pq.NullTime
+			rUpdatedAt             pq.NullTime
+			rDistanceKM            sql.NullFloat64
 		)
 
 		if err := rows.Scan(
@@ -307,26 +498,161 @@ func (repo *postgreRecreationRepo) FindByLocation(cityName string) (model.Recrea
 			&rRecreationImage,
 			&rRecreationDescription,
 			&rCreatedAt,
+			&rUpdatedAt,
+			&rDistanceKM,
 		); err != nil {
 			log.Println(err)
-			return nil, apperror.InternalServerError
+			return nil, apperror.InternalServerError.Wrap(err)
 		}
 
-		recreation := model.Recreation{
-			RecreationID:          rRecreationID.Int64,
-			RecreationName:        rRecreationName.String,
-			RecreationTimeMinute:  int(rRecreationTimeMinute.Int64),
-			RecreationPrice:       int(rRecreationPrice.Int64),
-			PositionLat:           rPositionLat.Float64,
-			PositionLong:          rPositionLong.Float64,
-			RecreationCity:        rRecreationCity.String,
-			RecreationImage:       rRecreationImage.String,
-			RecreationDescription: rRecreationDescription.String,
-			CreatedAt:             rCreatedAt.Time,
+		recreation := model.NearbyRecreation{
+			Recreation: model.Recreation{
+				RecreationID:          rRecreationID.Int64,
+				RecreationName:        rRecreationName.String,
+				RecreationTimeMinute:  int(rRecreationTimeMinute.Int64),
+				RecreationPrice:       int(rRecreationPrice.Int64),
+				PositionLat:           rPositionLat.Float64,
+				PositionLong:          rPositionLong.Float64,
+				RecreationCity:        rRecreationCity.String,
+				RecreationImage:       rRecreationImage.String,
+				RecreationDescription: rRecreationDescription.String,
+				CreatedAt:             rCreatedAt.Time,
+				UpdatedAt:             rUpdatedAt.Time,
+			},
+			DistanceKM: rDistanceKM.Float64,
 		}
 
 		recreations = append(recreations, &recreation)
 	}
 
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
 	return recreations, nil
 }
+
+func (repo *postgreRecreationRepo) UpdateRecreation(recreation *model.Recreation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			ms_recreation
+		SET
+			recreation_name = $2,
+			recreation_time_minute = $3,
+			recreation_price = $4,
+			position_lat = $5,
+			position_long = $6,
+			recreation_city = $7,
+			recreation_image = $8,
+			recreation_description = $9,
+			updated_at = now()
+		WHERE
+			recreation_id = $1 AND
+			deleted_at IS NULL
+	`
+
+	result, err := repo.DB.Writer(database.ScopeRecreations).ExecContext(
+		ctx,
+		query,
+		recreation.RecreationID,
+		recreation.RecreationName,
+		recreation.RecreationTimeMinute,
+		recreation.RecreationPrice,
+		recreation.PositionLat,
+		recreation.PositionLong,
+		recreation.RecreationCity,
+		recreation.RecreationImage,
+		recreation.RecreationDescription,
+	)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	if affected == 0 {
+		return apperror.RecreationNotExists
+	}
+
+	return nil
+}
+
+func (repo *postgreRecreationRepo) RestoreRecreation(recreationID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			ms_recreation
+		SET
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE
+			recreation_id = $1 AND
+			deleted_at IS NOT NULL
+	`
+
+	result, err := repo.DB.Writer(database.ScopeRecreations).ExecContext(ctx, query, recreationID)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	if affected == 0 {
+		return apperror.RecreationNotExists
+	}
+
+	return nil
+}
+
+// PurgeRecreations hard-deletes recreations soft-deleted before deletedBefore,
+// along with their swipes.
+func (repo *postgreRecreationRepo) PurgeRecreations(deletedBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		WITH purged AS (
+			DELETE FROM
+				ms_recreation
+			WHERE
+				deleted_at < $1
+			RETURNING
+				recreation_id
+		), swiped AS (
+			DELETE FROM
+				swipes
+			WHERE
+				venue_type = 'recreation' AND
+				venue_id IN (SELECT recreation_id FROM purged)
+		)
+		SELECT
+			count(*)
+		FROM
+			purged
+	`
+
+	var purged int64
+	err := repo.DB.Writer(database.ScopeRecreations).QueryRowContext(ctx, query, deletedBefore).Scan(&purged)
+	if err != nil {
+		log.Println(err)
+		return 0, apperror.InternalServerError.Wrap(err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/recreation"
+)
+
+const (
+	KeyRecreationsGeo = "recreations:geo"
+
+	geoRebuildTimeout = 5 * time.Minute
+)
+
+// geoRecreationRepo answers FindNearby from a Redis geo index and keeps the
+// index in step with every write. It falls back to the SQL search in next
+// while the index is being built, after a write to it failed, or while Redis
+// is unavailable. The index is rebuilt from db, the database repository
+// itself, so the rebuild doesn't go through the cache in next and fill it
+// with every recreation page.
+type geoRecreationRepo struct {
+	index *geo.Index
+	db    recreation.RecreationRepository
+	next  recreation.RecreationRepository
+}
+
+func NewGeoRecreationRepository(index *geo.Index, db recreation.RecreationRepository, next recreation.RecreationRepository) recreation.RecreationRepository {
+	return &geoRecreationRepo{
+		index: index,
+		db:    db,
+		next:  next,
+	}
+}
+
+func (repo *geoRecreationRepo) CreateRecreation(recreation *model.Recreation) (int64, error) {
+	recreationID, err := repo.next.CreateRecreation(recreation)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	if err := repo.index.Add(recreationID, recreation.PositionLat, recreation.PositionLong); err != nil {
+		log.Println(err)
+	}
+
+	return recreationID, nil
+}
+
+func (repo *geoRecreationRepo) UpdateRecreation(recreation *model.Recreation) error {
+	if err := repo.next.UpdateRecreation(recreation); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := repo.index.Add(recreation.RecreationID, recreation.PositionLat, recreation.PositionLong); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (repo *geoRecreationRepo) DeleteRecreation(recreationID int64) error {
+	if err := repo.next.DeleteRecreation(recreationID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := repo.index.Remove(recreationID); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (repo *geoRecreationRepo) RestoreRecreation(recreationID int64) error {
+	if err := repo.next.RestoreRecreation(recreationID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	recreation, err := repo.next.FindRecreationByID(recreationID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	if err := repo.index.Add(recreation.RecreationID, recreation.PositionLat, recreation.PositionLong); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (repo *geoRecreationRepo) FindRecreationByID(recreationID int64) (*model.Recreation, error) {
+	return repo.next.FindRecreationByID(recreationID)
+}
+
+func (repo *geoRecreationRepo) FindRecreationsByIDs(recreationIDs []int64) (model.Recreations, error) {
+	return repo.next.FindRecreationsByIDs(recreationIDs)
+}
+
+func (repo *geoRecreationRepo) FindAllRecreations(query *listing.Query) (*model.RecreationPage, error) {
+	return repo.next.FindAllRecreations(query)
+}
+
+func (repo *geoRecreationRepo) FindByLocation(cityName string, query *listing.Query) (*model.RecreationPage, error) {
+	return repo.next.FindByLocation(cityName, query)
+}
+
+func (repo *geoRecreationRepo) PurgeRecreations(deletedBefore time.Time) (int64, error) {
+	return repo.next.PurgeRecreations(deletedBefore)
+}
+
+// FindNearby loads the hits in one FindRecreationsByIDs call, so a hit the
+// index still holds for a deleted recreation is dropped from the result and
+// from the index.
+func (repo *geoRecreationRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRecreations, error) {
+	ready, err := repo.index.Ready()
+	if err != nil {
+		log.Println(err)
+		return repo.next.FindNearby(lat, long, radiusKM, limit)
+	}
+
+	if !ready {
+		go repo.rebuildIndex()
+		return repo.next.FindNearby(lat, long, radiusKM, limit)
+	}
+
+	hits, err := repo.index.Search(lat, long, radiusKM, limit)
+	if err != nil {
+		log.Println(err)
+		return repo.next.FindNearby(lat, long, radiusKM, limit)
+	}
+
+	recreationIDs := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		recreationIDs = append(recreationIDs, hit.ID)
+	}
+
+	found, err := repo.next.FindRecreationsByIDs(recreationIDs)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	byID := make(map[int64]*model.Recreation, len(found))
+	for _, recreation := range found {
+		byID[recreation.RecreationID] = recreation
+	}
+
+	recreations := make(model.NearbyRecreations, 0, len(hits))
+	for _, hit := range hits {
+		recreation, ok := byID[hit.ID]
+		if !ok {
+			repo.index.Remove(hit.ID)
+			continue
+		}
+
+		recreations = append(recreations, &model.NearbyRecreation{
+			Recreation: *recreation,
+			DistanceKM: hit.DistanceKM,
+		})
+	}
+
+	return recreations, nil
+}
+
+// rebuildIndex adds every recreation in db to the index, then marks it ready.
+// Only one instance rebuilds at a time; writes meanwhile keep updating the
+// index as usual. If an Add fails the index is left unready, and the next
+// search after the lock expires tries again.
+func (repo *geoRecreationRepo) rebuildIndex() {
+	locked, err := repo.index.LockRebuild(geoRebuildTimeout)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !locked {
+		return
+	}
+
+	query := &listing.Query{Limit: listing.MaxLimit, Sort: "id"}
+	for {
+		page, err := repo.db.FindAllRecreations(query)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		for _, recreation := range page.Recreations {
+			if err := repo.index.Add(recreation.RecreationID, recreation.PositionLat, recreation.PositionLong); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		last := page.Recreations[len(page.Recreations)-1]
+		query.After = &listing.Cursor{
+			Sort:  query.Sort,
+			Value: strconv.FormatInt(last.RecreationID, 10),
+			ID:    last.RecreationID,
+		}
+	}
+
+	if err := repo.index.MarkReady(); err != nil {
+		log.Println(err)
+	}
+}
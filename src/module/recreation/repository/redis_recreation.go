@@ -1,71 +1,142 @@
 package repository
 
 import (
+	"fmt"
 	"log"
+	"time"
 
-	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/recreation"
-	redigo "github.com/gomodule/redigo/redis"
-	cache "github.com/patrickmn/go-cache"
 )
 
 const (
-	KeyRecreationsFindAll = "recreations:find_all"
-	KeyRecreationsFind    = "recreations:find"
+	KeyRecreationsFindAll        = "recreations:find_all"
+	KeyRecreationsFind           = "recreations:find"
+	KeyRecreationsFindByLocation = "recreations:find_by_location"
 )
 
 type redisRecreationRepo struct {
-	cache map[string]*cache.Cache
-	pool  *redigo.Pool
-	next  recreation.RecreationRepository
+	find           *cache.Namespace
+	findAll        *cache.Namespace
+	findByLocation *cache.Namespace
+	next           recreation.RecreationRepository
 }
 
-func NewMiddlewareRecreationRepository(cache map[string]*cache.Cache, pool *redigo.Pool, next recreation.RecreationRepository) recreation.RecreationRepository {
+func NewMiddlewareRecreationRepository(store *cache.Store, localExpiration, remoteExpiration, staleExpiration time.Duration, next recreation.RecreationRepository) recreation.RecreationRepository {
 	return &redisRecreationRepo{
-		cache: cache,
-		pool:  pool,
-		next:  next,
+		find: store.Namespace(KeyRecreationsFind, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.Recreation)
+		})),
+		findAll: store.Namespace(KeyRecreationsFindAll, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.RecreationPage)
+		})).WithStaleWhileRevalidate(staleExpiration),
+		findByLocation: store.Namespace(KeyRecreationsFindByLocation, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.RecreationPage)
+		})),
+		next: next,
 	}
 }
 
-func (repo *redisRecreationRepo) do(command string, args ...interface{}) (reply interface{}, err error) {
-	conn := repo.pool.Get()
-	defer conn.Close()
-
-	return conn.Do(command, args...)
+func (repo *redisRecreationRepo) clearAllFindListCache() {
+	repo.findAll.InvalidateAll()
+	repo.findByLocation.InvalidateAll()
 }
 
-func (repo *redisRecreationRepo) clearAllFindListCache() error {
-	keys := []interface{}{
-		KeyRecreationsFindAll,
+func (repo *redisRecreationRepo) CreateRecreation(recreation *model.Recreation) (int64, error) {
+	lastID, err := repo.next.CreateRecreation(recreation)
+	if err != nil {
+		log.Println(err)
+		return 0, err
 	}
 
-	if _, err := repo.do("DEL", keys...); err != nil {
+	repo.clearAllFindListCache()
+	return lastID, nil
+}
+
+func (repo *redisRecreationRepo) FindRecreationByID(recreationID int64) (*model.Recreation, error) {
+	recreation, err := repo.find.GetOrLoad(fmt.Sprintf("%v", recreationID), func() (interface{}, error) {
+		return repo.next.FindRecreationByID(recreationID)
+	})
+	if err != nil {
 		log.Println(err)
-		return apperror.InternalServerError
+		return nil, err
 	}
 
-	repo.cache[KeyRecreationsFindAll].Flush()
-	return nil
+	return recreation.(*model.Recreation), nil
 }
 
-func (repo *redisRecreationRepo) CreateRecreation(recreation *model.Recreation) (int64, error) {
-	return repo.next.CreateRecreation(recreation)
+func (repo *redisRecreationRepo) FindAllRecreations(query *listing.Query) (*model.RecreationPage, error) {
+	page, err := repo.findAll.GetOrLoad(query.Key(), func() (interface{}, error) {
+		return repo.next.FindAllRecreations(query)
+	})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page.(*model.RecreationPage), nil
 }
 
-func (repo *redisRecreationRepo) FindRecreationByID(recreationID int64) (*model.Recreation, error) {
-	return repo.next.FindRecreationByID(recreationID)
+func (repo *redisRecreationRepo) FindByLocation(cityName string, query *listing.Query) (*model.RecreationPage, error) {
+	page, err := repo.findByLocation.GetOrLoad(cityName+"?"+query.Key(), func() (interface{}, error) {
+		return repo.next.FindByLocation(cityName, query)
+	})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page.(*model.RecreationPage), nil
 }
 
-func (repo *redisRecreationRepo) FindAllRecreations() (model.Recreations, error) {
-	return repo.next.FindAllRecreations()
+// FindRecreationsByIDs isn't cached: it backs FindNearby, which isn't
+// either.
+func (repo *redisRecreationRepo) FindRecreationsByIDs(recreationIDs []int64) (model.Recreations, error) {
+	return repo.next.FindRecreationsByIDs(recreationIDs)
 }
 
-func (repo *redisRecreationRepo) FindByLocation(cityName string) (model.Recreations, error) {
-	return repo.next.FindByLocation(cityName)
+// FindNearby isn't cached: coordinates hardly ever repeat exactly.
+func (repo *redisRecreationRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRecreations, error) {
+	return repo.next.FindNearby(lat, long, radiusKM, limit)
 }
 
 func (repo *redisRecreationRepo) DeleteRecreation(recreationID int64) error {
-	return repo.next.DeleteRecreation(recreationID)
+	if err := repo.next.DeleteRecreation(recreationID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	repo.clearAllFindListCache()
+	repo.find.Invalidate(fmt.Sprintf("%v", recreationID))
+	return nil
+}
+
+func (repo *redisRecreationRepo) UpdateRecreation(recreation *model.Recreation) error {
+	if err := repo.next.UpdateRecreation(recreation); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	repo.clearAllFindListCache()
+	repo.find.Invalidate(fmt.Sprintf("%v", recreation.RecreationID))
+	return nil
+}
+
+func (repo *redisRecreationRepo) RestoreRecreation(recreationID int64) error {
+	if err := repo.next.RestoreRecreation(recreationID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	repo.clearAllFindListCache()
+	repo.find.Invalidate(fmt.Sprintf("%v", recreationID))
+	return nil
+}
+
+// PurgeRecreations leaves the cache alone: purged recreations were already
+// invalidated when they were deleted.
+func (repo *redisRecreationRepo) PurgeRecreations(deletedBefore time.Time) (int64, error) {
+	return repo.next.PurgeRecreations(deletedBefore)
 }
@@ -2,16 +2,22 @@ package recreation
 
 import (
 	"log"
+	"time"
 
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 )
 
 type Usecase interface {
 	CreateRecreation(recreationName, recreationCity, recreationImage, recreationDescription string, recrationTime, recreationPrice int, positionLat, positionLong float64) (int64, error)
+	UpdateRecreation(recreationID int64, recreationName, recreationCity, recreationImage, recreationDescription string, recrationTime, recreationPrice int, positionLat, positionLong float64) error
 	GetRecreation(recreationID int64) (*model.Recreation, error)
-	GetAllRecrations() (model.Recreations, error)
-	GetRecreationsByCity(cityName string) (model.Recreations, error)
+	GetAllRecrations(query *listing.Query) (*model.RecreationPage, error)
+	GetRecreationsByCity(cityName string, query *listing.Query) (*model.RecreationPage, error)
+	GetNearbyRecreations(lat, long, radiusKM float64, limit int) (model.NearbyRecreations, error)
 	DeleteRecreationByID(recrationID int64) error
+	RestoreRecreationByID(recreationID int64) error
+	PurgeDeletedRecreations(deletedBefore time.Time) (int64, error)
 }
 
 type usecase struct {
@@ -37,14 +43,14 @@ func (u *usecase) CreateRecreation(recreationName, recreationCity, recreationIma
 	return recreationID, nil
 }
 
-func (u *usecase) GetAllRecrations() (model.Recreations, error) {
-	recreations, err := u.recreationRepo.FindAllRecreations()
+func (u *usecase) GetAllRecrations(query *listing.Query) (*model.RecreationPage, error) {
+	page, err := u.recreationRepo.FindAllRecreations(query)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return recreations, nil
+	return page, nil
 }
 
 func (u *usecase) GetRecreation(venueID int64) (*model.Recreation, error) {
@@ -57,8 +63,18 @@ func (u *usecase) GetRecreation(venueID int64) (*model.Recreation, error) {
 	return recreation, nil
 }
 
-func (u *usecase) GetRecreationsByCity(cityName string) (model.Recreations, error) {
-	recreations, err := u.recreationRepo.FindByLocation(cityName)
+func (u *usecase) GetRecreationsByCity(cityName string, query *listing.Query) (*model.RecreationPage, error) {
+	page, err := u.recreationRepo.FindByLocation(cityName, query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (u *usecase) GetNearbyRecreations(lat, long, radiusKM float64, limit int) (model.NearbyRecreations, error) {
+	recreations, err := u.recreationRepo.FindNearby(lat, long, radiusKM, limit)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -80,3 +96,42 @@ func (u *usecase) DeleteRecreationByID(recreationID int64) error {
 
 	return nil
 }
+
+// UpdateRecreation replaces every editable field of the recreation.
+func (u *usecase) UpdateRecreation(recreationID int64, recreationName, recreationCity, recreationImage, recreationDescription string, recrationTime, recreationPrice int, positionLat, positionLong float64) error {
+	current, err := u.recreationRepo.FindRecreationByID(recreationID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	updated := model.NewRecreation(recreationName, recreationCity, recreationImage, recreationDescription, recrationTime, recreationPrice, positionLat, positionLong)
+	updated.RecreationID = current.RecreationID
+	updated.CreatedAt = current.CreatedAt
+
+	if err := u.recreationRepo.UpdateRecreation(updated); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (u *usecase) RestoreRecreationByID(recreationID int64) error {
+	if err := u.recreationRepo.RestoreRecreation(recreationID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (u *usecase) PurgeDeletedRecreations(deletedBefore time.Time) (int64, error) {
+	purged, err := u.recreationRepo.PurgeRecreations(deletedBefore)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	return purged, nil
+}
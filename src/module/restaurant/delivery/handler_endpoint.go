@@ -5,6 +5,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/restaurant"
 	"github.com/atletaid/go-template/util/httputil"
@@ -15,27 +18,39 @@ type RestaurantHandler struct {
 	rtu restaurant.Usecase
 }
 
-func NewRestaurantHandler(router *gin.Engine, rtu restaurant.Usecase) *gin.Engine {
+func NewRestaurantHandler(router *gin.Engine, m *auth.Middleware, rtu restaurant.Usecase) *gin.Engine {
 	handler := &RestaurantHandler{rtu}
 
 	v1 := router.Group("/api")
-	v1.POST("/restaurant", handler.CreateRestaurantEndpoint())
 	v1.GET("/restaurant/:restaurant_id", handler.GetRestaurantEndpoint())
 	v1.GET("/restaurants", handler.GetAllRestaurantsEndpoint())
+	v1.GET("/restaurants/nearby", handler.GetNearbyRestaurantsEndpoint())
 	v1.POST("/restaurant/city", handler.GetRestaurantsByCityEndpoint())
-	v1.DELETE("/restaurant/:restaurant_id", handler.DeleteRestaurantEndpoint())
+
+	v1.Use(m.AuthUserToken(), m.RequireVerified(), m.RequireRole(model.RoleCurator, model.RoleAdmin))
+	{
+		v1.POST("/restaurant", handler.CreateRestaurantEndpoint())
+		v1.PUT("/restaurant/:restaurant_id", handler.UpdateRestaurantEndpoint())
+		v1.PATCH("/restaurant/:restaurant_id", handler.PatchRestaurantEndpoint())
+		v1.DELETE("/restaurant/:restaurant_id", handler.DeleteRestaurantEndpoint())
+	}
+
+	admin := v1.Group("", m.RequireRole(model.RoleAdmin))
+	{
+		admin.POST("/restaurants/:restaurant_id/restore", handler.RestoreRestaurantEndpoint())
+	}
 
 	return router
 }
 
 type createRestaurantRequest struct {
-	RestaurantName        string  `json:"restaurant_name" form:"restaurant_name"`
-	RestaurantTimeMinute  int     `json:"restaurant_time_minute" form:"restaurant_time_minute"`
-	RestaurantPrice       int     `json:"restaurant_price" form:"restaurant_price"`
-	PositionLat           float64 `json:"position_lat" form:"position_lat"`
-	PositionLong          float64 `json:"position_long" form:"position_long"`
-	RestaurantCity        string  `json:"restaurant_city" form:"restaurant_city"`
-	RestaurantImage       string  `json:"restaurant_image" form:"restaurant_image"`
+	RestaurantName        string  `json:"restaurant_name" form:"restaurant_name" binding:"required,max=255"`
+	RestaurantTimeMinute  int     `json:"restaurant_time_minute" form:"restaurant_time_minute" binding:"gte=0"`
+	RestaurantPrice       int     `json:"restaurant_price" form:"restaurant_price" binding:"gte=0"`
+	PositionLat           float64 `json:"position_lat" form:"position_lat" binding:"gte=-90,lte=90"`
+	PositionLong          float64 `json:"position_long" form:"position_long" binding:"gte=-180,lte=180"`
+	RestaurantCity        string  `json:"restaurant_city" form:"restaurant_city" binding:"required,max=255"`
+	RestaurantImage       string  `json:"restaurant_image" form:"restaurant_image" binding:"omitempty,url"`
 	RestaurantDescription string  `json:"restaurant_description" form:"restaurant_description"`
 }
 
@@ -55,6 +70,13 @@ func (h *RestaurantHandler) CreateRestaurantEndpoint() gin.HandlerFunc {
 			return
 		}
 
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
 		restaurantID, err := h.rtu.CreateRestaurant(req.RestaurantName, req.RestaurantCity, req.RestaurantImage, req.RestaurantDescription, req.RestaurantTimeMinute, req.RestaurantPrice, req.PositionLat, req.PositionLong)
 		if err != nil {
 			log.Println(err)
@@ -84,7 +106,7 @@ func (h *RestaurantHandler) GetRestaurantEndpoint() gin.HandlerFunc {
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
-			httputil.WriteErrorResponse(c, processTime, err)
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
 			return
 		}
 
@@ -105,15 +127,26 @@ func (h *RestaurantHandler) GetRestaurantEndpoint() gin.HandlerFunc {
 	}
 }
 
-type dataRestaurantsResponse struct {
-	Restaurants model.Restaurants `json:"restaurants"`
+func decodeListQuery(c *gin.Context) (*listing.Query, error) {
+	req := listing.Request{}
+	if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+		return nil, apperror.DecodeError.Wrap(err)
+	}
+
+	if err := httputil.ValidateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	return req.Query(restaurant.ListSorts, restaurant.ListFilters...)
 }
 
+// GetAllRestaurantsEndpoint lists restaurants one page at a time. See
+// listing.Request for the query parameters.
 func (h *RestaurantHandler) GetAllRestaurantsEndpoint() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 
-		restaurants, err := h.rtu.GetAllRestaurants()
+		query, err := decodeListQuery(c)
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
@@ -121,19 +154,25 @@ func (h *RestaurantHandler) GetAllRestaurantsEndpoint() gin.HandlerFunc {
 			return
 		}
 
-		resp := dataRestaurantsResponse{
-			Restaurants: restaurants,
+		page, err := h.rtu.GetAllRestaurants(query)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
 		}
 
 		processTime := time.Now().Sub(startTime).Seconds()
-		httputil.WriteResponse(c, []string{"Success get all restaurants"}, processTime, resp)
+		httputil.WriteResponse(c, []string{"Success get all restaurants"}, processTime, page)
 	}
 }
 
 type getRestaurantByCityRequest struct {
-	City string `json:"restaurant_city" form:"restaurant_city"`
+	City string `json:"restaurant_city" form:"restaurant_city" binding:"required"`
 }
 
+// GetRestaurantsByCityEndpoint takes the city in the body and the list query
+// parameters in the URL, like GetAllRestaurantsEndpoint.
 func (h *RestaurantHandler) GetRestaurantsByCityEndpoint() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
@@ -146,7 +185,22 @@ func (h *RestaurantHandler) GetRestaurantsByCityEndpoint() gin.HandlerFunc {
 			return
 		}
 
-		restaurants, err := h.rtu.GetRestaurantsByCity(req.City)
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		query, err := decodeListQuery(c)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		page, err := h.rtu.GetRestaurantsByCity(req.City, query)
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
@@ -155,7 +209,64 @@ func (h *RestaurantHandler) GetRestaurantsByCityEndpoint() gin.HandlerFunc {
 		}
 
 		processTime := time.Now().Sub(startTime).Seconds()
-		httputil.WriteResponse(c, []string{"Success get restaurants by city"}, processTime, restaurants)
+		httputil.WriteResponse(c, []string{"Success get restaurants by city"}, processTime, page)
+	}
+}
+
+type dataNearbyRestaurantsResponse struct {
+	Restaurants model.NearbyRestaurants `json:"restaurants"`
+}
+
+type getNearbyRestaurantsRequest struct {
+	Lat      *float64 `json:"lat" form:"lat" binding:"required,gte=-85,lte=85"`
+	Long     *float64 `json:"long" form:"long" binding:"required,gte=-180,lte=180"`
+	RadiusKM float64  `json:"radius_km" form:"radius_km" binding:"omitempty,gt=0,lte=100"`
+	Limit    int      `json:"limit" form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// GetNearbyRestaurantsEndpoint lists the restaurants within radius_km (default 5)
+// of lat and long, nearest first, each with its distance_km.
+func (h *RestaurantHandler) GetNearbyRestaurantsEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := getNearbyRestaurantsRequest{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if req.RadiusKM == 0 {
+			req.RadiusKM = 5
+		}
+
+		if req.Limit == 0 {
+			req.Limit = listing.DefaultLimit
+		}
+
+		restaurants, err := h.rtu.GetNearbyRestaurants(*req.Lat, *req.Long, req.RadiusKM, req.Limit)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		resp := dataNearbyRestaurantsResponse{
+			Restaurants: restaurants,
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get nearby restaurants"}, processTime, resp)
 	}
 }
 
@@ -167,7 +278,7 @@ func (h *RestaurantHandler) DeleteRestaurantEndpoint() gin.HandlerFunc {
 		if err != nil {
 			log.Println(err)
 			processTime := time.Now().Sub(startTime).Seconds()
-			httputil.WriteErrorResponse(c, processTime, err)
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
 			return
 		}
 
@@ -183,3 +294,115 @@ func (h *RestaurantHandler) DeleteRestaurantEndpoint() gin.HandlerFunc {
 		httputil.WriteResponse(c, []string{"Success delete restaurant"}, processTime, nil)
 	}
 }
+
+// UpdateRestaurantEndpoint replaces the restaurant; it takes the same body as create.
+func (h *RestaurantHandler) UpdateRestaurantEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		restaurantID, err := strconv.ParseInt(c.Param("restaurant_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		req := createRestaurantRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		h.updateRestaurant(c, startTime, restaurantID, &req)
+	}
+}
+
+// PatchRestaurantEndpoint applies a JSON merge patch to the restaurant. The patched
+// restaurant must pass the same validation as a created one.
+func (h *RestaurantHandler) PatchRestaurantEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		restaurantID, err := strconv.ParseInt(c.Param("restaurant_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		restaurant, err := h.rtu.GetRestaurant(restaurantID)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		req := createRestaurantRequest{
+			RestaurantName:        restaurant.RestaurantName,
+			RestaurantTimeMinute:  restaurant.RestaurantTimeMinute,
+			RestaurantPrice:       restaurant.RestaurantPrice,
+			PositionLat:           restaurant.PositionLat,
+			PositionLong:          restaurant.PositionLong,
+			RestaurantCity:        restaurant.RestaurantCity,
+			RestaurantImage:       restaurant.RestaurantImage,
+			RestaurantDescription: restaurant.RestaurantDescription,
+		}
+		if err := httputil.DecodeMergePatch(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		h.updateRestaurant(c, startTime, restaurantID, &req)
+	}
+}
+
+func (h *RestaurantHandler) updateRestaurant(c *gin.Context, startTime time.Time, restaurantID int64, req *createRestaurantRequest) {
+	if err := httputil.ValidateRequest(req); err != nil {
+		log.Println(err)
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteErrorResponse(c, processTime, err)
+		return
+	}
+
+	err := h.rtu.UpdateRestaurant(restaurantID, req.RestaurantName, req.RestaurantCity, req.RestaurantImage, req.RestaurantDescription, req.RestaurantTimeMinute, req.RestaurantPrice, req.PositionLat, req.PositionLong)
+	if err != nil {
+		log.Println(err)
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteErrorResponse(c, processTime, err)
+		return
+	}
+
+	processTime := time.Now().Sub(startTime).Seconds()
+	httputil.WriteResponse(c, []string{"Success update restaurant"}, processTime, nil)
+}
+
+func (h *RestaurantHandler) RestoreRestaurantEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		restaurantID, err := strconv.ParseInt(c.Param("restaurant_id"), 10, 64)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, apperror.StatusBadRequest.Wrap(err))
+			return
+		}
+
+		if err := h.rtu.RestoreRestaurantByID(restaurantID); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success restore restaurant"}, processTime, nil)
+	}
+}
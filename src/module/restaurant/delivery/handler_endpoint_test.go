@@ -0,0 +1,104 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/restaurant"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUsecase holds one restaurant and records the last update made to it.
+type fakeUsecase struct {
+	restaurant.Usecase
+
+	current *model.Restaurant
+	updated *model.Restaurant
+}
+
+func (u *fakeUsecase) GetRestaurant(restaurantID int64) (*model.Restaurant, error) {
+	return u.current, nil
+}
+
+func (u *fakeUsecase) UpdateRestaurant(restaurantID int64, restaurantName, restaurantCity, restaurantImage, restaurantDescription string, restaurantTime, restaurantPrice int, positionLat, positionLong float64) error {
+	u.updated = &model.Restaurant{
+		RestaurantID:          restaurantID,
+		RestaurantName:        restaurantName,
+		RestaurantCity:        restaurantCity,
+		RestaurantImage:       restaurantImage,
+		RestaurantDescription: restaurantDescription,
+		RestaurantTimeMinute:  restaurantTime,
+		RestaurantPrice:       restaurantPrice,
+		PositionLat:           positionLat,
+		PositionLong:          positionLong,
+	}
+	return nil
+}
+
+func patchRestaurant(u restaurant.Usecase, patch string) *httptest.ResponseRecorder {
+	handler := &RestaurantHandler{u}
+	router := gin.New()
+	router.PATCH("/restaurant/:restaurant_id", handler.PatchRestaurantEndpoint())
+
+	req := httptest.NewRequest(http.MethodPatch, "/restaurant/7", strings.NewReader(patch))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPatchRestaurantEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	current := &model.Restaurant{
+		RestaurantID:          7,
+		RestaurantName:        "Warung",
+		RestaurantCity:        "Bandung",
+		RestaurantDescription: "Spicy",
+		RestaurantPrice:       20,
+		PositionLat:           -6.9,
+		PositionLong:          107.6,
+	}
+
+	u := &fakeUsecase{current: current}
+	if w := patchRestaurant(u, `{"restaurant_price":25,"restaurant_description":null}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	want := *current
+	want.RestaurantPrice = 25
+	want.RestaurantDescription = ""
+	if u.updated == nil || *u.updated != want {
+		t.Errorf("UpdateRestaurant() got %+v, want %+v", u.updated, want)
+	}
+}
+
+// A patch is validated against the whole patched restaurant, like a PUT.
+func TestPatchRestaurantEndpointRefusesInvalidResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	current := &model.Restaurant{RestaurantID: 7, RestaurantName: "Warung", RestaurantCity: "Bandung"}
+
+	tests := []struct {
+		name  string
+		patch string
+		want  int
+	}{
+		{"required field removed", `{"restaurant_name":null}`, http.StatusUnprocessableEntity},
+		{"value out of range", `{"position_lat":91}`, http.StatusUnprocessableEntity},
+		{"malformed patch", `{"restaurant_price":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		u := &fakeUsecase{current: current}
+		if w := patchRestaurant(u, tt.patch); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if u.updated != nil {
+			t.Errorf("%s: restaurant updated to %+v", tt.name, u.updated)
+		}
+	}
+}
@@ -1,13 +1,28 @@
 package restaurant
 
 import (
+	"time"
+
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 )
 
+// ListSorts and ListFilters are what restaurant list queries may sort and
+// filter on. The first sort is the default.
+var (
+	ListSorts   = []string{"id", "created_at", "name", "price", "time"}
+	ListFilters = []string{listing.FilterCity, listing.FilterPrice, listing.FilterTime}
+)
+
 type RestaurantRepository interface {
 	CreateRestaurant(*model.Restaurant) (int64, error)
 	FindRestaurantByID(restaurantID int64) (*model.Restaurant, error)
-	FindAllRestaurants() (model.Restaurants, error)
-	FindByLocation(cityName string) (model.Restaurants, error)
+	FindRestaurantsByIDs(restaurantIDs []int64) (model.Restaurants, error)
+	FindAllRestaurants(query *listing.Query) (*model.RestaurantPage, error)
+	FindByLocation(cityName string, query *listing.Query) (*model.RestaurantPage, error)
+	FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error)
+	UpdateRestaurant(restaurant *model.Restaurant) error
 	DeleteRestaurantID(int64) error
+	RestoreRestaurant(restaurantID int64) error
+	PurgeRestaurants(deletedBefore time.Time) (int64, error)
 }
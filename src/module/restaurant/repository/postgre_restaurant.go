@@ -4,26 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/restaurant"
 	"github.com/lib/pq"
-	"github.com/tokopedia/sqlt"
 )
 
 type postgreRestaurantRepo struct {
-	DbMaster *sqlt.DB
-	DbSlave  *sqlt.DB
-	Timeout  time.Duration
+	DB      *database.DB
+	Timeout time.Duration
 }
 
-func NewRestaurantRepository(dbMaster *sqlt.DB, dbSlave *sqlt.DB, timeout time.Duration) restaurant.RestaurantRepository {
+func NewRestaurantRepository(db *database.DB, timeout time.Duration) restaurant.RestaurantRepository {
 	return &postgreRestaurantRepo{
-		DbMaster: dbMaster,
-		DbSlave:  dbSlave,
-		Timeout:  timeout,
+		DB:      db,
+		Timeout: timeout,
 	}
 }
 
@@ -42,7 +43,8 @@ func (repo *postgreRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant
 			restaurant_city,
 			restaurant_image,
 			restaurant_description,
-			created_at
+			created_at,
+			updated_at
 		)
 		VALUES
 		(
@@ -54,6 +56,7 @@ func (repo *postgreRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant
 			$6,
 			$7,
 			$8,
+			now(),
 			now()
 		)
 		RETURNING
@@ -62,7 +65,7 @@ func (repo *postgreRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant
 
 	var lastInsertID int64
 	log.Println(restaurant)
-	err := repo.DbMaster.QueryRowContext(
+	err := repo.DB.Writer(database.ScopeRestaurants).QueryRowContext(
 		ctx,
 		query,
 		restaurant.RestaurantName,
@@ -76,7 +79,7 @@ func (repo *postgreRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant
 	).Scan(&lastInsertID)
 	if err != nil {
 		log.Println(err)
-		return 0, apperror.InternalServerError
+		return 0, apperror.InternalServerError.Wrap(err)
 	}
 
 	return lastInsertID, nil
@@ -97,11 +100,13 @@ func (repo *postgreRestaurantRepo) FindRestaurantByID(restaurantID int64) (*mode
 			restaurant_city,
 			restaurant_image,
 			restaurant_description,
-			created_at
+			created_at,
+			updated_at
 		FROM
 			ms_restaurant
 		WHERE
-			restaurant_id = $1
+			restaurant_id = $1 AND
+			deleted_at IS NULL
 	`
 
 	var (
@@ -115,9 +120,10 @@ func (repo *postgreRestaurantRepo) FindRestaurantByID(restaurantID int64) (*mode
 		rtrestaurantImage       sql.NullString
 		rtrestaurantDescription sql.NullString
 		rtCreatedAt             pq.NullTime
+		rtUpdatedAt             pq.NullTime
 	)
 
-	err := repo.DbSlave.QueryRowContext(ctx, query, restaurantID).Scan(
+	err := repo.DB.Reader(database.ScopeRestaurants).QueryRowContext(ctx, query, restaurantID).Scan(
 		&rtrestaurantID,
 		&rtrestaurantName,
 		&rtrestaurantTimeMinute,
@@ -128,6 +134,7 @@ func (repo *postgreRestaurantRepo) FindRestaurantByID(restaurantID int64) (*mode
 		&rtrestaurantImage,
 		&rtrestaurantDescription,
 		&rtCreatedAt,
+		&rtUpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -137,7 +144,7 @@ func (repo *postgreRestaurantRepo) FindRestaurantByID(restaurantID int64) (*mode
 
 	if err != nil {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
 
 	restaurant := model.Restaurant{
@@ -151,15 +158,140 @@ func (repo *postgreRestaurantRepo) FindRestaurantByID(restaurantID int64) (*mode
 		RestaurantImage:       rtrestaurantImage.String,
 		RestaurantDescription: rtrestaurantDescription.String,
 		CreatedAt:             rtCreatedAt.Time,
+		UpdatedAt:             rtUpdatedAt.Time,
 	}
 
 	return &restaurant, nil
 }
 
-func (repo *postgreRestaurantRepo) FindAllRestaurants() (model.Restaurants, error) {
+// FindRestaurantsByIDs loads the restaurants with the given IDs in one
+// query, in no particular order. Deleted and unknown IDs are left out.
+func (repo *postgreRestaurantRepo) FindRestaurantsByIDs(restaurantIDs []int64) (model.Restaurants, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
+	query := `
+		SELECT
+			restaurant_id,
+			restaurant_name,
+			restaurant_time_minute,
+			restaurant_price,
+			position_lat,
+			position_long,
+			restaurant_city,
+			restaurant_image,
+			restaurant_description,
+			created_at,
+			updated_at
+		FROM
+			ms_restaurant
+		WHERE
+			restaurant_id = ANY($1) AND
+			deleted_at IS NULL
+	`
+
+	rows, err := repo.DB.Reader(database.ScopeRestaurants).QueryContext(ctx, query, pq.Array(restaurantIDs))
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	restaurants := make(model.Restaurants, 0, len(restaurantIDs))
+	for rows.Next() {
+		var (
+			rtrestaurantID          sql.NullInt64
+			rtrestaurantName        sql.NullString
+			rtrestaurantTimeMinute  sql.NullInt64
+			rtrestaurantPrice       sql.NullInt64
+			rtPositionLat           sql.NullFloat64
+			rtPositionLong          sql.NullFloat64
+			rtrestaurantCity        sql.NullString
+			rtrestaurantImage       sql.NullString
+			rtrestaurantDescription sql.NullString
+			rtCreatedAt             pq.NullTime
+			rtUpdatedAt             pq.NullTime
+		)
+
+		if err := rows.Scan(
+			&rtrestaurantID,
+			&rtrestaurantName,
+			&rtrestaurantTimeMinute,
+			&rtrestaurantPrice,
+			&rtPositionLat,
+			&rtPositionLong,
+			&rtrestaurantCity,
+			&rtrestaurantImage,
+			&rtrestaurantDescription,
+			&rtCreatedAt,
+			&rtUpdatedAt,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		restaurant := model.Restaurant{
+			RestaurantID:          rtrestaurantID.Int64,
+			RestaurantName:        rtrestaurantName.String,
+			RestaurantTimeMinute:  int(rtrestaurantTimeMinute.Int64),
+			RestaurantPrice:       int(rtrestaurantPrice.Int64),
+			PositionLat:           rtPositionLat.Float64,
+			PositionLong:          rtPositionLong.Float64,
+			RestaurantCity:        rtrestaurantCity.String,
+			RestaurantImage:       rtrestaurantImage.String,
+			RestaurantDescription: rtrestaurantDescription.String,
+			CreatedAt:             rtCreatedAt.Time,
+			UpdatedAt:             rtUpdatedAt.Time,
+		}
+
+		restaurants = append(restaurants, &restaurant)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return restaurants, nil
+}
+
+var restaurantColumns = listing.Columns{
+	ID:         "restaurant_id",
+	City:       "restaurant_city",
+	Price:      "restaurant_price",
+	TimeMinute: "restaurant_time_minute",
+	Sorts: map[string]string{
+		"id":         "restaurant_id",
+		"created_at": "created_at",
+		"name":       "restaurant_name",
+		"price":      "restaurant_price",
+		"time":       "restaurant_time_minute",
+	},
+}
+
+func restaurantSortValue(restaurant *model.Restaurant, sort string) string {
+	switch sort {
+	case "created_at":
+		return listing.TimeValue(restaurant.CreatedAt)
+	case "name":
+		return restaurant.RestaurantName
+	case "price":
+		return strconv.Itoa(restaurant.RestaurantPrice)
+	case "time":
+		return strconv.Itoa(restaurant.RestaurantTimeMinute)
+	}
+	return strconv.FormatInt(restaurant.RestaurantID, 10)
+}
+
+func (repo *postgreRestaurantRepo) FindAllRestaurants(query *listing.Query) (*model.RestaurantPage, error) {
+	return repo.findRestaurantPage(query)
+}
+
+func (repo *postgreRestaurantRepo) findRestaurantPage(listQuery *listing.Query) (*model.RestaurantPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	clauses, args := listQuery.SQL(restaurantColumns, []string{"deleted_at IS NULL"}, nil)
 	query := `
 	SELECT
 		restaurant_id,
@@ -171,16 +303,18 @@ func (repo *postgreRestaurantRepo) FindAllRestaurants() (model.Restaurants, erro
 		restaurant_city,
 		restaurant_image,
 		restaurant_description,
-		created_at
+		created_at,
+		updated_at
 	FROM
-		ms_restaurant	
-	`
+		ms_restaurant
+	` + clauses
 
-	rows, err := repo.DbSlave.QueryContext(ctx, query)
+	rows, err := repo.DB.Reader(database.ScopeRestaurants).QueryContext(ctx, query, args...)
 	if err != nil && err != sql.ErrNoRows {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
+	defer rows.Close()
 
 	restaurants := make(model.Restaurants, 0)
 	for rows.Next() {
@@ -195,6 +329,7 @@ func (repo *postgreRestaurantRepo) FindAllRestaurants() (model.Restaurants, erro
 			rtrestaurantImage       sql.NullString
 			rtrestaurantDescription sql.NullString
 			rtCreatedAt             pq.NullTime
+			rtUpdatedAt             pq.NullTime
 		)
 
 		if err := rows.Scan(
@@ -208,9 +343,10 @@ func (repo *postgreRestaurantRepo) FindAllRestaurants() (model.Restaurants, erro
 			&rtrestaurantImage,
 			&rtrestaurantDescription,
 			&rtCreatedAt,
+			&rtUpdatedAt,
 		); err != nil {
 			log.Println(err)
-			return nil, apperror.InternalServerError
+			return nil, apperror.InternalServerError.Wrap(err)
 		}
 
 		restaurant := model.Restaurant{
@@ -224,35 +360,67 @@ func (repo *postgreRestaurantRepo) FindAllRestaurants() (model.Restaurants, erro
 			RestaurantImage:       rtrestaurantImage.String,
 			RestaurantDescription: rtrestaurantDescription.String,
 			CreatedAt:             rtCreatedAt.Time,
+			UpdatedAt:             rtUpdatedAt.Time,
 		}
 
 		restaurants = append(restaurants, &restaurant)
 	}
 
-	return restaurants, nil
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	page := &model.RestaurantPage{Restaurants: restaurants}
+	if len(restaurants) > listQuery.Limit {
+		page.Restaurants = restaurants[:listQuery.Limit]
+		last := page.Restaurants[listQuery.Limit-1]
+		page.NextCursor = listQuery.NextCursor(restaurantSortValue(last, listQuery.Sort), last.RestaurantID)
+	}
+
+	return page, nil
 }
 
+// DeleteRestaurantID soft-deletes the restaurant. Find* skip it from then on,
+// and the purge job removes it once the retention period has passed.
 func (repo *postgreRestaurantRepo) DeleteRestaurantID(restaurantID int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
 	query := `
-		DELETE FROM
+		UPDATE
 			ms_restaurant
+		SET
+			deleted_at = now(),
+			updated_at = now()
 		WHERE
-			restaurant_id = $1
+			restaurant_id = $1 AND
+			deleted_at IS NULL
 	`
 
-	_, err := repo.DbMaster.ExecContext(ctx, query, restaurantID)
+	_, err := repo.DB.Writer(database.ScopeRestaurants).ExecContext(ctx, query, restaurantID)
 	if err != nil {
 		log.Println(err)
-		return apperror.InternalServerError
+		return apperror.InternalServerError.Wrap(err)
 	}
 
 	return nil
 }
 
-func (repo *postgreRestaurantRepo) FindByLocation(cityName string) (model.Restaurants, error) {
+// FindByLocation lists the restaurants in cityName, overriding any city
+// filter in query.
+func (repo *postgreRestaurantRepo) FindByLocation(cityName string, query *listing.Query) (*model.RestaurantPage, error) {
+	byCity := *query
+	byCity.City = cityName
+	return repo.findRestaurantPage(&byCity)
+}
+
+// FindNearby lists the restaurants within radiusKM of the given point,
+// nearest first, measuring great-circle distance. The bounding box lets the
+// position index narrow the scan before distances are computed. This is the
+// authoritative search; the Redis geo index in front of it is only a
+// faster path and falls back here whenever it isn't ready.
+func (repo *postgreRestaurantRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
 	defer cancel()
 
@@ -267,20 +435,41 @@ func (repo *postgreRestaurantRepo) FindByLocation(cityName string) (model.Restau
 		restaurant_city,
 		restaurant_image,
 		restaurant_description,
-		created_at
-	FROM
-		ms_restaurant	
+		created_at,
+		updated_at,
+		distance_km
+	FROM (
+		SELECT
+			*,
+			6371 * 2 * asin(sqrt(
+				power(sin(radians(position_lat - $1) / 2), 2) +
+				cos(radians($1)) * cos(radians(position_lat)) *
+				power(sin(radians(position_long - $2) / 2), 2)
+			)) AS distance_km
+		FROM
+			ms_restaurant
+		WHERE
+			deleted_at IS NULL AND
+			position_lat BETWEEN $1 - $5 AND $1 + $5 AND
+			position_long BETWEEN $2 - $6 AND $2 + $6
+	) nearby
 	WHERE
-		restaurant_city = $1
+		distance_km <= $3
+	ORDER BY
+		distance_km,
+		restaurant_id
+	LIMIT $4
 	`
 
-	rows, err := repo.DbSlave.QueryContext(ctx, query, cityName)
+	latDelta, longDelta := geo.BoundingBox(lat, radiusKM)
+	rows, err := repo.DB.Reader(database.ScopeRestaurants).QueryContext(ctx, query, lat, long, radiusKM, limit, latDelta, longDelta)
 	if err != nil && err != sql.ErrNoRows {
 		log.Println(err)
-		return nil, apperror.InternalServerError
+		return nil, apperror.InternalServerError.Wrap(err)
 	}
+	defer rows.Close()
 
-	restaurants := make(model.Restaurants, 0)
+	restaurants := make(model.NearbyRestaurants, 0)
 	for rows.Next() {
 		var (
 			rtrestaurantID          sql.NullInt64
@@ -293,6 +482,8 @@ func (repo *postgreRestaurantRepo) FindByLocation(cityName string) (model.Restau
 			rtrestaurantImage       sql.NullString
 			rtrestaurantDescription sql.NullString
 			rtCreatedAt             pq.NullTime
+			rtUpdatedAt             pq.NullTime
+			rtDistanceKM            sql.NullFloat64
 		)
 
 		if err := rows.Scan(
@@ -306,26 +497,161 @@ func (repo *postgreRestaurantRepo) FindByLocation(cityName string) (model.Restau
 			&rtrestaurantImage,
 			&rtrestaurantDescription,
 			&rtCreatedAt,
+			&rtUpdatedAt,
+			&rtDistanceKM,
 		); err != nil {
 			log.Println(err)
-			return nil, apperror.InternalServerError
+			return nil, apperror.InternalServerError.Wrap(err)
 		}
 
-		restaurant := model.Restaurant{
-			RestaurantID:          rtrestaurantID.Int64,
-			RestaurantName:        rtrestaurantName.String,
-			RestaurantTimeMinute:  int(rtrestaurantTimeMinute.Int64),
-			RestaurantPrice:       int(rtrestaurantPrice.Int64),
-			PositionLat:           rtPositionLat.Float64,
-			PositionLong:          rtPositionLong.Float64,
-			RestaurantCity:        rtrestaurantCity.String,
-			RestaurantImage:       rtrestaurantImage.String,
-			RestaurantDescription: rtrestaurantDescription.String,
-			CreatedAt:             rtCreatedAt.Time,
+		restaurant := model.NearbyRestaurant{
+			Restaurant: model.Restaurant{
+				RestaurantID:          rtrestaurantID.Int64,
+				RestaurantName:        rtrestaurantName.String,
+				RestaurantTimeMinute:  int(rtrestaurantTimeMinute.Int64),
+				RestaurantPrice:       int(rtrestaurantPrice.Int64),
+				PositionLat:           rtPositionLat.Float64,
+				PositionLong:          rtPositionLong.Float64,
+				RestaurantCity:        rtrestaurantCity.String,
+				RestaurantImage:       rtrestaurantImage.String,
+				RestaurantDescription: rtrestaurantDescription.String,
+				CreatedAt:             rtCreatedAt.Time,
+				UpdatedAt:             rtUpdatedAt.Time,
+			},
+			DistanceKM: rtDistanceKM.Float64,
 		}
 
 		restaurants = append(restaurants, &restaurant)
 	}
 
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
 	return restaurants, nil
 }
+
+func (repo *postgreRestaurantRepo) UpdateRestaurant(restaurant *model.Restaurant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			ms_restaurant
+		SET
+			restaurant_name = $2,
+			restaurant_time_minute = $3,
+			restaurant_price = $4,
+			position_lat = $5,
+			position_long = $6,
+			restaurant_city = $7,
+			restaurant_image = $8,
+			restaurant_description = $9,
+			updated_at = now()
+		WHERE
+			restaurant_id = $1 AND
+			deleted_at IS NULL
+	`
+
+	result, err := repo.DB.Writer(database.ScopeRestaurants).ExecContext(
+		ctx,
+		query,
+		restaurant.RestaurantID,
+		restaurant.RestaurantName,
+		restaurant.RestaurantTimeMinute,
+		restaurant.RestaurantPrice,
+		restaurant.PositionLat,
+		restaurant.PositionLong,
+		restaurant.RestaurantCity,
+		restaurant.RestaurantImage,
+		restaurant.RestaurantDescription,
+	)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	if affected == 0 {
+		return apperror.RestaurantNotExists
+	}
+
+	return nil
+}
+
+func (repo *postgreRestaurantRepo) RestoreRestaurant(restaurantID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		UPDATE
+			ms_restaurant
+		SET
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE
+			restaurant_id = $1 AND
+			deleted_at IS NOT NULL
+	`
+
+	result, err := repo.DB.Writer(database.ScopeRestaurants).ExecContext(ctx, query, restaurantID)
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return apperror.InternalServerError.Wrap(err)
+	}
+
+	if affected == 0 {
+		return apperror.RestaurantNotExists
+	}
+
+	return nil
+}
+
+// PurgeRestaurants hard-deletes restaurants soft-deleted before deletedBefore,
+// along with their swipes.
+func (repo *postgreRestaurantRepo) PurgeRestaurants(deletedBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		WITH purged AS (
+			DELETE FROM
+				ms_restaurant
+			WHERE
+				deleted_at < $1
+			RETURNING
+				restaurant_id
+		), swiped AS (
+			DELETE FROM
+				swipes
+			WHERE
+				venue_type = 'restaurant' AND
+				venue_id IN (SELECT restaurant_id FROM purged)
+		)
+		SELECT
+			count(*)
+		FROM
+			purged
+	`
+
+	var purged int64
+	err := repo.DB.Writer(database.ScopeRestaurants).QueryRowContext(ctx, query, deletedBefore).Scan(&purged)
+	if err != nil {
+		log.Println(err)
+		return 0, apperror.InternalServerError.Wrap(err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/restaurant"
+)
+
+const (
+	KeyRestaurantsGeo = "restaurants:geo"
+
+	geoRebuildTimeout = 5 * time.Minute
+)
+
+// geoRestaurantRepo answers FindNearby from a Redis geo index and keeps the
+// index in step with every write. It falls back to the SQL search in next
+// while the index is being built, after a write to it failed, or while Redis
+// is unavailable. The index is rebuilt from db, the database repository
+// itself, so the rebuild doesn't go through the cache in next and fill it
+// with every restaurant page.
+type geoRestaurantRepo struct {
+	index *geo.Index
+	db    restaurant.RestaurantRepository
+	next  restaurant.RestaurantRepository
+}
+
+func NewGeoRestaurantRepository(index *geo.Index, db restaurant.RestaurantRepository, next restaurant.RestaurantRepository) restaurant.RestaurantRepository {
+	return &geoRestaurantRepo{
+		index: index,
+		db:    db,
+		next:  next,
+	}
+}
+
+func (repo *geoRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant) (int64, error) {
+	restaurantID, err := repo.next.CreateRestaurant(restaurant)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	if err := repo.index.Add(restaurantID, restaurant.PositionLat, restaurant.PositionLong); err != nil {
+		log.Println(err)
+	}
+
+	return restaurantID, nil
+}
+
+func (repo *geoRestaurantRepo) UpdateRestaurant(restaurant *model.Restaurant) error {
+	if err := repo.next.UpdateRestaurant(restaurant); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := repo.index.Add(restaurant.RestaurantID, restaurant.PositionLat, restaurant.PositionLong); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (repo *geoRestaurantRepo) DeleteRestaurantID(restaurantID int64) error {
+	if err := repo.next.DeleteRestaurantID(restaurantID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := repo.index.Remove(restaurantID); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (repo *geoRestaurantRepo) RestoreRestaurant(restaurantID int64) error {
+	if err := repo.next.RestoreRestaurant(restaurantID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	restaurant, err := repo.next.FindRestaurantByID(restaurantID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	if err := repo.index.Add(restaurant.RestaurantID, restaurant.PositionLat, restaurant.PositionLong); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+func (repo *geoRestaurantRepo) FindRestaurantByID(restaurantID int64) (*model.Restaurant, error) {
+	return repo.next.FindRestaurantByID(restaurantID)
+}
+
+func (repo *geoRestaurantRepo) FindRestaurantsByIDs(restaurantIDs []int64) (model.Restaurants, error) {
+	return repo.next.FindRestaurantsByIDs(restaurantIDs)
+}
+
+func (repo *geoRestaurantRepo) FindAllRestaurants(query *listing.Query) (*model.RestaurantPage, error) {
+	return repo.next.FindAllRestaurants(query)
+}
+
+func (repo *geoRestaurantRepo) FindByLocation(cityName string, query *listing.Query) (*model.RestaurantPage, error) {
+	return repo.next.FindByLocation(cityName, query)
+}
+
+func (repo *geoRestaurantRepo) PurgeRestaurants(deletedBefore time.Time) (int64, error) {
+	return repo.next.PurgeRestaurants(deletedBefore)
+}
+
+// FindNearby loads the hits in one FindRestaurantsByIDs call, so a hit the
+// index still holds for a deleted restaurant is dropped from the result and
+// from the index.
+func (repo *geoRestaurantRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error) {
+	ready, err := repo.index.Ready()
+	if err != nil {
+		log.Println(err)
+		return repo.next.FindNearby(lat, long, radiusKM, limit)
+	}
+
+	if !ready {
+		go repo.rebuildIndex()
+		return repo.next.FindNearby(lat, long, radiusKM, limit)
+	}
+
+	hits, err := repo.index.Search(lat, long, radiusKM, limit)
+	if err != nil {
+		log.Println(err)
+		return repo.next.FindNearby(lat, long, radiusKM, limit)
+	}
+
+	restaurantIDs := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		restaurantIDs = append(restaurantIDs, hit.ID)
+	}
+
+	found, err := repo.next.FindRestaurantsByIDs(restaurantIDs)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	byID := make(map[int64]*model.Restaurant, len(found))
+	for _, restaurant := range found {
+		byID[restaurant.RestaurantID] = restaurant
+	}
+
+	restaurants := make(model.NearbyRestaurants, 0, len(hits))
+	for _, hit := range hits {
+		restaurant, ok := byID[hit.ID]
+		if !ok {
+			repo.index.Remove(hit.ID)
+			continue
+		}
+
+		restaurants = append(restaurants, &model.NearbyRestaurant{
+			Restaurant: *restaurant,
+			DistanceKM: hit.DistanceKM,
+		})
+	}
+
+	return restaurants, nil
+}
+
+// rebuildIndex adds every restaurant in db to the index, then marks it ready.
+// Only one instance rebuilds at a time; writes meanwhile keep updating the
+// index as usual. If an Add fails the index is left unready, and the next
+// search after the lock expires tries again.
+func (repo *geoRestaurantRepo) rebuildIndex() {
+	locked, err := repo.index.LockRebuild(geoRebuildTimeout)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !locked {
+		return
+	}
+
+	query := &listing.Query{Limit: listing.MaxLimit, Sort: "id"}
+	for {
+		page, err := repo.db.FindAllRestaurants(query)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		for _, restaurant := range page.Restaurants {
+			if err := repo.index.Add(restaurant.RestaurantID, restaurant.PositionLat, restaurant.PositionLong); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		last := page.Restaurants[len(page.Restaurants)-1]
+		query.After = &listing.Cursor{
+			Sort:  query.Sort,
+			Value: strconv.FormatInt(last.RestaurantID, 10),
+			ID:    last.RestaurantID,
+		}
+	}
+
+	if err := repo.index.MarkReady(); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/geo"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/common/redistest"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/restaurant"
+)
+
+// fakeRestaurantRepo serves a fixed set of restaurants. FindNearby answers
+// with fallback, so tests can tell when the SQL search was used.
+type fakeRestaurantRepo struct {
+	restaurant.RestaurantRepository
+
+	restaurants map[int64]*model.Restaurant
+	fallback    model.NearbyRestaurants
+	listed      int32
+}
+
+func (repo *fakeRestaurantRepo) FindAllRestaurants(query *listing.Query) (*model.RestaurantPage, error) {
+	atomic.AddInt32(&repo.listed, 1)
+
+	page := &model.RestaurantPage{Restaurants: model.Restaurants{}}
+	for _, restaurant := range repo.restaurants {
+		page.Restaurants = append(page.Restaurants, restaurant)
+	}
+	return page, nil
+}
+
+func (repo *fakeRestaurantRepo) FindRestaurantsByIDs(restaurantIDs []int64) (model.Restaurants, error) {
+	restaurants := model.Restaurants{}
+	for _, restaurantID := range restaurantIDs {
+		if restaurant, ok := repo.restaurants[restaurantID]; ok {
+			restaurants = append(restaurants, restaurant)
+		}
+	}
+	return restaurants, nil
+}
+
+func (repo *fakeRestaurantRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error) {
+	return repo.fallback, nil
+}
+
+func TestGeoRestaurantRepoFindNearby(t *testing.T) {
+	pool, mr := redistest.NewPool(t)
+	index := geo.NewIndex(cache.NewStore(pool, time.Minute, 1, time.Minute), KeyRestaurantsGeo)
+
+	restaurants := map[int64]*model.Restaurant{
+		1: {RestaurantID: 1, PositionLat: -6.9, PositionLong: 107.609},
+		2: {RestaurantID: 2, PositionLat: -6.9, PositionLong: 107.618},
+	}
+	fallback := model.NearbyRestaurants{{Restaurant: model.Restaurant{RestaurantID: 99}}}
+	db := &fakeRestaurantRepo{restaurants: restaurants, fallback: fallback}
+	cached := &fakeRestaurantRepo{restaurants: map[int64]*model.Restaurant{1: restaurants[1], 2: restaurants[2]}, fallback: fallback}
+	repo := NewGeoRestaurantRepository(index, db, cached)
+
+	isFallback := func(found model.NearbyRestaurants) bool {
+		return len(found) == 1 && found[0].RestaurantID == 99
+	}
+
+	// Until the index is built the SQL search answers, and the rebuild
+	// starts in the background.
+	found, err := repo.FindNearby(-6.9, 107.6, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isFallback(found) {
+		t.Errorf("FindNearby() on an unbuilt index = %v, want the SQL search", found)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for ready, _ := index.Ready(); !ready; ready, _ = index.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("index never marked ready")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&db.listed) == 0 || atomic.LoadInt32(&cached.listed) != 0 {
+		t.Errorf("rebuild listed %d pages from the database and %d through the cache, want the database only", db.listed, cached.listed)
+	}
+
+	// A restaurant deleted since the rebuild is dropped from the result and
+	// from the index.
+	delete(cached.restaurants, 2)
+	found, err = repo.FindNearby(-6.9, 107.6, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].RestaurantID != 1 || found[0].DistanceKM == 0 {
+		t.Errorf("FindNearby() = %v, want restaurant 1 with its distance", found)
+	}
+	if members, _ := mr.ZMembers(KeyRestaurantsGeo); len(members) != 1 {
+		t.Errorf("index members = %v, want the deleted restaurant removed", members)
+	}
+
+	mr.Close()
+	if found, err := repo.FindNearby(-6.9, 107.6, 10, 10); err != nil || !isFallback(found) {
+		t.Errorf("FindNearby() without redis = %v, %v, want the SQL search", found, err)
+	}
+}
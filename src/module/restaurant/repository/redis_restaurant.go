@@ -1,71 +1,142 @@
 package repository
 
 import (
+	"fmt"
 	"log"
+	"time"
 
-	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/cache"
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 	"github.com/atletaid/go-template/src/module/restaurant"
-	redigo "github.com/gomodule/redigo/redis"
-	cache "github.com/patrickmn/go-cache"
 )
 
 const (
-	KeyRestaurantsFindAll = "restaurants:find_all"
-	KeyRestaurantsFind    = "restaurants:find"
+	KeyRestaurantsFindAll        = "restaurants:find_all"
+	KeyRestaurantsFind           = "restaurants:find"
+	KeyRestaurantsFindByLocation = "restaurants:find_by_location"
 )
 
 type redisRestaurantRepo struct {
-	cache map[string]*cache.Cache
-	pool  *redigo.Pool
-	next  restaurant.RestaurantRepository
+	find           *cache.Namespace
+	findAll        *cache.Namespace
+	findByLocation *cache.Namespace
+	next           restaurant.RestaurantRepository
 }
 
-func NewMiddlewareRestaurantRepository(cache map[string]*cache.Cache, pool *redigo.Pool, next restaurant.RestaurantRepository) restaurant.RestaurantRepository {
+func NewMiddlewareRestaurantRepository(store *cache.Store, localExpiration, remoteExpiration, staleExpiration time.Duration, next restaurant.RestaurantRepository) restaurant.RestaurantRepository {
 	return &redisRestaurantRepo{
-		cache: cache,
-		pool:  pool,
-		next:  next,
+		find: store.Namespace(KeyRestaurantsFind, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.Restaurant)
+		})),
+		findAll: store.Namespace(KeyRestaurantsFindAll, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.RestaurantPage)
+		})).WithStaleWhileRevalidate(staleExpiration),
+		findByLocation: store.Namespace(KeyRestaurantsFindByLocation, localExpiration, remoteExpiration, cache.NewJSONSerializer(func() interface{} {
+			return new(*model.RestaurantPage)
+		})),
+		next: next,
 	}
 }
 
-func (repo *redisRestaurantRepo) do(command string, args ...interface{}) (reply interface{}, err error) {
-	conn := repo.pool.Get()
-	defer conn.Close()
-
-	return conn.Do(command, args...)
+func (repo *redisRestaurantRepo) clearAllFindListCache() {
+	repo.findAll.InvalidateAll()
+	repo.findByLocation.InvalidateAll()
 }
 
-func (repo *redisRestaurantRepo) clearAllFindListCache() error {
-	keys := []interface{}{
-		KeyRestaurantsFindAll,
+func (repo *redisRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant) (int64, error) {
+	lastID, err := repo.next.CreateRestaurant(restaurant)
+	if err != nil {
+		log.Println(err)
+		return 0, err
 	}
 
-	if _, err := repo.do("DEL", keys...); err != nil {
+	repo.clearAllFindListCache()
+	return lastID, nil
+}
+
+func (repo *redisRestaurantRepo) FindRestaurantByID(restaurantID int64) (*model.Restaurant, error) {
+	restaurant, err := repo.find.GetOrLoad(fmt.Sprintf("%v", restaurantID), func() (interface{}, error) {
+		return repo.next.FindRestaurantByID(restaurantID)
+	})
+	if err != nil {
 		log.Println(err)
-		return apperror.InternalServerError
+		return nil, err
 	}
 
-	repo.cache[KeyRestaurantsFindAll].Flush()
-	return nil
+	return restaurant.(*model.Restaurant), nil
 }
 
-func (repo *redisRestaurantRepo) CreateRestaurant(restaurant *model.Restaurant) (int64, error) {
-	return repo.next.CreateRestaurant(restaurant)
+func (repo *redisRestaurantRepo) FindAllRestaurants(query *listing.Query) (*model.RestaurantPage, error) {
+	page, err := repo.findAll.GetOrLoad(query.Key(), func() (interface{}, error) {
+		return repo.next.FindAllRestaurants(query)
+	})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page.(*model.RestaurantPage), nil
 }
 
-func (repo *redisRestaurantRepo) FindRestaurantByID(restaurantID int64) (*model.Restaurant, error) {
-	return repo.next.FindRestaurantByID(restaurantID)
+func (repo *redisRestaurantRepo) FindByLocation(cityName string, query *listing.Query) (*model.RestaurantPage, error) {
+	page, err := repo.findByLocation.GetOrLoad(cityName+"?"+query.Key(), func() (interface{}, error) {
+		return repo.next.FindByLocation(cityName, query)
+	})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page.(*model.RestaurantPage), nil
 }
 
-func (repo *redisRestaurantRepo) FindAllRestaurants() (model.Restaurants, error) {
-	return repo.next.FindAllRestaurants()
+// FindRestaurantsByIDs isn't cached: it backs FindNearby, which isn't
+// either.
+func (repo *redisRestaurantRepo) FindRestaurantsByIDs(restaurantIDs []int64) (model.Restaurants, error) {
+	return repo.next.FindRestaurantsByIDs(restaurantIDs)
 }
 
-func (repo *redisRestaurantRepo) FindByLocation(cityName string) (model.Restaurants, error) {
-	return repo.next.FindByLocation(cityName)
+// FindNearby isn't cached: coordinates hardly ever repeat exactly.
+func (repo *redisRestaurantRepo) FindNearby(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error) {
+	return repo.next.FindNearby(lat, long, radiusKM, limit)
 }
 
 func (repo *redisRestaurantRepo) DeleteRestaurantID(restaurantID int64) error {
-	return repo.next.DeleteRestaurantID(restaurantID)
+	if err := repo.next.DeleteRestaurantID(restaurantID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	repo.clearAllFindListCache()
+	repo.find.Invalidate(fmt.Sprintf("%v", restaurantID))
+	return nil
+}
+
+func (repo *redisRestaurantRepo) UpdateRestaurant(restaurant *model.Restaurant) error {
+	if err := repo.next.UpdateRestaurant(restaurant); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	repo.clearAllFindListCache()
+	repo.find.Invalidate(fmt.Sprintf("%v", restaurant.RestaurantID))
+	return nil
+}
+
+func (repo *redisRestaurantRepo) RestoreRestaurant(restaurantID int64) error {
+	if err := repo.next.RestoreRestaurant(restaurantID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	repo.clearAllFindListCache()
+	repo.find.Invalidate(fmt.Sprintf("%v", restaurantID))
+	return nil
+}
+
+// PurgeRestaurants leaves the cache alone: purged restaurants were already
+// invalidated when they were deleted.
+func (repo *redisRestaurantRepo) PurgeRestaurants(deletedBefore time.Time) (int64, error) {
+	return repo.next.PurgeRestaurants(deletedBefore)
 }
@@ -2,16 +2,22 @@ package restaurant
 
 import (
 	"log"
+	"time"
 
+	"github.com/atletaid/go-template/src/common/listing"
 	"github.com/atletaid/go-template/src/model"
 )
 
 type Usecase interface {
 	CreateRestaurant(restaurantName, restaurantCity, restaurantImage, restaurantDescription string, restaurantTime, restaurantPrice int, positionLat, positionLong float64) (int64, error)
+	UpdateRestaurant(restaurantID int64, restaurantName, restaurantCity, restaurantImage, restaurantDescription string, restaurantTime, restaurantPrice int, positionLat, positionLong float64) error
 	GetRestaurant(restaurantID int64) (*model.Restaurant, error)
-	GetAllRestaurants() (model.Restaurants, error)
-	GetRestaurantsByCity(cityName string) (model.Restaurants, error)
+	GetAllRestaurants(query *listing.Query) (*model.RestaurantPage, error)
+	GetRestaurantsByCity(cityName string, query *listing.Query) (*model.RestaurantPage, error)
+	GetNearbyRestaurants(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error)
 	DeleteRestaurantByID(restaurantID int64) error
+	RestoreRestaurantByID(restaurantID int64) error
+	PurgeDeletedRestaurants(deletedBefore time.Time) (int64, error)
 }
 
 type usecase struct {
@@ -37,14 +43,14 @@ func (u *usecase) CreateRestaurant(restaurantName, restaurantCity, restaurantIma
 	return restaurantID, nil
 }
 
-func (u *usecase) GetAllRestaurants() (model.Restaurants, error) {
-	restaurants, err := u.restaurantRepo.FindAllRestaurants()
+func (u *usecase) GetAllRestaurants(query *listing.Query) (*model.RestaurantPage, error) {
+	page, err := u.restaurantRepo.FindAllRestaurants(query)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return restaurants, nil
+	return page, nil
 }
 
 func (u *usecase) GetRestaurant(venueID int64) (*model.Restaurant, error) {
@@ -57,8 +63,18 @@ func (u *usecase) GetRestaurant(venueID int64) (*model.Restaurant, error) {
 	return restaurant, nil
 }
 
-func (u *usecase) GetRestaurantsByCity(cityName string) (model.Restaurants, error) {
-	restaurants, err := u.restaurantRepo.FindByLocation(cityName)
+func (u *usecase) GetRestaurantsByCity(cityName string, query *listing.Query) (*model.RestaurantPage, error) {
+	page, err := u.restaurantRepo.FindByLocation(cityName, query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (u *usecase) GetNearbyRestaurants(lat, long, radiusKM float64, limit int) (model.NearbyRestaurants, error) {
+	restaurants, err := u.restaurantRepo.FindNearby(lat, long, radiusKM, limit)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -80,3 +96,42 @@ func (u *usecase) DeleteRestaurantByID(restaurantID int64) error {
 
 	return nil
 }
+
+// UpdateRestaurant replaces every editable field of the restaurant.
+func (u *usecase) UpdateRestaurant(restaurantID int64, restaurantName, restaurantCity, restaurantImage, restaurantDescription string, restaurantTime, restaurantPrice int, positionLat, positionLong float64) error {
+	current, err := u.restaurantRepo.FindRestaurantByID(restaurantID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	updated := model.NewRestaurant(restaurantName, restaurantCity, restaurantImage, restaurantDescription, restaurantTime, restaurantPrice, positionLat, positionLong)
+	updated.RestaurantID = current.RestaurantID
+	updated.CreatedAt = current.CreatedAt
+
+	if err := u.restaurantRepo.UpdateRestaurant(updated); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (u *usecase) RestoreRestaurantByID(restaurantID int64) error {
+	if err := u.restaurantRepo.RestoreRestaurant(restaurantID); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (u *usecase) PurgeDeletedRestaurants(deletedBefore time.Time) (int64, error) {
+	purged, err := u.restaurantRepo.PurgeRestaurants(deletedBefore)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	return purged, nil
+}
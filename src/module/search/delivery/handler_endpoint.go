@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"log"
+	"time"
+
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/search"
+	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	su search.Usecase
+}
+
+func NewSearchHandler(router *gin.Engine, su search.Usecase) *gin.Engine {
+	handler := &SearchHandler{su}
+
+	v1 := router.Group("/api/v1")
+	v1.GET("/search", handler.SearchEndpoint())
+
+	return router
+}
+
+type searchRequest struct {
+	Query string `json:"q" form:"q" binding:"required,min=2,max=100"`
+	Limit int    `json:"limit" form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+type dataSearchResponse struct {
+	Results model.SearchResults `json:"results"`
+}
+
+// SearchEndpoint searches restaurants and recreations together, best match
+// first. Each result's type names the module it came from.
+func (h *SearchHandler) SearchEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := searchRequest{}
+		if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		if req.Limit == 0 {
+			req.Limit = 20
+		}
+
+		results, err := h.su.Search(req.Query, req.Limit)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		resp := dataSearchResponse{
+			Results: results,
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success search"}, processTime, resp)
+	}
+}
@@ -0,0 +1,9 @@
+package search
+
+import (
+	"github.com/atletaid/go-template/src/model"
+)
+
+type SearchRepository interface {
+	Search(text string, limit int) (model.SearchResults, error)
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/search"
+	"github.com/lib/pq"
+)
+
+type postgreSearchRepo struct {
+	DB      *database.DB
+	Timeout time.Duration
+}
+
+func NewSearchRepository(db *database.DB, timeout time.Duration) search.SearchRepository {
+	return &postgreSearchRepo{
+		DB:      db,
+		Timeout: timeout,
+	}
+}
+
+// Search matches text against the full-text vectors of both venue tables,
+// stemmed as Indonesian, and against venue names by trigram similarity so
+// that misspelled words still hit. The score adds the two.
+func (repo *postgreSearchRepo) Search(text string, limit int) (model.SearchResults, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+	WITH q AS (
+		SELECT websearch_to_tsquery('indonesian', $1) AS query
+	)
+	SELECT * FROM (
+		SELECT
+			'restaurant' AS venue_type,
+			restaurant_id AS venue_id,
+			restaurant_name,
+			restaurant_time_minute,
+			restaurant_price,
+			position_lat,
+			position_long,
+			restaurant_city,
+			restaurant_image,
+			restaurant_description,
+			created_at,
+			updated_at,
+			ts_rank(search_vector, q.query) + word_similarity($1, restaurant_name) AS score
+		FROM
+			ms_restaurant, q
+		WHERE
+			deleted_at IS NULL AND
+			(search_vector @@ q.query OR $1 <% restaurant_name)
+		UNION ALL
+		SELECT
+			'recreation' AS venue_type,
+			recreation_id AS venue_id,
+			recreation_name,
+			recreation_time_minute,
+			recreation_price,
+			position_lat,
+			position_long,
+			recreation_city,
+			recreation_image,
+			recreation_description,
+			created_at,
+			updated_at,
+			ts_rank(search_vector, q.query) + word_similarity($1, recreation_name) AS score
+		FROM
+			ms_recreation, q
+		WHERE
+			deleted_at IS NULL AND
+			(search_vector @@ q.query OR $1 <% recreation_name)
+	) hits
+	ORDER BY
+		score DESC,
+		venue_type,
+		venue_id
+	LIMIT $2
+	`
+
+	rows, err := repo.DB.Reader(database.ScopeRestaurants, database.ScopeRecreations).QueryContext(ctx, query, text, limit)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	results := make(model.SearchResults, 0)
+	for rows.Next() {
+		var (
+			vType         sql.NullString
+			vID           sql.NullInt64
+			vName         sql.NullString
+			vTimeMinute   sql.NullInt64
+			vPrice        sql.NullInt64
+			vPositionLat  sql.NullFloat64
+			vPositionLong sql.NullFloat64
+			vCity         sql.NullString
+			vImage        sql.NullString
+			vDescription  sql.NullString
+			vCreatedAt    pq.NullTime
+			vUpdatedAt    pq.NullTime
+			vScore        sql.NullFloat64
+		)
+
+		if err := rows.Scan(
+			&vType,
+			&vID,
+			&vName,
+			&vTimeMinute,
+			&vPrice,
+			&vPositionLat,
+			&vPositionLong,
+			&vCity,
+			&vImage,
+			&vDescription,
+			&vCreatedAt,
+			&vUpdatedAt,
+			&vScore,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		result := model.SearchResult{
+			Type:  vType.String,
+			Score: vScore.Float64,
+		}
+
+		switch result.Type {
+		case model.VenueTypeRestaurant:
+			result.Restaurant = &model.Restaurant{
+				RestaurantID:          vID.Int64,
+				RestaurantName:        vName.String,
+				RestaurantTimeMinute:  int(vTimeMinute.Int64),
+				RestaurantPrice:       int(vPrice.Int64),
+				PositionLat:           vPositionLat.Float64,
+				PositionLong:          vPositionLong.Float64,
+				RestaurantCity:        vCity.String,
+				RestaurantImage:       vImage.String,
+				RestaurantDescription: vDescription.String,
+				CreatedAt:             vCreatedAt.Time,
+				UpdatedAt:             vUpdatedAt.Time,
+			}
+		case model.VenueTypeRecreation:
+			result.Recreation = &model.Recreation{
+				RecreationID:          vID.Int64,
+				RecreationName:        vName.String,
+				RecreationTimeMinute:  int(vTimeMinute.Int64),
+				RecreationPrice:       int(vPrice.Int64),
+				PositionLat:           vPositionLat.Float64,
+				PositionLong:          vPositionLong.Float64,
+				RecreationCity:        vCity.String,
+				RecreationImage:       vImage.String,
+				RecreationDescription: vDescription.String,
+				CreatedAt:             vCreatedAt.Time,
+				UpdatedAt:             vUpdatedAt.Time,
+			}
+		}
+
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,33 @@
+package search
+
+import (
+	"log"
+
+	"github.com/atletaid/go-template/src/model"
+)
+
+type Usecase interface {
+	Search(text string, limit int) (model.SearchResults, error)
+}
+
+type usecase struct {
+	searchRepo SearchRepository
+}
+
+func NewSearchUsecase(
+	searchRepo SearchRepository,
+) Usecase {
+	return &usecase{
+		searchRepo: searchRepo,
+	}
+}
+
+func (u *usecase) Search(text string, limit int) (model.SearchResults, error) {
+	results, err := u.searchRepo.Search(text, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return results, nil
+}
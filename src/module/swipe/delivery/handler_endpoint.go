@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"log"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/auth"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/module/swipe"
+	"github.com/atletaid/go-template/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+type SwipeHandler struct {
+	su swipe.Usecase
+}
+
+func NewSwipeHandler(router *gin.Engine, m *auth.Middleware, su swipe.Usecase) *gin.Engine {
+	handler := &SwipeHandler{su}
+
+	v1 := router.Group("/api/v1", m.AuthUserToken(), m.RequireVerified())
+	v1.POST("/swipe", handler.SwipeEndpoint())
+	v1.POST("/swipe/undo", handler.UndoSwipeEndpoint())
+	v1.GET("/swipes/liked/restaurants", handler.GetLikedRestaurantsEndpoint())
+	v1.GET("/swipes/liked/recreations", handler.GetLikedRecreationsEndpoint())
+
+	return router
+}
+
+type swipeRequest struct {
+	VenueType string `json:"venue_type" form:"venue_type" binding:"required,eq=restaurant|eq=recreation"`
+	VenueID   int64  `json:"venue_id" form:"venue_id" binding:"required,min=1"`
+	Direction string `json:"direction" form:"direction" binding:"required,eq=like|eq=dislike|eq=superlike"`
+}
+
+// SwipeEndpoint records the caller's swipe on a venue. Sending the same
+// swipe again changes nothing.
+func (h *SwipeHandler) SwipeEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		req := swipeRequest{}
+		if err := httputil.DecodeFormRequest(c.Request, &req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteDecodeErrorResponse(c, processTime, &req)
+			return
+		}
+
+		if err := httputil.ValidateRequest(&req); err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		swiped, err := h.su.Swipe(accountID, req.VenueType, req.VenueID, req.Direction)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success swipe"}, processTime, swiped)
+	}
+}
+
+// UndoSwipeEndpoint reverts the caller's latest swipe, back to its previous
+// direction if it changed one, and returns it as it was.
+func (h *SwipeHandler) UndoSwipeEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		accountID, _ := auth.AccountID(c)
+		undone, err := h.su.UndoLastSwipe(accountID)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success undo swipe"}, processTime, undone)
+	}
+}
+
+// decodeLikedQuery reads the list query parameters, newest swipe first
+// unless another sort is asked for.
+func decodeLikedQuery(c *gin.Context) (*listing.Query, error) {
+	req := listing.Request{}
+	if err := httputil.DecodeQueryRequest(c.Request, &req); err != nil {
+		return nil, apperror.DecodeError.Wrap(err)
+	}
+
+	if err := httputil.ValidateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	if req.Sort == "" {
+		req.Sort = "-swiped_at"
+	}
+
+	return req.Query(swipe.LikedSorts, swipe.LikedFilters...)
+}
+
+func (h *SwipeHandler) GetLikedRestaurantsEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		query, err := decodeLikedQuery(c)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		page, err := h.su.GetLikedRestaurants(accountID, query)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get liked restaurants"}, processTime, page)
+	}
+}
+
+func (h *SwipeHandler) GetLikedRecreationsEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		query, err := decodeLikedQuery(c)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		accountID, _ := auth.AccountID(c)
+		page, err := h.su.GetLikedRecreations(accountID, query)
+		if err != nil {
+			log.Println(err)
+			processTime := time.Now().Sub(startTime).Seconds()
+			httputil.WriteErrorResponse(c, processTime, err)
+			return
+		}
+
+		processTime := time.Now().Sub(startTime).Seconds()
+		httputil.WriteResponse(c, []string{"Success get liked recreations"}, processTime, page)
+	}
+}
@@ -0,0 +1,20 @@
+package swipe
+
+import (
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/model"
+)
+
+// LikedSorts and LikedFilters are what liked venue list queries may sort
+// and filter on.
+var (
+	LikedSorts   = []string{"swiped_at", "name", "price", "time"}
+	LikedFilters = []string{listing.FilterCity, listing.FilterPrice, listing.FilterTime}
+)
+
+type SwipeRepository interface {
+	SaveSwipe(swipe *model.Swipe) (*model.Swipe, error)
+	UndoLatestSwipe(accountID int64) (*model.Swipe, error)
+	FindLikedRestaurants(accountID int64, query *listing.Query) (*model.LikedRestaurantPage, error)
+	FindLikedRecreations(accountID int64, query *listing.Query) (*model.LikedRecreationPage, error)
+}
@@ -0,0 +1,482 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/swipe"
+	"github.com/lib/pq"
+)
+
+type postgreSwipeRepo struct {
+	DB      *database.DB
+	Timeout time.Duration
+}
+
+func NewSwipeRepository(db *database.DB, timeout time.Duration) swipe.SwipeRepository {
+	return &postgreSwipeRepo{
+		DB:      db,
+		Timeout: timeout,
+	}
+}
+
+// SaveSwipe inserts the swipe or, if the account already swiped the venue,
+// sets its direction, keeping the one it replaces for undo. updated_at only
+// moves when the direction changes, so a repeated request doesn't make the
+// swipe the latest one again.
+func (repo *postgreSwipeRepo) SaveSwipe(swipe *model.Swipe) (*model.Swipe, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO
+			swipes
+		(
+			account_id,
+			venue_type,
+			venue_id,
+			direction,
+			created_at,
+			updated_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			now(),
+			now()
+		)
+		ON CONFLICT (account_id, venue_type, venue_id) DO UPDATE SET
+			direction = EXCLUDED.direction,
+			previous_direction = CASE
+				WHEN swipes.direction = EXCLUDED.direction THEN swipes.previous_direction
+				ELSE swipes.direction
+			END,
+			previous_updated_at = CASE
+				WHEN swipes.direction = EXCLUDED.direction THEN swipes.previous_updated_at
+				ELSE swipes.updated_at
+			END,
+			updated_at = CASE
+				WHEN swipes.direction = EXCLUDED.direction THEN swipes.updated_at
+				ELSE now()
+			END
+		RETURNING
+			swipe_id,
+			account_id,
+			venue_type,
+			venue_id,
+			direction,
+			created_at,
+			updated_at
+	`
+
+	row := repo.DB.Writer(database.ScopeSwipes(swipe.AccountID)).QueryRowContext(
+		ctx,
+		query,
+		swipe.AccountID,
+		swipe.VenueType,
+		swipe.VenueID,
+		swipe.Direction,
+	)
+
+	saved, err := scanSwipe(row)
+	if err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return saved, nil
+}
+
+// UndoLatestSwipe reverts the account's most recent swipe and returns it as
+// it was before the undo. A re-swipe gets back the direction it replaced; a
+// first swipe of the venue is deleted.
+func (repo *postgreSwipeRepo) UndoLatestSwipe(accountID int64) (*model.Swipe, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	query := `
+		WITH latest AS (
+			SELECT
+				swipe_id,
+				account_id,
+				venue_type,
+				venue_id,
+				direction,
+				previous_direction,
+				created_at,
+				updated_at
+			FROM
+				swipes
+			WHERE
+				account_id = $1
+			ORDER BY
+				updated_at DESC,
+				swipe_id DESC
+			LIMIT 1
+			FOR UPDATE
+		), restored AS (
+			UPDATE
+				swipes s
+			SET
+				direction = s.previous_direction,
+				updated_at = s.previous_updated_at,
+				previous_direction = NULL,
+				previous_updated_at = NULL
+			FROM
+				latest
+			WHERE
+				s.swipe_id = latest.swipe_id AND
+				latest.previous_direction IS NOT NULL
+		), deleted AS (
+			DELETE FROM
+				swipes s
+			USING
+				latest
+			WHERE
+				s.swipe_id = latest.swipe_id AND
+				latest.previous_direction IS NULL
+		)
+		SELECT
+			swipe_id,
+			account_id,
+			venue_type,
+			venue_id,
+			direction,
+			created_at,
+			updated_at
+		FROM
+			latest
+	`
+
+	deleted, err := scanSwipe(repo.DB.Writer(database.ScopeSwipes(accountID)).QueryRowContext(ctx, query, accountID))
+	if err == sql.ErrNoRows {
+		return nil, apperror.SwipeNotExists
+	}
+
+	if err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	return deleted, nil
+}
+
+func scanSwipe(row *sql.Row) (*model.Swipe, error) {
+	var (
+		sSwipeID   sql.NullInt64
+		sAccountID sql.NullInt64
+		sVenueType sql.NullString
+		sVenueID   sql.NullInt64
+		sDirection sql.NullString
+		sCreatedAt pq.NullTime
+		sUpdatedAt pq.NullTime
+	)
+
+	if err := row.Scan(
+		&sSwipeID,
+		&sAccountID,
+		&sVenueType,
+		&sVenueID,
+		&sDirection,
+		&sCreatedAt,
+		&sUpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &model.Swipe{
+		SwipeID:   sSwipeID.Int64,
+		AccountID: sAccountID.Int64,
+		VenueType: sVenueType.String,
+		VenueID:   sVenueID.Int64,
+		Direction: sDirection.String,
+		CreatedAt: sCreatedAt.Time,
+		UpdatedAt: sUpdatedAt.Time,
+	}, nil
+}
+
+var likedRestaurantColumns = listing.Columns{
+	ID:         "rt.restaurant_id",
+	City:       "rt.restaurant_city",
+	Price:      "rt.restaurant_price",
+	TimeMinute: "rt.restaurant_time_minute",
+	Sorts: map[string]string{
+		"swiped_at": "s.updated_at",
+		"name":      "rt.restaurant_name",
+		"price":     "rt.restaurant_price",
+		"time":      "rt.restaurant_time_minute",
+	},
+}
+
+func likedRestaurantSortValue(restaurant *model.LikedRestaurant, sort string) string {
+	switch sort {
+	case "name":
+		return restaurant.RestaurantName
+	case "price":
+		return strconv.Itoa(restaurant.RestaurantPrice)
+	case "time":
+		return strconv.Itoa(restaurant.RestaurantTimeMinute)
+	}
+	return listing.TimeValue(restaurant.SwipedAt)
+}
+
+// FindLikedRestaurants lists the live restaurants the account liked or
+// superliked.
+func (repo *postgreSwipeRepo) FindLikedRestaurants(accountID int64, listQuery *listing.Query) (*model.LikedRestaurantPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	clauses, args := listQuery.SQL(likedRestaurantColumns, []string{
+		"s.account_id = $1",
+		"s.venue_type = 'restaurant'",
+		"s.direction IN ('like', 'superlike')",
+		"rt.deleted_at IS NULL",
+	}, []interface{}{accountID})
+	query := `
+	SELECT
+		rt.restaurant_id,
+		rt.restaurant_name,
+		rt.restaurant_time_minute,
+		rt.restaurant_price,
+		rt.position_lat,
+		rt.position_long,
+		rt.restaurant_city,
+		rt.restaurant_image,
+		rt.restaurant_description,
+		rt.created_at,
+		rt.updated_at,
+		s.direction,
+		s.updated_at
+	FROM
+		swipes s
+	JOIN
+		ms_restaurant rt ON rt.restaurant_id = s.venue_id
+	` + clauses
+
+	rows, err := repo.DB.Reader(database.ScopeSwipes(accountID)).QueryContext(ctx, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	restaurants := make([]*model.LikedRestaurant, 0)
+	for rows.Next() {
+		var (
+			rtrestaurantID          sql.NullInt64
+			rtrestaurantName        sql.NullString
+			rtrestaurantTimeMinute  sql.NullInt64
+			rtrestaurantPrice       sql.NullInt64
+			rtPositionLat           sql.NullFloat64
+			rtPositionLong          sql.NullFloat64
+			rtrestaurantCity        sql.NullString
+			rtrestaurantImage       sql.NullString
+			rtrestaurantDescription sql.NullString
+			rtCreatedAt             pq.NullTime
+			rtUpdatedAt             pq.NullTime
+			sDirection              sql.NullString
+			sSwipedAt               pq.NullTime
+		)
+
+		if err := rows.Scan(
+			&rtrestaurantID,
+			&rtrestaurantName,
+			&rtrestaurantTimeMinute,
+			&rtrestaurantPrice,
+			&rtPositionLat,
+			&rtPositionLong,
+			&rtrestaurantCity,
+			&rtrestaurantImage,
+			&rtrestaurantDescription,
+			&rtCreatedAt,
+			&rtUpdatedAt,
+			&sDirection,
+			&sSwipedAt,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		restaurant := model.LikedRestaurant{
+			Restaurant: model.Restaurant{
+				RestaurantID:          rtrestaurantID.Int64,
+				RestaurantName:        rtrestaurantName.String,
+				RestaurantTimeMinute:  int(rtrestaurantTimeMinute.Int64),
+				RestaurantPrice:       int(rtrestaurantPrice.Int64),
+				PositionLat:           rtPositionLat.Float64,
+				PositionLong:          rtPositionLong.Float64,
+				RestaurantCity:        rtrestaurantCity.String,
+				RestaurantImage:       rtrestaurantImage.String,
+				RestaurantDescription: rtrestaurantDescription.String,
+				CreatedAt:             rtCreatedAt.Time,
+				UpdatedAt:             rtUpdatedAt.Time,
+			},
+			Direction: sDirection.String,
+			SwipedAt:  sSwipedAt.Time,
+		}
+
+		restaurants = append(restaurants, &restaurant)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	page := &model.LikedRestaurantPage{Restaurants: restaurants}
+	if len(restaurants) > listQuery.Limit {
+		page.Restaurants = restaurants[:listQuery.Limit]
+		last := page.Restaurants[listQuery.Limit-1]
+		page.NextCursor = listQuery.NextCursor(likedRestaurantSortValue(last, listQuery.Sort), last.RestaurantID)
+	}
+
+	return page, nil
+}
+
+var likedRecreationColumns = listing.Columns{
+	ID:         "r.recreation_id",
+	City:       "r.recreation_city",
+	Price:      "r.recreation_price",
+	TimeMinute: "r.recreation_time_minute",
+	Sorts: map[string]string{
+		"swiped_at": "s.updated_at",
+		"name":      "r.recreation_name",
+		"price":     "r.recreation_price",
+		"time":      "r.recreation_time_minute",
+	},
+}
+
+func likedRecreationSortValue(recreation *model.LikedRecreation, sort string) string {
+	switch sort {
+	case "name":
+		return recreation.RecreationName
+	case "price":
+		return strconv.Itoa(recreation.RecreationPrice)
+	case "time":
+		return strconv.Itoa(recreation.RecreationTimeMinute)
+	}
+	return listing.TimeValue(recreation.SwipedAt)
+}
+
+// FindLikedRecreations lists the live recreations the account liked or
+// superliked.
+func (repo *postgreSwipeRepo) FindLikedRecreations(accountID int64, listQuery *listing.Query) (*model.LikedRecreationPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), repo.Timeout)
+	defer cancel()
+
+	clauses, args := listQuery.SQL(likedRecreationColumns, []string{
+		"s.account_id = $1",
+		"s.venue_type = 'recreation'",
+		"s.direction IN ('like', 'superlike')",
+		"r.deleted_at IS NULL",
+	}, []interface{}{accountID})
+	query := `
+	SELECT
+		r.recreation_id,
+		r.recreation_name,
+		r.recreation_time_minute,
+		r.recreation_price,
+		r.position_lat,
+		r.position_long,
+		r.recreation_city,
+		r.recreation_image,
+		r.recreation_description,
+		r.created_at,
+		r.updated_at,
+		s.direction,
+		s.updated_at
+	FROM
+		swipes s
+	JOIN
+		ms_recreation r ON r.recreation_id = s.venue_id
+	` + clauses
+
+	rows, err := repo.DB.Reader(database.ScopeSwipes(accountID)).QueryContext(ctx, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+	defer rows.Close()
+
+	recreations := make([]*model.LikedRecreation, 0)
+	for rows.Next() {
+		var (
+			rRecreationID          sql.NullInt64
+			rRecreationName        sql.NullString
+			rRecreationTimeMinute  sql.NullInt64
+			rRecreationPrice       sql.NullInt64
+			rPositionLat           sql.NullFloat64
+			rPositionLong          sql.NullFloat64
+			rRecreationCity        sql.NullString
+			rRecreationImage       sql.NullString
+			rRecreationDescription sql.NullString
+			rCreatedAt             pq.NullTime
+			rUpdatedAt             pq.NullTime
+			sDirection             sql.NullString
+			sSwipedAt              pq.NullTime
+		)
+
+		if err := rows.Scan(
+			&rRecreationID,
+			&rRecreationName,
+			&rRecreationTimeMinute,
+			&rRecreationPrice,
+			&rPositionLat,
+			&rPositionLong,
+			&rRecreationCity,
+			&rRecreationImage,
+			&rRecreationDescription,
+			&rCreatedAt,
+			&rUpdatedAt,
+			&sDirection,
+			&sSwipedAt,
+		); err != nil {
+			log.Println(err)
+			return nil, apperror.InternalServerError.Wrap(err)
+		}
+
+		recreation := model.LikedRecreation{
+			Recreation: model.Recreation{
+				RecreationID:          rRecreationID.Int64,
+				RecreationName:        rRecreationName.String,
+				RecreationTimeMinute:  int(rRecreationTimeMinute.Int64),
+				RecreationPrice:       int(rRecreationPrice.Int64),
+				PositionLat:           rPositionLat.Float64,
+				PositionLong:          rPositionLong.Float64,
+				RecreationCity:        rRecreationCity.String,
+				RecreationImage:       rRecreationImage.String,
+				RecreationDescription: rRecreationDescription.String,
+				CreatedAt:             rCreatedAt.Time,
+				UpdatedAt:             rUpdatedAt.Time,
+			},
+			Direction: sDirection.String,
+			SwipedAt:  sSwipedAt.Time,
+		}
+
+		recreations = append(recreations, &recreation)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, apperror.InternalServerError.Wrap(err)
+	}
+
+	page := &model.LikedRecreationPage{Recreations: recreations}
+	if len(recreations) > listQuery.Limit {
+		page.Recreations = recreations[:listQuery.Limit]
+		last := page.Recreations[listQuery.Limit-1]
+		page.NextCursor = listQuery.NextCursor(likedRecreationSortValue(last, listQuery.Sort), last.RecreationID)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/common/database"
+	"github.com/atletaid/go-template/src/common/migration"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/swipe"
+)
+
+// newTestSwipeRepo needs a throwaway Postgres database in TEST_DATABASE_URL,
+// which it migrates. It returns the repository and a new account to swipe
+// with, deleted along with its swipes when the test ends.
+func newTestSwipeRepo(t *testing.T) (swipe.SwipeRepository, *database.DB, int64) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := database.Open(dsn, "", time.Second, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	migrations, err := migration.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migration.NewMigrator(db.Master(), migrations).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var accountID int64
+	email := fmt.Sprintf("swipe-test-%d@example.com", time.Now().UnixNano())
+	if err := db.Master().QueryRowContext(ctx, `INSERT INTO accounts (user_email) VALUES ($1) RETURNING account_id`, email).Scan(&accountID); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Master().ExecContext(ctx, `DELETE FROM accounts WHERE account_id = $1`, accountID); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return NewSwipeRepository(db, 5*time.Second), db, accountID
+}
+
+func storedSwipe(db *database.DB, accountID, venueID int64) (direction string, updatedAt time.Time, found bool) {
+	err := db.Master().QueryRowContext(context.Background(), `
+		SELECT direction, updated_at FROM swipes
+		WHERE account_id = $1 AND venue_type = 'restaurant' AND venue_id = $2
+	`, accountID, venueID).Scan(&direction, &updatedAt)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return direction, updatedAt, true
+}
+
+func save(t *testing.T, repo swipe.SwipeRepository, accountID, venueID int64, direction string) *model.Swipe {
+	saved, err := repo.SaveSwipe(model.NewSwipe(accountID, model.VenueTypeRestaurant, venueID, direction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saved
+}
+
+// Repeating a swipe changes nothing, so it doesn't become the one an undo
+// reverts.
+func TestSaveSwipeIsIdempotent(t *testing.T) {
+	repo, _, accountID := newTestSwipeRepo(t)
+
+	first := save(t, repo, accountID, 1, model.SwipeLike)
+	save(t, repo, accountID, 2, model.SwipeDislike)
+
+	repeated := save(t, repo, accountID, 1, model.SwipeLike)
+	if repeated.SwipeID != first.SwipeID || !repeated.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("repeated SaveSwipe() = %+v, want %+v unchanged", repeated, first)
+	}
+
+	undone, err := repo.UndoLatestSwipe(accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone.VenueID != 2 {
+		t.Errorf("UndoLatestSwipe() reverted venue %d, want 2", undone.VenueID)
+	}
+
+	// The repeat kept no previous direction, so undoing the first swipe
+	// deletes it.
+	if undone, err := repo.UndoLatestSwipe(accountID); err != nil || undone.VenueID != 1 {
+		t.Fatalf("second UndoLatestSwipe() = %+v, %v, want venue 1", undone, err)
+	}
+	if _, err := repo.UndoLatestSwipe(accountID); !errors.Is(err, apperror.SwipeNotExists) {
+		t.Errorf("UndoLatestSwipe() with no swipes left error = %v, want SwipeNotExists", err)
+	}
+}
+
+func TestUndoLatestSwipe(t *testing.T) {
+	repo, db, accountID := newTestSwipeRepo(t)
+
+	liked := save(t, repo, accountID, 1, model.SwipeLike)
+	save(t, repo, accountID, 2, model.SwipeLike)
+	disliked := save(t, repo, accountID, 1, model.SwipeDislike)
+	if disliked.SwipeID != liked.SwipeID || !disliked.UpdatedAt.After(liked.UpdatedAt) {
+		t.Fatalf("re-swipe = %+v, want swipe %d moved to the latest", disliked, liked.SwipeID)
+	}
+
+	// Undoing the re-swipe returns it as it was and puts back the like,
+	// with the time it was made.
+	undone, err := repo.UndoLatestSwipe(accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone.VenueID != 1 || undone.Direction != model.SwipeDislike {
+		t.Errorf("UndoLatestSwipe() = %+v, want the dislike of venue 1", undone)
+	}
+	direction, updatedAt, found := storedSwipe(db, accountID, 1)
+	if !found || direction != model.SwipeLike || !updatedAt.Equal(liked.UpdatedAt) {
+		t.Errorf("venue 1 after undo = %s at %v, want the like at %v", direction, updatedAt, liked.UpdatedAt)
+	}
+
+	// The like is older than venue 2's swipe again, so that one goes next.
+	if undone, err := repo.UndoLatestSwipe(accountID); err != nil || undone.VenueID != 2 {
+		t.Fatalf("second UndoLatestSwipe() = %+v, %v, want venue 2", undone, err)
+	}
+	if _, _, found := storedSwipe(db, accountID, 2); found {
+		t.Error("first swipe of venue 2 still stored after undo")
+	}
+
+	if undone, err := repo.UndoLatestSwipe(accountID); err != nil || undone.VenueID != 1 || undone.Direction != model.SwipeLike {
+		t.Fatalf("third UndoLatestSwipe() = %+v, %v, want the like of venue 1", undone, err)
+	}
+	if _, _, found := storedSwipe(db, accountID, 1); found {
+		t.Error("venue 1 still stored after undoing its restored swipe")
+	}
+}
@@ -0,0 +1,92 @@
+package swipe
+
+import (
+	"log"
+
+	"github.com/atletaid/go-template/src/common/listing"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/recreation"
+	"github.com/atletaid/go-template/src/module/restaurant"
+)
+
+type Usecase interface {
+	Swipe(accountID int64, venueType string, venueID int64, direction string) (*model.Swipe, error)
+	UndoLastSwipe(accountID int64) (*model.Swipe, error)
+	GetLikedRestaurants(accountID int64, query *listing.Query) (*model.LikedRestaurantPage, error)
+	GetLikedRecreations(accountID int64, query *listing.Query) (*model.LikedRecreationPage, error)
+}
+
+type usecase struct {
+	swipeRepo      SwipeRepository
+	restaurantRepo restaurant.RestaurantRepository
+	recreationRepo recreation.RecreationRepository
+}
+
+func NewSwipeUsecase(
+	swipeRepo SwipeRepository,
+	restaurantRepo restaurant.RestaurantRepository,
+	recreationRepo recreation.RecreationRepository,
+) Usecase {
+	return &usecase{
+		swipeRepo:      swipeRepo,
+		restaurantRepo: restaurantRepo,
+		recreationRepo: recreationRepo,
+	}
+}
+
+// Swipe records the account's decision on a venue. Repeating a swipe is a
+// no-op; swiping the same venue another way replaces the decision.
+func (u *usecase) Swipe(accountID int64, venueType string, venueID int64, direction string) (*model.Swipe, error) {
+	var err error
+	switch venueType {
+	case model.VenueTypeRestaurant:
+		_, err = u.restaurantRepo.FindRestaurantByID(venueID)
+	case model.VenueTypeRecreation:
+		_, err = u.recreationRepo.FindRecreationByID(venueID)
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	swipe, err := u.swipeRepo.SaveSwipe(model.NewSwipe(accountID, venueType, venueID, direction))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return swipe, nil
+}
+
+// UndoLastSwipe reverts the account's most recent swipe and returns it: a
+// changed swipe goes back to its previous direction, a new one is
+// forgotten.
+func (u *usecase) UndoLastSwipe(accountID int64) (*model.Swipe, error) {
+	swipe, err := u.swipeRepo.UndoLatestSwipe(accountID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return swipe, nil
+}
+
+func (u *usecase) GetLikedRestaurants(accountID int64, query *listing.Query) (*model.LikedRestaurantPage, error) {
+	page, err := u.swipeRepo.FindLikedRestaurants(accountID, query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (u *usecase) GetLikedRecreations(accountID int64, query *listing.Query) (*model.LikedRecreationPage, error) {
+	page, err := u.swipeRepo.FindLikedRecreations(accountID, query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return page, nil
+}
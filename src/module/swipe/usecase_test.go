@@ -0,0 +1,104 @@
+package swipe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/atletaid/go-template/src/model"
+	"github.com/atletaid/go-template/src/module/recreation"
+	"github.com/atletaid/go-template/src/module/restaurant"
+)
+
+type fakeSwipeRepo struct {
+	SwipeRepository
+
+	saved []*model.Swipe
+}
+
+func (repo *fakeSwipeRepo) SaveSwipe(swipe *model.Swipe) (*model.Swipe, error) {
+	repo.saved = append(repo.saved, swipe)
+	return swipe, nil
+}
+
+func (repo *fakeSwipeRepo) UndoLatestSwipe(accountID int64) (*model.Swipe, error) {
+	if len(repo.saved) == 0 {
+		return nil, apperror.SwipeNotExists
+	}
+
+	latest := repo.saved[len(repo.saved)-1]
+	repo.saved = repo.saved[:len(repo.saved)-1]
+	return latest, nil
+}
+
+// The fake venue repositories know the venues with an ID below 100.
+type fakeRestaurantRepo struct {
+	restaurant.RestaurantRepository
+}
+
+type fakeRecreationRepo struct {
+	recreation.RecreationRepository
+}
+
+func (repo *fakeRestaurantRepo) FindRestaurantByID(restaurantID int64) (*model.Restaurant, error) {
+	if restaurantID >= 100 {
+		return nil, apperror.RestaurantNotExists
+	}
+	return &model.Restaurant{RestaurantID: restaurantID}, nil
+}
+
+func (repo *fakeRecreationRepo) FindRecreationByID(recreationID int64) (*model.Recreation, error) {
+	if recreationID >= 100 {
+		return nil, apperror.RecreationNotExists
+	}
+	return &model.Recreation{RecreationID: recreationID}, nil
+}
+
+func TestSwipe(t *testing.T) {
+	swipeRepo := &fakeSwipeRepo{}
+	u := NewSwipeUsecase(swipeRepo, &fakeRestaurantRepo{}, &fakeRecreationRepo{})
+
+	tests := []struct {
+		name      string
+		venueType string
+		venueID   int64
+		want      error
+	}{
+		{"restaurant", model.VenueTypeRestaurant, 1, nil},
+		{"recreation", model.VenueTypeRecreation, 2, nil},
+		{"unknown restaurant", model.VenueTypeRestaurant, 100, apperror.RestaurantNotExists},
+		{"unknown recreation", model.VenueTypeRecreation, 100, apperror.RecreationNotExists},
+	}
+
+	for _, tt := range tests {
+		saved := len(swipeRepo.saved)
+		swipe, err := u.Swipe(7, tt.venueType, tt.venueID, model.SwipeLike)
+
+		if tt.want != nil {
+			if !errors.Is(err, tt.want) || len(swipeRepo.saved) != saved {
+				t.Errorf("%s: Swipe() error = %v after %d saves, want %v and no save", tt.name, err, len(swipeRepo.saved)-saved, tt.want)
+			}
+			continue
+		}
+
+		if err != nil || swipe.AccountID != 7 || swipe.VenueType != tt.venueType || swipe.VenueID != tt.venueID || swipe.Direction != model.SwipeLike {
+			t.Errorf("%s: Swipe() = %+v, %v", tt.name, swipe, err)
+		}
+	}
+}
+
+func TestUndoLastSwipe(t *testing.T) {
+	swipeRepo := &fakeSwipeRepo{}
+	u := NewSwipeUsecase(swipeRepo, &fakeRestaurantRepo{}, &fakeRecreationRepo{})
+
+	if _, err := u.Swipe(7, model.VenueTypeRestaurant, 1, model.SwipeDislike); err != nil {
+		t.Fatal(err)
+	}
+
+	if swipe, err := u.UndoLastSwipe(7); err != nil || swipe.VenueID != 1 {
+		t.Errorf("UndoLastSwipe() = %+v, %v, want the swipe of venue 1", swipe, err)
+	}
+	if _, err := u.UndoLastSwipe(7); !errors.Is(err, apperror.SwipeNotExists) {
+		t.Errorf("UndoLastSwipe() with nothing to undo error = %v, want SwipeNotExists", err)
+	}
+}
@@ -3,18 +3,20 @@ package httputil
 import (
 	"encoding/json"
 	"net/http"
+	"reflect"
 	"strings"
 
+	"github.com/atletaid/go-template/src/common/apperror"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/form"
-	"github.com/atletaid/go-template/src/common/apperror"
 )
 
 type response struct {
-	StatusCode  int         `json:"status_code"`
-	Messages    []string    `json:"messages"`
-	ProcessTime float64     `json:"process_time"`
-	Data        interface{} `json:"data"`
+	StatusCode  int                   `json:"status_code"`
+	Messages    []string              `json:"messages"`
+	ProcessTime float64               `json:"process_time"`
+	Data        interface{}           `json:"data"`
+	Errors      []apperror.FieldError `json:"errors,omitempty"`
 }
 
 func WriteResponse(c *gin.Context, messages []string, processTime float64, data interface{}) {
@@ -30,34 +32,51 @@ func WriteResponse(c *gin.Context, messages []string, processTime float64, data
 }
 
 func WriteErrorResponse(c *gin.Context, processTime float64, err error) {
-	errCode := apperror.GetErrorCodes(err)
+	appErr := apperror.From(err)
 	c.Abort()
 	c.JSON(
-		errCode.HTTPcode,
+		appErr.HTTPStatus,
 		response{
-			StatusCode:  errCode.StatusCode,
-			Messages:    []string{err.Error()},
+			StatusCode:  appErr.Code,
+			Messages:    []string{appErr.Message},
 			ProcessTime: processTime,
 			Data:        nil,
+			Errors:      appErr.Details,
 		},
 	)
 }
 
-func WriteDecodeErrorResponse(c *gin.Context, processTime float64, data interface{}) {
-	err := apperror.DecodeError
-	errCode := apperror.GetErrorCodes(err)
+// WriteDecodeErrorResponse answers a request whose params couldn't be
+// decoded into req. An empty req of the same type is sent back as the
+// example of the expected params, never what was decoded, which may hold
+// passwords or tokens.
+func WriteDecodeErrorResponse(c *gin.Context, processTime float64, req interface{}) {
+	appErr := apperror.DecodeError
 	c.Abort()
 	c.JSON(
-		errCode.HTTPcode,
+		appErr.HTTPStatus,
 		response{
-			StatusCode:  errCode.StatusCode,
-			Messages:    []string{err.Error()},
+			StatusCode:  appErr.Code,
+			Messages:    []string{appErr.Message},
 			ProcessTime: processTime,
-			Data:        data,
+			Data:        example(req),
 		},
 	)
 }
 
+// example returns a new zero value of req's type.
+func example(req interface{}) interface{} {
+	t := reflect.TypeOf(req)
+	if t == nil {
+		return nil
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface()
+}
+
 func DecodeFormRequest(r *http.Request, req interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 	if strings.Contains(contentType, "application/json") {
@@ -69,3 +88,8 @@ func DecodeFormRequest(r *http.Request, req interface{}) error {
 	}
 	return apperror.DecodeError
 }
+
+// DecodeQueryRequest decodes the URL query string into req by its form tags.
+func DecodeQueryRequest(r *http.Request, req interface{}) error {
+	return form.NewDecoder().Decode(req, r.URL.Query())
+}
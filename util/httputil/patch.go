@@ -0,0 +1,66 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+)
+
+// DecodeMergePatch applies the JSON merge patch (RFC 7386) in r's body to
+// req, which must point to a struct already holding the resource's current
+// state. Fields the patch sets to null go back to their zero value.
+func DecodeMergePatch(r *http.Request, req interface{}) error {
+	contentType := r.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "application/merge-patch+json") && !strings.Contains(contentType, "application/json") {
+		return apperror.DecodeError
+	}
+
+	var patch interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return err
+	}
+
+	current, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(current, &document); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(req).Elem()
+	target.Set(reflect.Zero(target.Type()))
+	return json.Unmarshal(merged, req)
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
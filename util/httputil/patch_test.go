@@ -0,0 +1,141 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The examples of RFC 7386, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	decode := func(document string) interface{} {
+		var value interface{}
+		if err := json.Unmarshal([]byte(document), &value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	for _, tt := range tests {
+		if got := mergePatch(decode(tt.target), decode(tt.patch)); !reflect.DeepEqual(got, decode(tt.want)) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}
+
+type patchAddress struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+}
+
+type patchRequest struct {
+	Name    string       `json:"name"`
+	Price   int          `json:"price"`
+	Tags    []string     `json:"tags"`
+	Address patchAddress `json:"address"`
+}
+
+func TestDecodeMergePatch(t *testing.T) {
+	current := patchRequest{
+		Name:    "Warung",
+		Price:   20,
+		Tags:    []string{"spicy", "cheap"},
+		Address: patchAddress{City: "Bandung", Street: "Braga"},
+	}
+
+	tests := []struct {
+		name  string
+		patch string
+		want  patchRequest
+	}{
+		{
+			"empty patch",
+			`{}`,
+			current,
+		},
+		{
+			"null resets a field",
+			`{"price":null}`,
+			patchRequest{Name: "Warung", Tags: current.Tags, Address: current.Address},
+		},
+		{
+			"nested object is merged",
+			`{"address":{"street":"Dago"}}`,
+			patchRequest{Name: "Warung", Price: 20, Tags: current.Tags, Address: patchAddress{City: "Bandung", Street: "Dago"}},
+		},
+		{
+			"null inside a nested object",
+			`{"address":{"city":null}}`,
+			patchRequest{Name: "Warung", Price: 20, Tags: current.Tags, Address: patchAddress{Street: "Braga"}},
+		},
+		{
+			"array is replaced",
+			`{"tags":["sweet"]}`,
+			patchRequest{Name: "Warung", Price: 20, Tags: []string{"sweet"}, Address: current.Address},
+		},
+		{
+			"unknown fields are ignored",
+			`{"owner":"someone","name":"Kedai"}`,
+			patchRequest{Name: "Kedai", Price: 20, Tags: current.Tags, Address: current.Address},
+		},
+	}
+
+	for _, tt := range tests {
+		req := current
+		r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.patch))
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+
+		if err := DecodeMergePatch(r, &req); err != nil {
+			t.Errorf("%s: DecodeMergePatch() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(req, tt.want) {
+			t.Errorf("%s: DecodeMergePatch() = %+v, want %+v", tt.name, req, tt.want)
+		}
+	}
+}
+
+func TestDecodeMergePatchRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"wrong content type", "text/plain", `{"name":"Kedai"}`},
+		{"malformed body", "application/merge-patch+json", `{"name":`},
+		{"wrong type for a field", "application/json", `{"price":"free"}`},
+	}
+
+	for _, tt := range tests {
+		req := patchRequest{Name: "Warung"}
+		r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+
+		if err := DecodeMergePatch(r, &req); err == nil {
+			t.Errorf("%s: DecodeMergePatch() error = nil, want an error", tt.name)
+		}
+	}
+}
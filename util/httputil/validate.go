@@ -0,0 +1,110 @@
+package httputil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/atletaid/go-template/src/common/apperror"
+	"github.com/gin-gonic/gin/binding"
+	validator "gopkg.in/go-playground/validator.v8"
+)
+
+// ValidateRequest checks req against the rules in its `binding` struct tags
+// and reports every failing field at once as an apperror.ValidationError.
+func ValidateRequest(req interface{}) error {
+	err := binding.Validator.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+
+	validationErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return apperror.ValidationError.Wrap(err)
+	}
+
+	details := make([]apperror.FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		details = append(details, apperror.FieldError{
+			Field:   jsonFieldName(req, fieldErr.Field),
+			Message: validationMessage(fieldErr),
+		})
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].Field < details[j].Field
+	})
+	return apperror.ValidationError.WithDetails(details...)
+}
+
+// jsonFieldName maps a struct field to the name clients send it under.
+func jsonFieldName(req interface{}, field string) string {
+	t := reflect.TypeOf(req)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	structField, found := t.FieldByName(field)
+	if !found {
+		return field
+	}
+
+	if name := strings.Split(structField.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field
+}
+
+func validationMessage(fieldErr *validator.FieldError) string {
+	isString := fieldErr.Kind == reflect.String
+
+	switch fieldErr.Tag {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters", fieldErr.Param)
+		}
+		return fmt.Sprintf("must be at least %s", fieldErr.Param)
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters", fieldErr.Param)
+		}
+		return fmt.Sprintf("must be at most %s", fieldErr.Param)
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", fieldErr.Param)
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", fieldErr.Param)
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param)
+	}
+
+	if values, ok := oneOfValues(fieldErr.Tag); ok {
+		return "must be one of " + strings.Join(values, ", ")
+	}
+
+	return fmt.Sprintf("failed %s validation", fieldErr.Tag)
+}
+
+// oneOfValues reads an enum written as "eq=a|eq=b|eq=c".
+func oneOfValues(tag string) ([]string, bool) {
+	alternatives := strings.Split(tag, "|")
+	if len(alternatives) < 2 {
+		return nil, false
+	}
+
+	values := make([]string, 0, len(alternatives))
+	for _, alternative := range alternatives {
+		if !strings.HasPrefix(alternative, "eq=") {
+			return nil, false
+		}
+		values = append(values, strings.TrimPrefix(alternative, "eq="))
+	}
+
+	return values, true
+}